@@ -1,6 +1,9 @@
 package skedda
 
-import "fmt"
+import (
+	"fmt"
+	"time"
+)
 
 // Booking of a space in Skedda
 type Booking struct {
@@ -27,3 +30,27 @@ func (b Booking) String() string {
 
 	return fmt.Sprintf("%s -- %s - %s", b.Title, b.StartTime.Format(dateTimeFormat), b.EndTime.Format(timeFormat))
 }
+
+// Occurrences returns the intervals during which the booking takes place in a
+// time period, expanding recurring bookings into each of their occurrences
+func (b Booking) Occurrences(from, to time.Time) []Interval {
+	duration := b.EndTime.Sub(b.StartTime.Time)
+	if len(b.RecurrenceRule.All()) == 0 {
+		occurrence := Interval{b.StartTime.Time, b.EndTime.Time}
+		if !occurrence.Overlaps(from, to) {
+			return nil
+		}
+		return []Interval{occurrence}
+	}
+
+	occurrences := []Interval{}
+	for _, t := range b.RecurrenceRule.Between(from.Add(-duration), to, true) {
+		start := time.Date(t.Year(), t.Month(), t.Day(), b.StartTime.Hour(), b.StartTime.Minute(), b.StartTime.Second(), 0, time.UTC)
+		occurrence := Interval{start, start.Add(duration)}
+		if occurrence.Overlaps(from, to) {
+			occurrences = append(occurrences, occurrence)
+		}
+	}
+
+	return occurrences
+}
@@ -0,0 +1,58 @@
+package skedda_test
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/alyyousuf7/skedda"
+)
+
+func TestBookingOccurrences(t *testing.T) {
+	at := func(day, hour int) time.Time {
+		return time.Date(2020, time.March, day, hour, 0, 0, 0, time.UTC)
+	}
+
+	single := `{"id":1,"start":"2020-03-04T09:00:00","end":"2020-03-04T10:00:00","recurrenceRule":null}`
+	weekdays := `{"id":2,"start":"2020-03-02T09:00:00","end":"2020-03-02T10:00:00","recurrenceRule":"DTSTART:20200302T090000Z\nRRULE:FREQ=WEEKLY;BYDAY=MO,TU,WE,TH,FR;COUNT=20"}`
+	overnight := `{"id":3,"start":"2020-03-02T22:00:00","end":"2020-03-03T02:00:00","recurrenceRule":"DTSTART:20200302T220000Z\nRRULE:FREQ=DAILY;COUNT=3"}`
+
+	testCases := []struct {
+		Booking  string
+		From, To time.Time
+		Expected []time.Time
+	}{
+		{single, at(4, 0), at(5, 0), []time.Time{at(4, 9)}},
+		{single, at(4, 9), at(4, 10), []time.Time{at(4, 9)}},
+		{single, at(4, 10), at(4, 12), nil},
+		{single, at(5, 0), at(6, 0), nil},
+		{weekdays, at(2, 0), at(9, 0), []time.Time{at(2, 9), at(3, 9), at(4, 9), at(5, 9), at(6, 9)}},
+		{weekdays, at(4, 9), at(4, 10), []time.Time{at(4, 9)}},
+		{weekdays, at(7, 0), at(9, 0), nil},
+		{weekdays, at(4, 10), at(5, 9), nil},
+		{overnight, at(3, 1), at(3, 2), []time.Time{at(2, 22)}},
+		{overnight, at(4, 0), at(5, 0), []time.Time{at(3, 22), at(4, 22)}},
+		{overnight, at(5, 2), at(6, 0), nil},
+	}
+
+	for i, testCase := range testCases {
+		var booking skedda.Booking
+		if err := json.Unmarshal([]byte(testCase.Booking), &booking); err != nil {
+			t.Fatal(err)
+		}
+
+		duration := booking.EndTime.Sub(booking.StartTime.Time)
+		occurrences := booking.Occurrences(testCase.From, testCase.To)
+		if len(occurrences) != len(testCase.Expected) {
+			t.Errorf("Expected %v but got %v: Test case %d", testCase.Expected, occurrences, i)
+			continue
+		}
+
+		for j, start := range testCase.Expected {
+			if !occurrences[j].Start.Equal(start) || !occurrences[j].End.Equal(start.Add(duration)) {
+				t.Errorf("Expected %v but got %v: Test case %d", testCase.Expected, occurrences, i)
+				break
+			}
+		}
+	}
+}
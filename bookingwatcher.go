@@ -0,0 +1,224 @@
+package skedda
+
+import (
+	"context"
+	"sort"
+	"time"
+)
+
+// BookingEventType is the kind of change that happened to a booking
+type BookingEventType int
+
+const (
+	// BookingCreated is emitted when a booking appears
+	BookingCreated BookingEventType = iota + 1
+
+	// BookingUpdated is emitted when the content of a booking changes
+	BookingUpdated
+
+	// BookingDeleted is emitted when a booking disappears
+	BookingDeleted
+)
+
+func (t BookingEventType) String() string {
+	switch t {
+	case BookingCreated:
+		return "created"
+	case BookingUpdated:
+		return "updated"
+	case BookingDeleted:
+		return "deleted"
+	}
+	return "unknown"
+}
+
+// BookingEvent describes a change to a booking between two snapshots. When
+// fetching the bookings of the domain fails, only Domain and Err are set.
+type BookingEvent struct {
+	Type     BookingEventType
+	Domain   string
+	Booking  *Booking
+	Previous *Booking
+	Err      error
+}
+
+// BookingWatcher periodically snapshots the bookings of domains and emits the
+// differences between consecutive snapshots
+type BookingWatcher struct {
+	// Domains to watch
+	Domains []string
+
+	// Interval between snapshots
+	Interval time.Duration
+
+	// LookAhead is the length of the watched period, starting today
+	LookAhead time.Duration
+
+	skedda    *Skedda
+	snapshots map[string][]*Booking
+	windows   map[string]time.Time
+}
+
+// NewBookingWatcher initializes BookingWatcher for domains. It returns
+// ErrInvalidInterval when interval is not positive.
+func NewBookingWatcher(s *Skedda, domains []string, interval, lookAhead time.Duration) (*BookingWatcher, error) {
+	if interval <= 0 {
+		return nil, ErrInvalidInterval
+	}
+
+	return &BookingWatcher{
+		Domains:   domains,
+		Interval:  interval,
+		LookAhead: lookAhead,
+		skedda:    s,
+		snapshots: map[string][]*Booking{},
+		windows:   map[string]time.Time{},
+	}, nil
+}
+
+// Watch starts taking snapshots and emits the changes. The first snapshot of
+// each domain is taken as the baseline and does not emit any event. The
+// channel is closed once ctx is done, or after a single ErrInvalidInterval
+// event when Interval is not positive.
+func (w *BookingWatcher) Watch(ctx context.Context) <-chan BookingEvent {
+	ch := make(chan BookingEvent)
+
+	go func() {
+		defer close(ch)
+
+		if w.Interval <= 0 {
+			select {
+			case ch <- BookingEvent{Err: ErrInvalidInterval}:
+			case <-ctx.Done():
+			}
+			return
+		}
+
+		for {
+			from := time.Now().UTC().Truncate(24 * time.Hour)
+			to := from.Add(w.LookAhead)
+
+			for _, domain := range w.Domains {
+				for _, event := range w.poll(domain, from, to) {
+					select {
+					case ch <- event:
+					case <-ctx.Done():
+						return
+					}
+				}
+			}
+
+			select {
+			case <-time.After(w.Interval):
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return ch
+}
+
+func (w *BookingWatcher) poll(domain string, from, to time.Time) []BookingEvent {
+	bookings, err := w.skedda.Bookings(domain, from, to)
+	if err != nil {
+		return []BookingEvent{{Domain: domain, Err: err}}
+	}
+
+	previous, ok := w.snapshots[domain]
+	previousTo := w.windows[domain]
+	w.snapshots[domain] = bookings
+	w.windows[domain] = to
+	if !ok {
+		return nil
+	}
+
+	events := []BookingEvent{}
+	for _, event := range DiffBookings(previous, bookings) {
+		// Bookings leaving the period as it moves forward are not deleted
+		if event.Type == BookingDeleted && !bookingOverlaps(event.Booking, from, to) {
+			continue
+		}
+
+		// Bookings entering the period as it moves forward are not created,
+		// they were only out of sight
+		if event.Type == BookingCreated && to.After(previousTo) && len(event.Booking.Occurrences(from, previousTo)) == 0 {
+			continue
+		}
+
+		event.Domain = domain
+		events = append(events, event)
+	}
+
+	return events
+}
+
+// DiffBookings compares two snapshots of bookings by their ID and content and
+// returns the events turning the old snapshot into the new one
+func DiffBookings(old, current []*Booking) []BookingEvent {
+	oldByID := map[int]*Booking{}
+	for _, b := range old {
+		oldByID[b.ID] = b
+	}
+
+	events := []BookingEvent{}
+	seen := map[int]bool{}
+	for _, b := range current {
+		seen[b.ID] = true
+
+		prev, ok := oldByID[b.ID]
+		if !ok {
+			events = append(events, BookingEvent{Type: BookingCreated, Booking: b})
+		} else if !bookingEqual(prev, b) {
+			events = append(events, BookingEvent{Type: BookingUpdated, Booking: b, Previous: prev})
+		}
+	}
+
+	for _, b := range old {
+		if !seen[b.ID] {
+			events = append(events, BookingEvent{Type: BookingDeleted, Booking: b})
+		}
+	}
+
+	return events
+}
+
+func bookingEqual(a, b *Booking) bool {
+	if a.Title != b.Title || a.VenueID != b.VenueID {
+		return false
+	}
+
+	if !a.StartTime.Equal(b.StartTime.Time) || !a.EndTime.Equal(b.EndTime.Time) {
+		return false
+	}
+
+	if a.RecurrenceRule.String() != b.RecurrenceRule.String() {
+		return false
+	}
+
+	if len(a.SpaceIDs) != len(b.SpaceIDs) {
+		return false
+	}
+
+	aSpaceIDs := append([]int{}, a.SpaceIDs...)
+	bSpaceIDs := append([]int{}, b.SpaceIDs...)
+	sort.Ints(aSpaceIDs)
+	sort.Ints(bSpaceIDs)
+	for i := range aSpaceIDs {
+		if aSpaceIDs[i] != bSpaceIDs[i] {
+			return false
+		}
+	}
+
+	return true
+}
+
+// bookingOverlaps tells whether a booking takes place during a time period.
+// Recurring bookings are always considered to overlap.
+func bookingOverlaps(b *Booking, from, to time.Time) bool {
+	if len(b.RecurrenceRule.All()) > 0 {
+		return true
+	}
+
+	return TimeOverlaps(from, to, b.StartTime.Time, b.EndTime.Time)
+}
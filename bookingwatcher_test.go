@@ -0,0 +1,86 @@
+package skedda_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alyyousuf7/skedda"
+)
+
+func TestDiffBookings(t *testing.T) {
+	at := func(hour int) skedda.DateTime {
+		return skedda.DateTime{Time: time.Date(1991, time.March, 7, hour, 0, 0, 0, time.UTC)}
+	}
+
+	old := []*skedda.Booking{
+		{ID: 1, Title: "Standup", StartTime: at(9), EndTime: at(10), SpaceIDs: []int{1, 2}},
+		{ID: 2, Title: "Retro", StartTime: at(11), EndTime: at(12), SpaceIDs: []int{1}},
+		{ID: 3, Title: "Lunch", StartTime: at(13), EndTime: at(14), SpaceIDs: []int{3}},
+	}
+	current := []*skedda.Booking{
+		{ID: 1, Title: "Standup", StartTime: at(9), EndTime: at(10), SpaceIDs: []int{2, 1}},
+		{ID: 2, Title: "Retro", StartTime: at(12), EndTime: at(13), SpaceIDs: []int{1}},
+		{ID: 4, Title: "Planning", StartTime: at(15), EndTime: at(16), SpaceIDs: []int{1}},
+	}
+
+	expected := []struct {
+		eventType skedda.BookingEventType
+		id        int
+	}{
+		{skedda.BookingUpdated, 2},
+		{skedda.BookingCreated, 4},
+		{skedda.BookingDeleted, 3},
+	}
+
+	events := skedda.DiffBookings(old, current)
+	if len(events) != len(expected) {
+		t.Fatalf("Expected %d events but got %d", len(expected), len(events))
+	}
+
+	for i, e := range expected {
+		if events[i].Type != e.eventType || events[i].Booking.ID != e.id {
+			t.Errorf("Expected %s booking %d but got %s booking %d: Event %d", e.eventType, e.id, events[i].Type, events[i].Booking.ID, i+1)
+		}
+	}
+
+	if events[0].Previous != old[1] {
+		t.Errorf("Expected updated event to carry the previous booking")
+	}
+}
+
+func TestNewBookingWatcherInterval(t *testing.T) {
+	testCases := []struct {
+		Interval time.Duration
+		Expected error
+	}{
+		{time.Minute, nil},
+		{0, skedda.ErrInvalidInterval},
+		{-1 * time.Minute, skedda.ErrInvalidInterval},
+	}
+
+	for i, testCase := range testCases {
+		if _, err := skedda.NewBookingWatcher(nil, []string{"acme"}, testCase.Interval, 24*time.Hour); err != testCase.Expected {
+			t.Errorf("Expected %v but got %v: Test case %d", testCase.Expected, err, i)
+		}
+	}
+
+	// An Interval set after initialization is rejected by Watch without polling
+	w, err := skedda.NewBookingWatcher(nil, []string{"acme"}, time.Minute, 24*time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Interval = 0
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	events := []skedda.BookingEvent{}
+	for event := range w.Watch(ctx) {
+		events = append(events, event)
+	}
+
+	if len(events) != 1 || events[0].Err != skedda.ErrInvalidInterval {
+		t.Errorf("Expected a single %v event but got %v", skedda.ErrInvalidInterval, events)
+	}
+}
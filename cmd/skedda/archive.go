@@ -0,0 +1,259 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"sort"
+	"time"
+
+	"github.com/alyyousuf7/skedda"
+)
+
+// archiveChunk is the period of bookings fetched at once while syncing
+const archiveChunk = 7 * 24 * time.Hour
+
+// archiveRange is the period of a domain synced into the archive
+type archiveRange struct {
+	From     time.Time
+	Till     time.Time
+	SyncedAt time.Time
+}
+
+func archivePath(configPath string) string {
+	return path.Join(configPath, "archive")
+}
+
+func loadArchiveState(configPath string) (map[string]archiveRange, error) {
+	state := map[string]archiveRange{}
+
+	buf, err := ioutil.ReadFile(path.Join(archivePath(configPath), "state.json"))
+	if os.IsNotExist(err) {
+		return state, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(buf, &state); err != nil {
+		return nil, err
+	}
+
+	return state, nil
+}
+
+func saveArchiveState(configPath string, state map[string]archiveRange) error {
+	buf, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(path.Join(archivePath(configPath), "state.json"), buf, 0600)
+}
+
+// loadArchiveRecords reads the bookings archived for a domain. The archive is
+// append-only, so the last record of a booking is its latest version.
+func loadArchiveRecords(configPath, domain string) (map[int][]byte, error) {
+	records := map[int][]byte{}
+
+	f, err := os.Open(path.Join(archivePath(configPath), domain+".jsonl"))
+	if os.IsNotExist(err) {
+		return records, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+
+		booking := struct{ ID int }{}
+		if err := json.Unmarshal(line, &booking); err != nil {
+			return nil, fmt.Errorf("reading archive of %s: %w", domain, err)
+		}
+		records[booking.ID] = append([]byte{}, line...)
+	}
+
+	return records, scanner.Err()
+}
+
+// loadArchive returns the bookings archived for a domain
+func loadArchive(configPath, domain string) ([]*skedda.Booking, error) {
+	records, err := loadArchiveRecords(configPath, domain)
+	if err != nil {
+		return nil, err
+	}
+
+	bookings := []*skedda.Booking{}
+	for _, record := range records {
+		booking := &skedda.Booking{}
+		if err := json.Unmarshal(record, booking); err != nil {
+			return nil, fmt.Errorf("reading archive of %s: %w", domain, err)
+		}
+		bookings = append(bookings, booking)
+	}
+
+	sort.Slice(bookings, func(i, j int) bool {
+		if !bookings[i].StartTime.Equal(bookings[j].StartTime.Time) {
+			return bookings[i].StartTime.Before(bookings[j].StartTime.Time)
+		}
+		return bookings[i].ID < bookings[j].ID
+	})
+
+	return bookings, nil
+}
+
+// syncArchive fetches the bookings of a domain which are missing in the
+// archive between from and till, and appends the new and changed ones. The
+// synced period is kept contiguous, and only extends up to today so that
+// upcoming bookings are fetched again on the next sync. It returns the number
+// of bookings appended.
+func syncArchive(s *skedda.Skedda, configPath, domain string, from, till, today time.Time) (int, error) {
+	if err := os.MkdirAll(archivePath(configPath), 0700); err != nil {
+		return 0, err
+	}
+
+	state, err := loadArchiveState(configPath)
+	if err != nil {
+		return 0, err
+	}
+
+	periods := []skedda.Interval{{Start: from, End: till}}
+	if synced, ok := state[domain]; ok {
+		periods = []skedda.Interval{}
+		if from.Before(synced.From) {
+			periods = append(periods, skedda.Interval{Start: from, End: synced.From})
+		}
+		if till.After(synced.Till) {
+			periods = append(periods, skedda.Interval{Start: synced.Till, End: till})
+		}
+
+		if synced.From.Before(from) {
+			from = synced.From
+		}
+		if synced.Till.After(till) {
+			till = synced.Till
+		}
+	}
+
+	records, err := loadArchiveRecords(configPath, domain)
+	if err != nil {
+		return 0, err
+	}
+
+	f, err := os.OpenFile(path.Join(archivePath(configPath), domain+".jsonl"), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	appended := 0
+	for _, period := range periods {
+		for start := period.Start; start.Before(period.End); start = start.Add(archiveChunk) {
+			end := start.Add(archiveChunk)
+			if end.After(period.End) {
+				end = period.End
+			}
+
+			bookings, err := s.Bookings(domain, start, end)
+			if err != nil {
+				return appended, fmt.Errorf("fetching bookings of %s from %s: %w", domain, start.Format("2006-01-02"), err)
+			}
+
+			for _, booking := range bookings {
+				record, err := json.Marshal(booking)
+				if err != nil {
+					return appended, err
+				}
+
+				if bytes.Equal(records[booking.ID], record) {
+					continue
+				}
+
+				if _, err := f.Write(append(record, '\n')); err != nil {
+					return appended, err
+				}
+				records[booking.ID] = record
+				appended++
+			}
+		}
+	}
+
+	if till.After(today) {
+		till = today
+	}
+	if from.Before(till) {
+		state[domain] = archiveRange{From: from, Till: till, SyncedAt: time.Now()}
+	}
+
+	return appended, saveArchiveState(configPath, state)
+}
+
+// isArchived tells whether the domains of the spaces are archived between from
+// and till
+func isArchived(configPath string, venues skedda.VenueList, spaces skedda.SpaceList, from, till time.Time) bool {
+	state, err := loadArchiveState(configPath)
+	if err != nil {
+		return false
+	}
+
+	for _, domain := range spaceDomains(venues, spaces) {
+		synced, ok := state[domain]
+		if !ok || from.Before(synced.From) || till.After(synced.Till) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// archiveOccurrences returns when each space is occupied between from and till
+// according to the archive
+func archiveOccurrences(configPath string, venues skedda.VenueList, spaces skedda.SpaceList, from, till time.Time) (map[*skedda.Space][]skedda.Interval, error) {
+	state, err := loadArchiveState(configPath)
+	if err != nil {
+		return nil, err
+	}
+
+	occurrences := map[*skedda.Space][]skedda.Interval{}
+	for _, space := range spaces {
+		occurrences[space] = []skedda.Interval{}
+	}
+
+	for _, domain := range spaceDomains(venues, spaces) {
+		synced, ok := state[domain]
+		if !ok {
+			return nil, fmt.Errorf("%s is not archived yet, try using `skedda sync`", domain)
+		}
+		fmt.Fprintf(os.Stderr, "Offline: %s synced %s\n", domain, dataAge(synced.SyncedAt))
+		if from.Before(synced.From) || till.After(synced.Till) {
+			fmt.Fprintf(os.Stderr, "Warning: %s is only archived from %s till %s\n", domain, synced.From.Format("2006-01-02"), synced.Till.Format("2006-01-02"))
+		}
+
+		bookings, err := loadArchive(configPath, domain)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, booking := range bookings {
+			for _, id := range booking.SpaceIDs {
+				space := spaces.FindByID(id)
+				if space == nil {
+					continue
+				}
+
+				occurrences[space] = append(occurrences[space], booking.Occurrences(from, till)...)
+			}
+		}
+	}
+
+	return occurrences, nil
+}
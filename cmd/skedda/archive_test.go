@@ -0,0 +1,103 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/alyyousuf7/skedda"
+)
+
+func TestArchiveOccurrences(t *testing.T) {
+	dir, err := ioutil.TempDir("", "skedda")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	if err := os.MkdirAll(archivePath(dir), 0700); err != nil {
+		t.Fatal(err)
+	}
+
+	// The second record of booking 1 moves it to another time
+	records := `{"ID":1,"Title":"Standup","start":"2020-01-06T09:00:00","end":"2020-01-06T09:30:00","RecurrenceRule":null,"spaces":[10],"venue":1}
+{"ID":2,"Title":"Retro","start":"2020-01-06T14:00:00","end":"2020-01-06T15:00:00","RecurrenceRule":null,"spaces":[10],"venue":1}
+{"ID":1,"Title":"Standup","start":"2020-01-06T10:00:00","end":"2020-01-06T10:30:00","RecurrenceRule":null,"spaces":[10],"venue":1}
+`
+	if err := ioutil.WriteFile(path.Join(archivePath(dir), "acme.jsonl"), []byte(records), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	from := time.Date(2020, 1, 6, 0, 0, 0, 0, time.UTC)
+	till := from.Add(24 * time.Hour)
+	if err := saveArchiveState(dir, map[string]archiveRange{"acme": {From: from, Till: till}}); err != nil {
+		t.Fatal(err)
+	}
+
+	venues := skedda.VenueList{{ID: 1, Domain: "acme"}}
+	space := &skedda.Space{ID: 10, VenueID: 1}
+	occurrences, err := archiveOccurrences(dir, venues, skedda.SpaceList{space}, from, till)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []skedda.Interval{
+		{Start: from.Add(10 * time.Hour), End: from.Add(10*time.Hour + 30*time.Minute)},
+		{Start: from.Add(14 * time.Hour), End: from.Add(15 * time.Hour)},
+	}
+
+	if len(occurrences[space]) != len(expected) {
+		t.Fatalf("Expected %v but got %v", expected, occurrences[space])
+	}
+
+	for i, interval := range expected {
+		if !interval.Start.Equal(occurrences[space][i].Start) || !interval.End.Equal(occurrences[space][i].End) {
+			t.Errorf("Expected %v but got %v: Test case %d", interval, occurrences[space][i], i)
+		}
+	}
+}
+
+func TestIsArchived(t *testing.T) {
+	dir, err := ioutil.TempDir("", "skedda")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	if err := os.MkdirAll(archivePath(dir), 0700); err != nil {
+		t.Fatal(err)
+	}
+
+	day := func(d int) time.Time {
+		return time.Date(2020, time.January, d, 0, 0, 0, 0, time.UTC)
+	}
+
+	if err := saveArchiveState(dir, map[string]archiveRange{"acme": {From: day(6), Till: day(20)}}); err != nil {
+		t.Fatal(err)
+	}
+
+	venues := skedda.VenueList{{ID: 1, Domain: "acme"}, {ID: 2, Domain: "acme-lisbon"}}
+	london := skedda.SpaceList{{ID: 10, VenueID: 1}}
+	both := skedda.SpaceList{{ID: 10, VenueID: 1}, {ID: 20, VenueID: 2}}
+
+	testCases := []struct {
+		Spaces   skedda.SpaceList
+		From     time.Time
+		Till     time.Time
+		Expected bool
+	}{
+		{london, day(6), day(20), true},
+		{london, day(8), day(15), true},
+		{london, day(5), day(15), false},
+		{london, day(8), day(21), false},
+		{both, day(8), day(15), false},
+	}
+
+	for i, testCase := range testCases {
+		if result := isArchived(dir, venues, testCase.Spaces, testCase.From, testCase.Till); result != testCase.Expected {
+			t.Errorf("Expected %v but got %v: Test case %d", testCase.Expected, result, i)
+		}
+	}
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"sync"
+	"time"
+
+	"github.com/alyyousuf7/skedda"
+)
+
+// fetchSpaceBookings fetches the bookings of the spaces during a time period,
+// querying each of their venues concurrently
+func fetchSpaceBookings(s *skedda.Skedda, venues skedda.VenueList, spaces skedda.SpaceList, from, till time.Time) (map[*skedda.Space][]*skedda.Booking, error) {
+	venueBookings, err := fetchVenueBookings(s, venues, spaces, from, till)
+	if err != nil {
+		return nil, err
+	}
+
+	return groupBySpace(spaces, venueBookings), nil
+}
+
+// fetchVenueBookings fetches the bookings of the venues of the spaces during a
+// time period, querying each venue concurrently
+func fetchVenueBookings(s *skedda.Skedda, venues skedda.VenueList, spaces skedda.SpaceList, from, till time.Time) (map[*skedda.Venue][]*skedda.Booking, error) {
+	type Result struct {
+		Venue    *skedda.Venue
+		Bookings []*skedda.Booking
+		Error    error
+	}
+
+	worker := func(venue *skedda.Venue, resultCh chan<- Result, wg *sync.WaitGroup) {
+		bookings, err := s.Bookings(venue.Domain, from, till)
+		resultCh <- Result{venue, bookings, err}
+		wg.Done()
+	}
+
+	filteredVenues := map[*skedda.Venue]bool{}
+	for _, s := range spaces {
+		venue := venues.FindByID(s.VenueID)
+		filteredVenues[venue] = true
+	}
+
+	resultCh := make(chan Result, len(filteredVenues))
+	var wg sync.WaitGroup
+	for venue := range filteredVenues {
+		wg.Add(1)
+		go worker(venue, resultCh, &wg)
+	}
+	wg.Wait()
+	close(resultCh)
+
+	venueBookings := map[*skedda.Venue][]*skedda.Booking{}
+	for result := range resultCh {
+		if result.Error != nil {
+			return nil, result.Error
+		}
+
+		venueBookings[result.Venue] = result.Bookings
+	}
+
+	return venueBookings, nil
+}
+
+// groupBySpace groups the bookings of the venues by the spaces they book
+func groupBySpace(spaces skedda.SpaceList, venueBookings map[*skedda.Venue][]*skedda.Booking) map[*skedda.Space][]*skedda.Booking {
+	spaceBookings := map[*skedda.Space][]*skedda.Booking{}
+
+	// create empty keys
+	for _, space := range spaces {
+		spaceBookings[space] = []*skedda.Booking{}
+	}
+
+	// fill up with result
+	for _, bookings := range venueBookings {
+		for _, booking := range bookings {
+			for _, spaceID := range booking.SpaceIDs {
+				space := spaces.FindByID(spaceID)
+				if space != nil {
+					spaceBookings[space] = append(spaceBookings[space], booking)
+				}
+			}
+		}
+	}
+
+	return spaceBookings
+}
+
+// cachedVenueBookings loads the bookings of the venues of the spaces during a
+// time period from the cache, along with when the oldest of them were fetched
+func cachedVenueBookings(configPath string, venues skedda.VenueList, spaces skedda.SpaceList, from, till time.Time) (map[*skedda.Venue][]*skedda.Booking, time.Time, error) {
+	venueBookings := map[*skedda.Venue][]*skedda.Booking{}
+	oldest := time.Time{}
+	for _, space := range spaces {
+		venue := venues.FindByID(space.VenueID)
+		if _, ok := venueBookings[venue]; ok {
+			continue
+		}
+
+		bookings, fetchedAt, err := loadBookingsFromCache(configPath, venue.Domain, from, till)
+		if err != nil {
+			return nil, time.Time{}, err
+		}
+
+		venueBookings[venue] = bookings
+		if oldest.IsZero() || fetchedAt.Before(oldest) {
+			oldest = fetchedAt
+		}
+	}
+
+	return venueBookings, oldest, nil
+}
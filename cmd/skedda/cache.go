@@ -3,21 +3,48 @@ package main
 import (
 	"encoding/json"
 	"fmt"
+	"io"
 	"io/ioutil"
+	"os"
 	"path"
 	"sync"
+	"text/tabwriter"
+	"time"
 
 	"github.com/alyyousuf7/skedda"
 )
 
-func load(s *skedda.Skedda, noCache bool, configPath string) (skedda.VenueList, skedda.SpaceList, error) {
+// cacheVersion is the format of the cache file. Caches of another format are
+// fetched again. Version 2 caches the booking grid of the venues.
+const cacheVersion = 2
+
+// backgroundRefresh tracks the refreshes of the cache running in the
+// background, which have to finish before exiting
+var backgroundRefresh sync.WaitGroup
+
+// cacheMu guards the updates of the cache file
+var cacheMu sync.Mutex
+
+func load(s *skedda.Skedda, noCache bool, cacheTTL time.Duration, configPath string) (skedda.VenueList, skedda.SpaceList, error) {
 	if noCache {
 		fmt.Println("Loading venues and spaces from Skedda...")
 		return loadFromSkedda(s)
 	}
 
-	if venues, spaces, err := loadFromCache(configPath); err == nil {
-		return venues, spaces, nil
+	if data, err := readCache(configPath); err == nil && data.Version == cacheVersion && (s.Username() == "" || data.Account == s.Username()) {
+		if len(data.Stale) > 0 {
+			fmt.Println("Refreshing invalidated venues from Skedda...")
+			if err := refreshDomains(s, configPath); err != nil {
+				return nil, nil, err
+			}
+			return loadFromCache(configPath)
+		}
+
+		if cacheTTL > 0 && time.Since(data.CachedAt) > cacheTTL {
+			refreshInBackground(s, configPath)
+		}
+
+		return data.Venues, data.Spaces, nil
 	}
 
 	fmt.Println("Caching venues and spaces from Skedda...")
@@ -27,45 +54,332 @@ func load(s *skedda.Skedda, noCache bool, configPath string) (skedda.VenueList,
 		return nil, nil, err
 	}
 
-	if err := saveToCache(venues, spaces, configPath); err != nil {
+	if err := saveToCache(venues, spaces, s.Username(), configPath); err != nil {
 		fmt.Println("Failed to cache")
 	}
 
 	return venues, spaces, nil
 }
 
-func loadFromCache(configPath string) (skedda.VenueList, skedda.SpaceList, error) {
-	data := struct {
-		Venues skedda.VenueList
-		Spaces skedda.SpaceList
-	}{}
+// refreshInBackground caches the venues and spaces from Skedda again without
+// blocking the command. The refresh uses a client of its own, which never
+// prompts for a code. The expired cache stays in place if it fails.
+func refreshInBackground(s *skedda.Skedda, configPath string) {
+	client, err := s.Clone()
+	if err != nil {
+		return
+	}
+
+	backgroundRefresh.Add(1)
+	go func() {
+		defer backgroundRefresh.Done()
+
+		venues, spaces, err := loadFromSkedda(client)
+		if err != nil {
+			return
+		}
+
+		saveToCache(venues, spaces, s.Username(), configPath)
+	}()
+}
+
+// refreshDomains fetches the venues and spaces of the invalidated domains
+// again
+func refreshDomains(s *skedda.Skedda, configPath string) error {
+	cacheMu.Lock()
+	defer cacheMu.Unlock()
+
+	data, err := readCache(configPath)
+	if err != nil {
+		return err
+	}
+
+	for _, domain := range data.Stale {
+		venue, spaces, err := s.Venue(domain)
+		if err != nil {
+			return fmt.Errorf("refreshing %s: %w", domain, err)
+		}
+
+		data.Venues = append(data.Venues, venue)
+		data.Spaces = append(data.Spaces, spaces...)
+	}
+	data.Stale = nil
+
+	return writeCache(configPath, data)
+}
+
+// invalidateDomain drops the venue, spaces and bookings of a domain from the
+// cache, to be fetched again on the next load
+func invalidateDomain(configPath, domain string) error {
+	cacheMu.Lock()
+	defer cacheMu.Unlock()
+
+	data, err := readCache(configPath)
+	if err != nil {
+		return fmt.Errorf("no cache to invalidate")
+	}
+
+	var venue *skedda.Venue
+	venues := skedda.VenueList{}
+	for _, v := range data.Venues {
+		if v.Domain == domain {
+			venue = v
+			continue
+		}
+		venues = append(venues, v)
+	}
+
+	if venue == nil {
+		return fmt.Errorf("no venue cached for %s", domain)
+	}
+
+	spaces := skedda.SpaceList{}
+	for _, space := range data.Spaces {
+		if space.VenueID != venue.ID {
+			spaces = append(spaces, space)
+		}
+	}
+
+	entries := []cachedBookings{}
+	for _, entry := range data.Bookings {
+		if entry.Domain != domain {
+			entries = append(entries, entry)
+		}
+	}
+
+	data.Venues = venues
+	data.Spaces = spaces
+	data.Bookings = entries
+	data.Stale = append(data.Stale, domain)
+	return writeCache(configPath, data)
+}
+
+// clearCache removes the cache file
+func clearCache(configPath string) error {
+	cacheMu.Lock()
+	defer cacheMu.Unlock()
+
+	err := os.Remove(path.Join(configPath, "cache"))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
+
+// writeCacheStatus describes the cache
+func writeCacheStatus(w io.Writer, configPath string, cacheTTL time.Duration) error {
+	data, err := readCache(configPath)
+	if os.IsNotExist(err) {
+		fmt.Fprintln(w, "Nothing is cached")
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "File:\t%s\n", path.Join(configPath, "cache"))
+	fmt.Fprintf(tw, "Format version:\t%d", data.Version)
+	if data.Version != cacheVersion {
+		fmt.Fprintf(tw, " (outdated, will be fetched again)")
+	}
+	fmt.Fprintf(tw, "\nAccount:\t%s\n", data.Account)
+	fmt.Fprintf(tw, "Created:\t%s\n", dataAge(data.CachedAt))
+
+	switch {
+	case cacheTTL <= 0:
+		fmt.Fprintf(tw, "Expires:\tnever\n")
+	case time.Since(data.CachedAt) > cacheTTL:
+		fmt.Fprintf(tw, "Expires:\texpired, refreshed on the next use\n")
+	default:
+		fmt.Fprintf(tw, "Expires:\tin %s\n", (cacheTTL - time.Since(data.CachedAt)).Round(time.Minute))
+	}
+
+	fmt.Fprintf(tw, "Venues:\t%d\n", len(data.Venues))
+	fmt.Fprintf(tw, "Spaces:\t%d\n", len(data.Spaces))
+	for _, domain := range data.Stale {
+		fmt.Fprintf(tw, "Invalidated:\t%s\n", domain)
+	}
+	for _, entry := range data.Bookings {
+		fmt.Fprintf(tw, "Bookings:\t%s, %s - %s, %d bookings fetched %s\n", entry.Domain, entry.From.Format("2006-01-02 3:04pm"), entry.Till.Format("2006-01-02 3:04pm"), len(entry.Bookings), dataAge(entry.FetchedAt))
+	}
+
+	return tw.Flush()
+}
+
+// bookingsCacheAge is how long fetched bookings are kept in the cache
+const bookingsCacheAge = 14 * 24 * time.Hour
+
+// cacheData is the content of the cache file
+type cacheData struct {
+	Version  int
+	Account  string
+	CachedAt time.Time
+	Venues   skedda.VenueList
+	Spaces   skedda.SpaceList
+	Stale    []string
+	Bookings []cachedBookings
+}
+
+// cachedBookings are the bookings of a domain fetched for a time period
+type cachedBookings struct {
+	Domain    string
+	From      time.Time
+	Till      time.Time
+	FetchedAt time.Time
+	Bookings  []*skedda.Booking
+}
+
+func readCache(configPath string) (*cacheData, error) {
+	data := &cacheData{}
 
 	cacheFilename := path.Join(configPath, "cache")
 	buf, err := ioutil.ReadFile(cacheFilename)
 	if err != nil {
-		return nil, nil, err
+		return nil, err
 	}
 
-	if err := json.Unmarshal(buf, &data); err != nil {
-		return nil, nil, err
+	if err := json.Unmarshal(buf, data); err != nil {
+		return nil, err
 	}
 
-	return data.Venues, data.Spaces, nil
+	return data, nil
 }
 
-func saveToCache(venues skedda.VenueList, spaces skedda.SpaceList, configPath string) error {
-	data := struct {
-		Venues skedda.VenueList
-		Spaces skedda.SpaceList
-	}{venues, spaces}
-
+func writeCache(configPath string, data *cacheData) error {
 	buf, err := json.Marshal(data)
 	if err != nil {
 		panic(err)
 	}
 
+	if err := os.MkdirAll(configPath, 0700); err != nil {
+		return err
+	}
+
+	// Write aside and rename so that readers never see a partial file
 	cacheFilename := path.Join(configPath, "cache")
-	return ioutil.WriteFile(cacheFilename, buf, 0600)
+	if err := ioutil.WriteFile(cacheFilename+".tmp", buf, 0600); err != nil {
+		return err
+	}
+	return os.Rename(cacheFilename+".tmp", cacheFilename)
+}
+
+func loadFromCache(configPath string) (skedda.VenueList, skedda.SpaceList, error) {
+	data, err := readCache(configPath)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return data.Venues, data.Spaces, nil
+}
+
+// loadOffline loads the venues and spaces from the cache only, along with
+// when they were cached
+func loadOffline(configPath string) (skedda.VenueList, skedda.SpaceList, time.Time, error) {
+	data, err := readCache(configPath)
+	if err != nil {
+		return nil, nil, time.Time{}, fmt.Errorf("no venues and spaces cached, try using `skedda cache`")
+	}
+
+	return data.Venues, data.Spaces, data.CachedAt, nil
+}
+
+func saveToCache(venues skedda.VenueList, spaces skedda.SpaceList, account, configPath string) error {
+	cacheMu.Lock()
+	defer cacheMu.Unlock()
+
+	data, err := readCache(configPath)
+	if err != nil || data.Version != cacheVersion || data.Account != account {
+		data = &cacheData{}
+	}
+
+	data.Version = cacheVersion
+	data.Account = account
+	data.CachedAt = time.Now()
+	data.Venues = venues
+	data.Spaces = spaces
+	data.Stale = nil
+	return writeCache(configPath, data)
+}
+
+// saveBookingsToCache stores the bookings of the venues fetched for a time
+// period in a single write, replacing the ones fetched earlier for the same or
+// a shorter period and dropping the stale ones
+func saveBookingsToCache(configPath string, from, till time.Time, venueBookings map[*skedda.Venue][]*skedda.Booking) error {
+	cacheMu.Lock()
+	defer cacheMu.Unlock()
+
+	data, err := readCache(configPath)
+	if err != nil {
+		data = &cacheData{}
+	}
+
+	domains := map[string]bool{}
+	for venue := range venueBookings {
+		domains[venue.Domain] = true
+	}
+
+	now := time.Now()
+	entries := []cachedBookings{}
+	for _, entry := range data.Bookings {
+		if now.Sub(entry.FetchedAt) > bookingsCacheAge {
+			continue
+		}
+
+		if domains[entry.Domain] && !entry.From.Before(from) && !entry.Till.After(till) {
+			continue
+		}
+
+		entries = append(entries, entry)
+	}
+
+	for venue, bookings := range venueBookings {
+		entries = append(entries, cachedBookings{venue.Domain, from, till, now, bookings})
+	}
+
+	data.Bookings = entries
+	return writeCache(configPath, data)
+}
+
+// loadBookingsFromCache returns the bookings of a domain during a time period
+// from the latest fetch covering it, along with when they were fetched
+func loadBookingsFromCache(configPath, domain string, from, till time.Time) ([]*skedda.Booking, time.Time, error) {
+	data, err := readCache(configPath)
+	if err != nil {
+		return nil, time.Time{}, fmt.Errorf("no bookings cached")
+	}
+
+	var latest *cachedBookings
+	for i, entry := range data.Bookings {
+		if entry.Domain != domain || entry.From.After(from) || entry.Till.Before(till) {
+			continue
+		}
+
+		if latest == nil || entry.FetchedAt.After(latest.FetchedAt) {
+			latest = &data.Bookings[i]
+		}
+	}
+
+	if latest == nil {
+		return nil, time.Time{}, fmt.Errorf("no bookings of %s cached between %s and %s", domain, from.Format("2006-01-02 3:04pm"), till.Format("2006-01-02 3:04pm"))
+	}
+
+	bookings := []*skedda.Booking{}
+	for _, booking := range latest.Bookings {
+		if len(booking.Occurrences(from, till)) > 0 {
+			bookings = append(bookings, booking)
+		}
+	}
+
+	return bookings, latest.FetchedAt, nil
+}
+
+// dataAge describes how old data fetched at t is
+func dataAge(t time.Time) string {
+	if t.IsZero() {
+		return "at an unknown time"
+	}
+
+	return fmt.Sprintf("%s ago, at %s", time.Since(t).Round(time.Minute), t.Local().Format("Mon 02 Jan 3:04pm"))
 }
 
 func loadFromSkedda(s *skedda.Skedda) (skedda.VenueList, skedda.SpaceList, error) {
@@ -105,7 +419,7 @@ func loadFromSkedda(s *skedda.Skedda) (skedda.VenueList, skedda.SpaceList, error
 
 	for result := range resultCh {
 		if result.Error != nil {
-			return nil, nil, err
+			return nil, nil, result.Error
 		}
 
 		venues = append(venues, result.Venue)
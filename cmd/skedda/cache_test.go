@@ -0,0 +1,316 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alyyousuf7/skedda"
+)
+
+func TestSaveBookingsToCache(t *testing.T) {
+	dir, err := ioutil.TempDir("", "skedda")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	at := func(hour int) time.Time {
+		return time.Date(2020, time.March, 4, hour, 0, 0, 0, time.UTC)
+	}
+	booking := func(id, hour int) *skedda.Booking {
+		b := &skedda.Booking{ID: id, SpaceIDs: []int{id}}
+		b.StartTime.Time = at(hour)
+		b.EndTime.Time = at(hour + 1)
+		return b
+	}
+
+	london := &skedda.Venue{ID: 1, Domain: "acme"}
+	lisbon := &skedda.Venue{ID: 2, Domain: "acme-lisbon"}
+	venues := skedda.VenueList{london, lisbon}
+	spaces := skedda.SpaceList{{ID: 10, VenueID: 1}, {ID: 20, VenueID: 2}}
+
+	// The second fetch of London covers the first one
+	fetches := []struct {
+		From, Till    time.Time
+		VenueBookings map[*skedda.Venue][]*skedda.Booking
+	}{
+		{at(9), at(12), map[*skedda.Venue][]*skedda.Booking{london: {booking(10, 9)}, lisbon: {booking(20, 10)}}},
+		{at(8), at(18), map[*skedda.Venue][]*skedda.Booking{london: {booking(10, 9), booking(11, 14)}}},
+	}
+
+	for _, fetch := range fetches {
+		if err := saveBookingsToCache(dir, fetch.From, fetch.Till, fetch.VenueBookings); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	data, err := readCache(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(data.Bookings) != 2 {
+		t.Errorf("Expected 2 cached fetches but got %d", len(data.Bookings))
+	}
+
+	testCases := []struct {
+		From, Till time.Time
+		Expected   map[string]int
+		Valid      bool
+	}{
+		{at(9), at(12), map[string]int{"acme": 1, "acme-lisbon": 1}, true},
+		{at(13), at(15), map[string]int{"acme": 1, "acme-lisbon": 0}, false},
+	}
+
+	for i, testCase := range testCases {
+		venueBookings, _, err := cachedVenueBookings(dir, venues, spaces, testCase.From, testCase.Till)
+		if (err == nil) != testCase.Valid {
+			t.Errorf("Expected valid: %v but got %v: Test case %d", testCase.Valid, err, i)
+			continue
+		}
+
+		if err != nil {
+			continue
+		}
+
+		for venue, bookings := range venueBookings {
+			if len(bookings) != testCase.Expected[venue.Domain] {
+				t.Errorf("Expected %d bookings of %s but got %d: Test case %d", testCase.Expected[venue.Domain], venue.Domain, len(bookings), i)
+			}
+		}
+	}
+
+	// Only London is cached for the afternoon
+	bookings, _, err := loadBookingsFromCache(dir, "acme", at(13), at(15))
+	if err != nil || len(bookings) != 1 || bookings[0].ID != 11 {
+		t.Errorf("Expected booking 11 but got %v, %v", bookings, err)
+	}
+}
+
+func TestLoadOffline(t *testing.T) {
+	dir, err := ioutil.TempDir("", "skedda")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// Nothing cached yet
+	if _, _, _, err := loadOffline(dir); err == nil {
+		t.Errorf("Expected an error without a cache")
+	}
+
+	before := time.Now()
+	if err := saveToCache(testVenues, testSpaces, "jane@acme.com", dir); err != nil {
+		t.Fatal(err)
+	}
+
+	venues, spaces, cachedAt, err := loadOffline(dir)
+	if err != nil || len(venues) != len(testVenues) || len(spaces) != len(testSpaces) {
+		t.Errorf("Expected the cached venues and spaces but got %v, %v, %v", venues, spaces, err)
+	}
+
+	if cachedAt.Before(before) {
+		t.Errorf("Expected the time of the cache but got %v", cachedAt)
+	}
+}
+
+// cacheSkedda returns a client serving the venues of acme and acme-lisbon,
+// recording the venues fetched
+func cacheSkedda(t *testing.T, fetched *[]string) *skedda.Skedda {
+	return fakeSkedda(t, func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/account/login":
+			w.Header().Set("Location", "https://acme.skedda.com/booking")
+			w.WriteHeader(http.StatusFound)
+		case r.Method == "POST" && r.URL.Path == "/webs":
+			w.Write([]byte(`{"web":{"otherSubdomains":{"acme":"London","acme-lisbon":"Lisbon"}}}`))
+		case r.Method == "GET" && r.URL.Path == "/webs":
+			domain := strings.TrimSuffix(r.URL.Hostname(), ".skedda.com")
+			*fetched = append(*fetched, domain)
+			if domain == "acme" {
+				w.Write([]byte(`{"venue":[{"id":1,"name":"London Office","subdomain":"acme"}],"spaces":[{"id":10,"name":"Thames","venue":1}]}`))
+			} else {
+				w.Write([]byte(`{"venue":[{"id":2,"name":"Lisbon Office","subdomain":"acme-lisbon"}],"spaces":[{"id":20,"name":"Tagus","venue":2}]}`))
+			}
+		default:
+			t.Errorf("Unexpected request %s %s", r.Method, r.URL)
+		}
+	})
+}
+
+func TestLoad(t *testing.T) {
+	cached := skedda.VenueList{{ID: 1, Name: "Cached Office", Domain: "acme"}}
+
+	testCases := []struct {
+		Version  int
+		Account  string
+		Age      time.Duration
+		Fetched  bool
+		Returned string
+	}{
+		// Fresh
+		{cacheVersion, "jane@acme.com", time.Hour, false, "Cached Office"},
+		// Expired, answered from the cache and refreshed in the background
+		{cacheVersion, "jane@acme.com", 48 * time.Hour, true, "Cached Office"},
+		// Another format
+		{cacheVersion - 1, "jane@acme.com", time.Hour, true, "London Office"},
+		// Another account
+		{cacheVersion, "john@acme.com", time.Hour, true, "London Office"},
+	}
+
+	for i, testCase := range testCases {
+		dir, err := ioutil.TempDir("", "skedda")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer os.RemoveAll(dir)
+
+		data := &cacheData{Version: testCase.Version, Account: testCase.Account, CachedAt: time.Now().Add(-testCase.Age), Venues: cached}
+		if err := writeCache(dir, data); err != nil {
+			t.Fatal(err)
+		}
+
+		fetched := []string{}
+		venues, _, err := load(cacheSkedda(t, &fetched), false, 24*time.Hour, dir)
+		backgroundRefresh.Wait()
+		if err != nil {
+			t.Errorf("Expected no error but got %v: Test case %d", err, i)
+			continue
+		}
+
+		names := strings.Join(venues.Map(func(i int, v skedda.Venue) string {
+			return v.Name
+		}), ", ")
+		if !strings.Contains(names, testCase.Returned) {
+			t.Errorf("Expected %s but got %s: Test case %d", testCase.Returned, names, i)
+		}
+
+		if (len(fetched) > 0) != testCase.Fetched {
+			t.Errorf("Expected fetched: %v but got %v: Test case %d", testCase.Fetched, fetched, i)
+		}
+
+		// Whatever was fetched replaces the cache
+		data, err = readCache(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if testCase.Fetched && (data.Version != cacheVersion || data.Account != "jane@acme.com" || len(data.Venues) != 2 || time.Since(data.CachedAt) > time.Minute) {
+			t.Errorf("Expected the fetched venues to be cached but got %+v: Test case %d", data, i)
+		}
+	}
+}
+
+func TestInvalidateDomain(t *testing.T) {
+	dir, err := ioutil.TempDir("", "skedda")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	data := &cacheData{
+		Version:  cacheVersion,
+		Account:  "jane@acme.com",
+		CachedAt: time.Now(),
+		Venues:   skedda.VenueList{{ID: 1, Name: "London Office", Domain: "acme"}, {ID: 2, Name: "Lisbon Office", Domain: "acme-lisbon"}},
+		Spaces:   skedda.SpaceList{{ID: 10, Name: "Thames", VenueID: 1}, {ID: 20, Name: "Tagus", VenueID: 2}},
+		Bookings: []cachedBookings{{Domain: "acme", FetchedAt: time.Now()}, {Domain: "acme-lisbon", FetchedAt: time.Now()}},
+	}
+	if err := writeCache(dir, data); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := invalidateDomain(dir, "acme-paris"); err == nil {
+		t.Errorf("Expected an error for a domain which is not cached")
+	}
+
+	if err := invalidateDomain(dir, "acme-lisbon"); err != nil {
+		t.Fatal(err)
+	}
+
+	// The other domains stay in place
+	data, err = readCache(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(data.Venues) != 1 || data.Venues[0].Domain != "acme" || len(data.Spaces) != 1 || data.Spaces[0].ID != 10 || len(data.Bookings) != 1 || data.Bookings[0].Domain != "acme" {
+		t.Errorf("Expected only acme to be left but got %+v", data)
+	}
+
+	if len(data.Stale) != 1 || data.Stale[0] != "acme-lisbon" {
+		t.Errorf("Expected acme-lisbon to be invalidated but got %v", data.Stale)
+	}
+
+	// Only the invalidated domain is fetched again
+	fetched := []string{}
+	venues, spaces, err := load(cacheSkedda(t, &fetched), false, 24*time.Hour, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(fetched) != 1 || fetched[0] != "acme-lisbon" || len(venues) != 2 || len(spaces) != 2 {
+		t.Errorf("Expected acme-lisbon to be fetched again but got %v, %v, %v", fetched, venues, spaces)
+	}
+
+	if data, err := readCache(dir); err != nil || len(data.Stale) != 0 {
+		t.Errorf("Expected nothing invalidated but got %v, %v", data, err)
+	}
+}
+
+func TestWriteCacheStatus(t *testing.T) {
+	dir, err := ioutil.TempDir("", "skedda")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	var buf bytes.Buffer
+	if err := writeCacheStatus(&buf, dir, time.Hour); err != nil || !strings.Contains(buf.String(), "Nothing is cached") {
+		t.Errorf("Expected nothing cached but got %q, %v", buf.String(), err)
+	}
+
+	testCases := []struct {
+		Data     cacheData
+		TTL      time.Duration
+		Expected []string
+	}{
+		{
+			cacheData{Version: cacheVersion, Account: "jane@acme.com", CachedAt: time.Now().Add(-time.Hour)},
+			0,
+			[]string{"Account:         jane@acme.com", "Expires:         never"},
+		},
+		{
+			cacheData{Version: cacheVersion, CachedAt: time.Now().Add(-time.Hour)},
+			3 * time.Hour,
+			[]string{"Expires:         in 2h0m0s"},
+		},
+		{
+			cacheData{Version: cacheVersion - 1, CachedAt: time.Now().Add(-48 * time.Hour), Stale: []string{"acme-lisbon"}, Venues: skedda.VenueList{{ID: 1}}},
+			24 * time.Hour,
+			[]string{"(outdated, will be fetched again)", "expired, refreshed on the next use", "Invalidated:     acme-lisbon", "Venues:          1"},
+		},
+	}
+
+	for i, testCase := range testCases {
+		if err := writeCache(dir, &testCase.Data); err != nil {
+			t.Fatal(err)
+		}
+
+		buf.Reset()
+		if err := writeCacheStatus(&buf, dir, testCase.TTL); err != nil {
+			t.Fatal(err)
+		}
+
+		for _, expected := range testCase.Expected {
+			if !strings.Contains(buf.String(), expected) {
+				t.Errorf("Expected %q in %q: Test case %d", expected, buf.String(), i)
+			}
+		}
+	}
+}
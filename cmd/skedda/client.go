@@ -0,0 +1,390 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"os/exec"
+	"path"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/alyyousuf7/skedda"
+	"golang.org/x/crypto/ssh/terminal"
+)
+
+// credentialOptions are the credentials given on the command line
+type credentialOptions struct {
+	Username      string
+	Password      string
+	PasswordStdin bool
+}
+
+// Credentials to sign in with, along with where they come from
+type Credentials struct {
+	Username       string
+	Password       string
+	UsernameSource string
+	PasswordSource string
+}
+
+// resolveCredentials looks up the username and the password from the first
+// source providing them, in order:
+//  1. --username and --password
+//  2. SKEDDA_USERNAME and SKEDDA_PASSWORD
+//  3. --password-stdin, for the password
+//  4. credential_command of the config, for the password
+//  5. the browser session imported by `skedda login`, instead of the password
+//  6. the config and its credential store
+func resolveCredentials(opts credentialOptions, configPath string) (Credentials, error) {
+	creds := Credentials{}
+	config, configErr := readConfig(configPath)
+
+	switch {
+	case opts.Username != "":
+		creds.Username, creds.UsernameSource = opts.Username, "--username"
+	case os.Getenv("SKEDDA_USERNAME") != "":
+		creds.Username, creds.UsernameSource = os.Getenv("SKEDDA_USERNAME"), "SKEDDA_USERNAME"
+	case config.Username != "":
+		creds.Username, creds.UsernameSource = config.Username, "config"
+	default:
+		return Credentials{}, skedda.ErrCredsMissing
+	}
+
+	switch {
+	case opts.Password != "":
+		creds.Password, creds.PasswordSource = opts.Password, "--password"
+	case os.Getenv("SKEDDA_PASSWORD") != "":
+		creds.Password, creds.PasswordSource = os.Getenv("SKEDDA_PASSWORD"), "SKEDDA_PASSWORD"
+	case opts.PasswordStdin:
+		password, err := readPasswordStdin()
+		if err != nil {
+			return Credentials{}, err
+		}
+		creds.Password, creds.PasswordSource = password, "--password-stdin"
+	case config.CredentialCommand != "":
+		password, err := runCredentialCommand(config.CredentialCommand)
+		if err != nil {
+			return Credentials{}, err
+		}
+		creds.Password, creds.PasswordSource = password, "credential_command"
+	case hasSession(configPath):
+		creds.PasswordSource = sessionSource
+	case configErr == nil:
+		config, err := loadConfig(configPath)
+		if errors.Is(err, os.ErrNotExist) {
+			return Credentials{}, skedda.ErrCredsMissing
+		} else if err != nil {
+			return Credentials{}, err
+		}
+		creds.Password, creds.PasswordSource = config.Password, "config"
+	}
+
+	if creds.Password == "" && creds.PasswordSource != sessionSource {
+		return Credentials{}, skedda.ErrCredsMissing
+	}
+
+	return creds, nil
+}
+
+// usesSession tells whether resolveCredentials signs in with the browser
+// session imported by `skedda login`, without reading the password
+func usesSession(opts credentialOptions, configPath string) bool {
+	config, _ := readConfig(configPath)
+	return opts.Password == "" && os.Getenv("SKEDDA_PASSWORD") == "" && !opts.PasswordStdin && config.CredentialCommand == "" && hasSession(configPath)
+}
+
+// readPasswordStdin reads the password from the first line of stdin
+func readPasswordStdin() (string, error) {
+	line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && line == "" {
+		return "", fmt.Errorf("reading the password from stdin: %w", err)
+	}
+
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+// runCredentialCommand runs the command with the shell and returns the
+// password it prints
+func runCredentialCommand(command string) (string, error) {
+	cmd := exec.Command("sh", "-c", command)
+	cmd.Stdin = os.Stdin
+	cmd.Stderr = os.Stderr
+
+	out, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("running credential_command: %w", err)
+	}
+
+	return strings.TrimRight(string(out), "\r\n"), nil
+}
+
+// newClient returns a client signing in with the credentials resolved from
+// the command line, the environment or the config
+func newClient(opts credentialOptions, configPath string) (*skedda.Skedda, error) {
+	creds, err := resolveCredentials(opts, configPath)
+	if err != nil {
+		return nil, err
+	}
+
+	return clientFor(creds, configPath)
+}
+
+// clientFor returns a client signing in with the credentials
+func clientFor(creds Credentials, configPath string) (*skedda.Skedda, error) {
+	if creds.PasswordSource == sessionSource {
+		cookies, err := loadSession(configPath)
+		if err != nil {
+			return nil, err
+		}
+
+		return skedda.NewWithSession(creds.Username, cookies)
+	}
+
+	s, err := skedda.NewWithCreds(creds.Username, creds.Password)
+	if err != nil {
+		return nil, err
+	}
+
+	s.SetMFAHandler(mfaHandler(configPath))
+	return s, nil
+}
+
+// verifyCredentials signs in with the credentials, shows the primary domain
+// and the venues found, and caches them
+func verifyCredentials(configPath, username, password string) error {
+	s, err := skedda.NewWithCreds(username, password)
+	if err != nil {
+		return err
+	}
+	s.SetMFAHandler(mfaHandler(configPath))
+
+	fmt.Println("Signing in to Skedda...")
+	if err := s.Auth(); err != nil {
+		return err
+	}
+
+	primaryDomain, err := s.PrimaryDomain()
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Signed in to %s.skedda.com\n", primaryDomain)
+
+	venues, spaces, err := loadFromSkedda(s)
+	if err != nil {
+		return err
+	}
+
+	for _, venue := range venues {
+		count := 0
+		for _, space := range spaces {
+			if space.VenueID == venue.ID {
+				count++
+			}
+		}
+		fmt.Printf("\t%s (%d spaces)\n", venue.Name, count)
+	}
+
+	if err := saveToCache(venues, spaces, username, configPath); err != nil {
+		fmt.Println("Failed to cache")
+	}
+
+	return nil
+}
+
+// mfaHandler answers the second factor challenge with a code derived from the
+// TOTP secret of SKEDDA_TOTP_SECRET or the credential store, or prompts for it
+// when running in a terminal
+func mfaHandler(configPath string) skedda.MFAHandler {
+	return func(challenge skedda.MFAChallenge) (string, error) {
+		if secret := os.Getenv("SKEDDA_TOTP_SECRET"); secret != "" {
+			return skedda.TOTP(secret, time.Now())
+		}
+
+		if v, err := loadVault(configPath); err == nil && len(v.TOTPCiphertext) > 0 {
+			_, key, err := unlockVault(configPath, v)
+			if err != nil {
+				return "", err
+			}
+
+			secret, err := v.openTOTP(key)
+			if err != nil {
+				return "", err
+			}
+
+			return skedda.TOTP(secret, time.Now())
+		}
+
+		if !terminal.IsTerminal(int(syscall.Stdin)) {
+			return "", fmt.Errorf("%s, set SKEDDA_TOTP_SECRET or use `skedda configure --totp`", skedda.ErrMFARequired)
+		}
+
+		if challenge.Detail != "" {
+			fmt.Println(challenge.Detail)
+		}
+		return readInput("Authentication code", "", false)
+	}
+}
+
+// sessionSource is the source of the credentials signing in with an imported
+// browser session
+const sessionSource = "session"
+
+func hasSession(configPath string) bool {
+	_, err := os.Stat(path.Join(configPath, "cookies"))
+	return err == nil
+}
+
+// sealedSession is the browser session imported by `skedda login`, sealed
+// with the key of the credential store when there is one
+type sealedSession struct {
+	Nonce      []byte
+	Ciphertext []byte
+}
+
+// loadSession loads the cookies of the browser session imported by
+// `skedda login`, unlocking the credential store if they are sealed
+func loadSession(configPath string) ([]*http.Cookie, error) {
+	raw, err := ioutil.ReadFile(path.Join(configPath, "cookies"))
+	if err != nil {
+		return nil, err
+	}
+
+	// Sealed sessions are an object, the others the list of cookies
+	sealed := sealedSession{}
+	if json.Unmarshal(raw, &sealed) == nil {
+		v, err := loadVault(configPath)
+		if err != nil {
+			return nil, fmt.Errorf("the browser session is sealed but no credentials are stored, try using `skedda login` again")
+		}
+
+		_, key, err := unlockVault(configPath, v)
+		if err != nil {
+			return nil, err
+		}
+
+		aead, err := newAEAD(key)
+		if err != nil {
+			return nil, err
+		}
+
+		raw, err = aead.Open(nil, sealed.Nonce, sealed.Ciphertext, nil)
+		if err != nil {
+			return nil, fmt.Errorf("the browser session was sealed with other credentials, try using `skedda login` again")
+		}
+	}
+
+	cookies := []*http.Cookie{}
+	if err := json.Unmarshal(raw, &cookies); err != nil {
+		return nil, err
+	}
+
+	return cookies, nil
+}
+
+// logoutSession signs out of the browser session imported by `skedda login`
+func logoutSession(configPath string) error {
+	cookies, err := loadSession(configPath)
+	if err != nil {
+		return err
+	}
+
+	config, _ := readConfig(configPath)
+	s, err := skedda.NewWithSession(config.Username, cookies)
+	if err != nil {
+		return err
+	}
+
+	return s.Logout()
+}
+
+// logoutPassword signs out of Skedda with the password, for accounts without
+// a browser session
+func logoutPassword(creds Credentials, configPath string) error {
+	s, err := clientFor(creds, configPath)
+	if err != nil {
+		return err
+	}
+
+	if err := s.Auth(); err != nil {
+		return err
+	}
+
+	return s.Logout()
+}
+
+// saveSession saves the cookies of the browser session, sealed with the key
+// of the credential store when there is one
+func saveSession(configPath string, cookies []*http.Cookie) error {
+	var key []byte
+	if v, err := loadVault(configPath); err == nil {
+		_, key, err = unlockVault(configPath, v)
+		if err != nil {
+			return err
+		}
+	}
+
+	return writeSession(configPath, cookies, key)
+}
+
+// writeSession writes the cookies of the browser session, sealed with the key
+// unless it is nil
+func writeSession(configPath string, cookies []*http.Cookie, key []byte) error {
+	raw, err := json.Marshal(cookies)
+	if err != nil {
+		return err
+	}
+
+	if key != nil {
+		aead, err := newAEAD(key)
+		if err != nil {
+			return err
+		}
+
+		sealed := sealedSession{Nonce: make([]byte, aead.NonceSize())}
+		if _, err := rand.Read(sealed.Nonce); err != nil {
+			return err
+		}
+		sealed.Ciphertext = aead.Seal(nil, sealed.Nonce, raw, nil)
+
+		raw, err = json.Marshal(sealed)
+		if err != nil {
+			return err
+		}
+	}
+
+	if err := os.MkdirAll(configPath, 0700); err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(path.Join(configPath, "cookies"), raw, 0600)
+}
+
+// readSessionCookies reads the cookies of a browser session from a file, or
+// stdin for -, either exported in the cookies.txt format or as a raw Cookie
+// header
+func readSessionCookies(filename string) ([]*http.Cookie, error) {
+	var raw []byte
+	var err error
+	if filename == "-" {
+		raw, err = ioutil.ReadAll(os.Stdin)
+	} else {
+		raw, err = ioutil.ReadFile(filename)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if strings.Contains(string(raw), "\t") {
+		return skedda.ParseCookiesTxt(bytes.NewReader(raw))
+	}
+
+	return skedda.ParseCookieHeader(string(raw), "skedda.com"), nil
+}
@@ -0,0 +1,196 @@
+package main
+
+import (
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestResolveCredentials(t *testing.T) {
+	dir, err := ioutil.TempDir("", "skedda")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// The environment of the caller would take precedence over the config
+	for _, key := range []string{"SKEDDA_USERNAME", "SKEDDA_PASSWORD", "SKEDDA_PROFILE"} {
+		if value, ok := os.LookupEnv(key); ok {
+			defer os.Setenv(key, value)
+		}
+		os.Unsetenv(key)
+	}
+
+	config := `{"Username":"config-user","credential_command":"echo command-password"}`
+	if err := ioutil.WriteFile(path.Join(dir, "config"), []byte(config), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := []struct {
+		Opts     credentialOptions
+		Env      map[string]string
+		Expected Credentials
+	}{
+		{
+			credentialOptions{},
+			nil,
+			Credentials{"config-user", "command-password", "config", "credential_command"},
+		},
+		{
+			credentialOptions{},
+			map[string]string{"SKEDDA_USERNAME": "env-user", "SKEDDA_PASSWORD": "env-password"},
+			Credentials{"env-user", "env-password", "SKEDDA_USERNAME", "SKEDDA_PASSWORD"},
+		},
+		{
+			credentialOptions{Username: "flag-user", Password: "flag-password"},
+			map[string]string{"SKEDDA_USERNAME": "env-user", "SKEDDA_PASSWORD": "env-password"},
+			Credentials{"flag-user", "flag-password", "--username", "--password"},
+		},
+		{
+			credentialOptions{Username: "flag-user"},
+			nil,
+			Credentials{"flag-user", "command-password", "--username", "credential_command"},
+		},
+	}
+
+	for i, testCase := range testCases {
+		for key, value := range testCase.Env {
+			os.Setenv(key, value)
+		}
+
+		creds, err := resolveCredentials(testCase.Opts, dir)
+		if err != nil {
+			t.Errorf("Expected %v but got error %s: Test case %d", testCase.Expected, err, i)
+		} else if creds != testCase.Expected {
+			t.Errorf("Expected %v but got %v: Test case %d", testCase.Expected, creds, i)
+		}
+
+		for key := range testCase.Env {
+			os.Unsetenv(key)
+		}
+	}
+}
+
+func TestSessionSealed(t *testing.T) {
+	dir, err := ioutil.TempDir("", "skedda")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	os.Setenv(passphraseEnv, "correct horse")
+	defer os.Unsetenv(passphraseEnv)
+
+	cookies := []*http.Cookie{{Name: "session", Value: "s3cr3t-cookie", Domain: "skedda.com"}}
+
+	testCases := []struct {
+		Vault  bool
+		Sealed bool
+	}{
+		{false, false},
+		{true, true},
+	}
+
+	for i, testCase := range testCases {
+		if testCase.Vault {
+			if err := saveCredentials(dir, Config{Username: "jane", Password: "s3cr3t"}); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		if err := saveSession(dir, cookies); err != nil {
+			t.Fatal(err)
+		}
+
+		raw, err := ioutil.ReadFile(path.Join(dir, "cookies"))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if sealed := !strings.Contains(string(raw), "s3cr3t-cookie"); sealed != testCase.Sealed {
+			t.Errorf("Expected sealed: %v but got %s: Test case %d", testCase.Sealed, raw, i)
+		}
+
+		loaded, err := loadSession(dir)
+		if err != nil || len(loaded) != 1 || loaded[0].Value != "s3cr3t-cookie" {
+			t.Errorf("Expected %v but got %v, %v: Test case %d", cookies, loaded, err, i)
+		}
+	}
+
+	// The session is sealed again when the passphrase changes, opening it
+	// with the unlocked key of the previous one
+	v, err := loadVault(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	key, _ := v.key("correct horse")
+	if err := unlock(dir, key, time.Hour); err != nil {
+		t.Fatal(err)
+	}
+
+	os.Setenv(passphraseEnv, "battery staple")
+	if err := saveCredentials(dir, Config{Username: "jane", Password: "s3cr3t"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if loaded, err := loadSession(dir); err != nil || len(loaded) != 1 || loaded[0].Value != "s3cr3t-cookie" {
+		t.Errorf("Expected %v but got %v, %v", cookies, loaded, err)
+	}
+}
+
+func TestUsesSession(t *testing.T) {
+	dir, err := ioutil.TempDir("", "skedda")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	if value, ok := os.LookupEnv("SKEDDA_PASSWORD"); ok {
+		defer os.Setenv("SKEDDA_PASSWORD", value)
+	}
+	defer os.Unsetenv("SKEDDA_PASSWORD")
+	os.Unsetenv("SKEDDA_PASSWORD")
+
+	if err := ioutil.WriteFile(path.Join(dir, "config"), []byte(`{"Username":"jane"}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	if usesSession(credentialOptions{}, dir) {
+		t.Errorf("Expected no session before `skedda login`")
+	}
+
+	if err := writeSession(dir, []*http.Cookie{{Name: "session", Value: "abc"}}, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := []struct {
+		Opts credentialOptions
+		Env  string
+	}{
+		{credentialOptions{}, ""},
+		{credentialOptions{Password: "flag-password"}, ""},
+		{credentialOptions{}, "env-password"},
+	}
+
+	// It agrees with the source resolveCredentials picks
+	for i, testCase := range testCases {
+		os.Unsetenv("SKEDDA_PASSWORD")
+		if testCase.Env != "" {
+			os.Setenv("SKEDDA_PASSWORD", testCase.Env)
+		}
+
+		creds, err := resolveCredentials(testCase.Opts, dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if expected := creds.PasswordSource == sessionSource; usesSession(testCase.Opts, dir) != expected {
+			t.Errorf("Expected %v for %s: Test case %d", expected, creds.PasswordSource, i)
+		}
+	}
+}
@@ -3,23 +3,60 @@ package main
 import (
 	"bufio"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"io/ioutil"
+	"net/http"
 	"os"
 	"path"
 	"strings"
 	"syscall"
 
+	"github.com/alyyousuf7/skedda"
 	"golang.org/x/crypto/ssh/terminal"
 )
 
-// Config contains configurations for the CLI
+// Config contains configurations for the CLI. The password is kept in the
+// encrypted credential store, it is only set in configs written by older
+// versions.
 type Config struct {
 	Username string
-	Password string
+	Password string `json:",omitempty"`
+
+	// CredentialCommand prints the password, e.g. from a password manager
+	CredentialCommand string `json:"credential_command,omitempty"`
 }
 
+// loadConfig loads the configuration along with the password decrypted from
+// the credential store
 func loadConfig(configPath string) (Config, error) {
+	config, err := readConfig(configPath)
+	if err != nil {
+		return Config{}, err
+	}
+
+	if config.Password != "" {
+		migrateConfig(configPath, config)
+		return config, nil
+	}
+
+	v, err := loadVault(configPath)
+	if err != nil {
+		return config, err
+	}
+
+	password, _, err := unlockVault(configPath, v)
+	if err != nil {
+		return Config{}, err
+	}
+
+	config.Password = password
+	return config, nil
+}
+
+// readConfig loads the configuration as stored, without the password
+func readConfig(configPath string) (Config, error) {
 	raw, err := ioutil.ReadFile(path.Join(configPath, "config"))
 	if err != nil {
 		return Config{}, err
@@ -39,13 +76,121 @@ func saveConfig(configPath string, config Config) error {
 		return err
 	}
 
-	if err := os.MkdirAll(configPath, 0777); err != nil {
+	if err := os.MkdirAll(configPath, 0700); err != nil {
+		return err
+	}
+
+	// Tighten the permissions given by older versions
+	if err := os.Chmod(configPath, 0700); err != nil {
+		return err
+	}
+
+	filename := path.Join(configPath, "config")
+	if err := ioutil.WriteFile(filename, raw, 0600); err != nil {
+		return err
+	}
+	return os.Chmod(filename, 0600)
+}
+
+// forgetCredentials deletes the credential store and the username and
+// password from the configuration, keeping the rest of it
+func forgetCredentials(configPath string) error {
+	if err := os.Remove(path.Join(configPath, "credentials")); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	filename := path.Join(configPath, "config")
+	raw, err := ioutil.ReadFile(filename)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	// The fields are kept as they are, including those of other versions
+	fields := map[string]json.RawMessage{}
+	if err := json.Unmarshal(raw, &fields); err != nil {
+		return err
+	}
+
+	for name := range fields {
+		if strings.EqualFold(name, "Username") || strings.EqualFold(name, "Password") {
+			delete(fields, name)
+		}
+	}
+
+	if len(fields) == 0 {
+		return os.Remove(filename)
+	}
+
+	raw, err = json.Marshal(fields)
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(filename, raw, 0600)
+}
+
+// saveCredentials stores the password in the credential store, encrypted with
+// a new passphrase, and the rest of the configuration without it
+func saveCredentials(configPath string, config Config) error {
+	// The browser session is sealed again with the new key
+	var cookies []*http.Cookie
+	if hasSession(configPath) {
+		var err error
+		if cookies, err = loadSession(configPath); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: the browser session cannot be kept (%s)\n", err)
+		}
+	}
+
+	passphrase, err := readNewPassphrase()
+	if err != nil {
 		return err
 	}
-	return ioutil.WriteFile(path.Join(configPath, "config"), raw, 0777)
+
+	v, err := sealPassword(passphrase, config.Password)
+	if err != nil {
+		return err
+	}
+
+	if err := saveVault(configPath, v); err != nil {
+		return err
+	}
+
+	if cookies != nil {
+		key, err := v.key(passphrase)
+		if err != nil {
+			return err
+		}
+
+		if err := writeSession(configPath, cookies, key); err != nil {
+			return err
+		}
+	}
+
+	// Sessions unlocked with the previous passphrase cannot open the vault
+	lock(configPath)
+
+	config.Password = ""
+	return saveConfig(configPath, config)
+}
+
+// migrateConfig moves a password stored in plain text by older versions into
+// the credential store. The config is left as is if it cannot be done now, or
+// without a terminal to choose the passphrase in unless SKEDDA_PASSPHRASE is
+// set.
+func migrateConfig(configPath string, config Config) {
+	if os.Getenv(passphraseEnv) == "" && !terminal.IsTerminal(int(syscall.Stdin)) {
+		return
+	}
+
+	fmt.Fprintln(os.Stderr, "Your password is stored in plain text, choose a passphrase to encrypt it.")
+	if err := saveCredentials(configPath, config); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to encrypt the password (%s), try using `skedda configure`\n", err)
+	}
 }
 
-func readInput(prompt, defaultVal string, secret bool) string {
+func readInput(prompt, defaultVal string, secret bool) (string, error) {
 	reader := bufio.NewReader(os.Stdin)
 
 	defaultValDisplay := defaultVal
@@ -69,18 +214,78 @@ func readInput(prompt, defaultVal string, secret bool) string {
 	} else {
 		v, err = reader.ReadString('\n')
 	}
-	if err != nil {
-		fmt.Println("\n", err)
-		return readInput(prompt, defaultVal, secret)
+	// The last line may end without a newline, nothing is left to read after
+	// it
+	if err != nil && (err != io.EOF || v == "") {
+		fmt.Println()
+		return "", fmt.Errorf("reading the %s: %w", strings.ToLower(prompt), err)
 	}
 
 	v = strings.TrimSpace(v)
 	if v == "" {
-		return defaultVal
+		return defaultVal, nil
 	}
-	return string(v)
+	return v, nil
 }
 
-func readCredentials(oldUsername, oldPassword string) (string, string) {
-	return readInput("Username", oldUsername, false), readInput("Password", oldPassword, true)
+// configureCredentials prompts for the credentials with input until verify
+// accepts them or the user gives up. On the first attempt an empty password
+// keeps the stored one, which stored returns, and the result tells whether it
+// was kept. A nil stored means no password is stored, and a nil verify accepts
+// any credentials.
+func configureCredentials(config Config, input func(prompt, defaultVal string, secret bool) (string, error), stored func() (string, error), verify func(username, password string) error) (Config, bool, error) {
+	for attempt := 0; ; attempt++ {
+		username, err := input("Username", config.Username, false)
+		if err != nil {
+			return config, false, err
+		}
+
+		// The stored password is only offered on the first attempt, the next
+		// ones follow its failure
+		keepable := attempt == 0 && stored != nil && config.Password == ""
+		prompt := "Password"
+		if keepable {
+			prompt = "Password (empty to keep the stored one) [*****]"
+		}
+
+		password, err := input(prompt, config.Password, true)
+		if err != nil {
+			return config, false, err
+		}
+		fmt.Println()
+
+		config.Username = username
+		config.Password = password
+		config.CredentialCommand = ""
+
+		keep := keepable && password == ""
+		if verify == nil {
+			return config, keep, nil
+		}
+
+		if keep {
+			password, err = stored()
+			if err != nil {
+				return config, false, err
+			}
+		}
+
+		err = verify(username, password)
+		if err == nil {
+			return config, keep, nil
+		}
+
+		if !errors.Is(err, skedda.ErrAuthFailed) && !errors.Is(err, skedda.ErrMFARequired) && !errors.Is(err, skedda.ErrCredsMissing) {
+			return config, false, fmt.Errorf("%w, use --no-verify to save the credentials anyway", err)
+		}
+
+		fmt.Printf("\n%s\n", err)
+		answer, inputErr := input("Try again? [Y/n]", "y", false)
+		if inputErr != nil || !strings.HasPrefix(strings.ToLower(answer), "y") {
+			return config, false, err
+		}
+
+		// Do not suggest the password which failed
+		config.Password = ""
+	}
 }
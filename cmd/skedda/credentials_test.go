@@ -0,0 +1,206 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+	"testing"
+
+	"github.com/alyyousuf7/skedda"
+)
+
+func TestForgetCredentials(t *testing.T) {
+	testCases := []struct {
+		Config   string
+		Expected string
+	}{
+		{`{"Username":"jane@acme.com","Password":"s3cr3t"}`, ""},
+		{`{"Username":"jane@acme.com"}`, ""},
+		{`{"Username":"jane@acme.com","credential_command":"pass skedda"}`, `{"credential_command":"pass skedda"}`},
+		{`{"username":"jane@acme.com","theme":"dark"}`, `{"theme":"dark"}`},
+	}
+
+	for i, testCase := range testCases {
+		dir, err := ioutil.TempDir("", "skedda")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer os.RemoveAll(dir)
+
+		for name, content := range map[string]string{"config": testCase.Config, "credentials": "{}"} {
+			if err := ioutil.WriteFile(path.Join(dir, name), []byte(content), 0600); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		if err := forgetCredentials(dir); err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := os.Stat(path.Join(dir, "credentials")); !os.IsNotExist(err) {
+			t.Errorf("Expected the credential store to be deleted but got %v: Test case %d", err, i)
+		}
+
+		raw, err := ioutil.ReadFile(path.Join(dir, "config"))
+		if testCase.Expected == "" {
+			if !os.IsNotExist(err) {
+				t.Errorf("Expected the config to be deleted but got %s: Test case %d", raw, i)
+			}
+			continue
+		}
+
+		if string(raw) != testCase.Expected {
+			t.Errorf("Expected %s but got %s: Test case %d", testCase.Expected, raw, i)
+		}
+	}
+
+	// Nothing stored is nothing to forget
+	dir, err := ioutil.TempDir("", "skedda")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	if err := forgetCredentials(dir); err != nil {
+		t.Errorf("Expected nothing to forget but got %v", err)
+	}
+}
+
+func TestReadInput(t *testing.T) {
+	stdin := os.Stdin
+	defer func() { os.Stdin = stdin }()
+
+	testCases := []struct {
+		Input    string
+		Default  string
+		Expected string
+		Valid    bool
+	}{
+		{"jane\n", "", "jane", true},
+		{"jane", "", "jane", true},
+		{"\n", "john", "john", true},
+		{"", "john", "", false},
+		{"", "", "", false},
+	}
+
+	for i, testCase := range testCases {
+		f, err := ioutil.TempFile("", "stdin")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer os.Remove(f.Name())
+
+		f.WriteString(testCase.Input)
+		f.Seek(0, 0)
+		os.Stdin = f
+
+		// The end of stdin is an error rather than an endless prompt
+		value, err := readInput("Username", testCase.Default, false)
+		f.Close()
+
+		if (err == nil) != testCase.Valid || value != testCase.Expected {
+			t.Errorf("Expected %q, valid: %v but got %q, %v: Test case %d", testCase.Expected, testCase.Valid, value, err, i)
+		}
+	}
+}
+
+func TestConfigureCredentials(t *testing.T) {
+	testCases := []struct {
+		Stored   string
+		Answers  []string
+		Failures []error
+		Verified []string
+		Password string
+		Keep     bool
+		Err      error
+	}{
+		// A new password
+		{"", []string{"jane", "s3cr3t"}, nil, []string{"s3cr3t"}, "s3cr3t", false, nil},
+		// The stored password is kept when left empty
+		{"vaulted", []string{"jane", ""}, nil, []string{"vaulted"}, "", true, nil},
+		{"vaulted", []string{"jane", "s3cr3t"}, nil, []string{"s3cr3t"}, "s3cr3t", false, nil},
+		// Retried after a failure, without offering the stored password again
+		{"vaulted", []string{"jane", "", "y", "jane", "s3cr3t"}, []error{skedda.ErrAuthFailed}, []string{"vaulted", "s3cr3t"}, "s3cr3t", false, nil},
+		// Given up after a failure
+		{"", []string{"jane", "wrong", "n"}, []error{skedda.ErrAuthFailed}, []string{"wrong"}, "", false, skedda.ErrAuthFailed},
+		{"", []string{"jane", "wrong"}, []error{skedda.ErrAuthFailed}, []string{"wrong"}, "", false, skedda.ErrAuthFailed},
+		// Not retried when the credentials are not at fault
+		{"", []string{"jane", "s3cr3t"}, []error{io.ErrUnexpectedEOF}, []string{"s3cr3t"}, "", false, io.ErrUnexpectedEOF},
+	}
+
+	for i, testCase := range testCases {
+		answers := testCase.Answers
+		starred := []bool{}
+		input := func(prompt, defaultVal string, secret bool) (string, error) {
+			if strings.HasPrefix(prompt, "Password") {
+				starred = append(starred, strings.Contains(prompt, "*****") || defaultVal != "")
+			}
+
+			if len(answers) == 0 {
+				return "", io.EOF
+			}
+
+			answer := answers[0]
+			answers = answers[1:]
+			if answer == "" {
+				return defaultVal, nil
+			}
+			return answer, nil
+		}
+
+		var stored func() (string, error)
+		if testCase.Stored != "" {
+			stored = func() (string, error) {
+				return testCase.Stored, nil
+			}
+		}
+
+		verified := []string{}
+		verify := func(username, password string) error {
+			verified = append(verified, password)
+			if len(verified) <= len(testCase.Failures) {
+				return testCase.Failures[len(verified)-1]
+			}
+			return nil
+		}
+
+		config, keep, err := configureCredentials(Config{Username: "jane"}, input, stored, verify)
+		if !errors.Is(err, testCase.Err) {
+			t.Errorf("Expected %v but got %v: Test case %d", testCase.Err, err, i)
+			continue
+		}
+
+		if strings.Join(verified, ",") != strings.Join(testCase.Verified, ",") {
+			t.Errorf("Expected to verify %v but got %v: Test case %d", testCase.Verified, verified, i)
+		}
+
+		// Only the first prompt offers the stored password
+		for j, star := range starred {
+			if expected := j == 0 && testCase.Stored != ""; star != expected {
+				t.Errorf("Expected stored password shown: %v but got %v on prompt %d: Test case %d", expected, star, j, i)
+			}
+		}
+
+		if err != nil {
+			continue
+		}
+
+		if config.Password != testCase.Password || keep != testCase.Keep {
+			t.Errorf("Expected %q, keep: %v but got %q, %v: Test case %d", testCase.Password, testCase.Keep, config.Password, keep, i)
+		}
+	}
+
+	// No verification skips unlocking the stored password
+	config, keep, err := configureCredentials(Config{Username: "jane"}, func(prompt, defaultVal string, secret bool) (string, error) {
+		return defaultVal, nil
+	}, func() (string, error) {
+		return "", fmt.Errorf("vault unlocked")
+	}, nil)
+	if err != nil || !keep || config.Username != "jane" {
+		t.Errorf("Expected to keep the stored password but got %+v, %v, %v", config, keep, err)
+	}
+}
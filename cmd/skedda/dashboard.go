@@ -0,0 +1,216 @@
+package main
+
+import (
+	"html/template"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/alyyousuf7/skedda"
+)
+
+// dashboardServer serves an HTML overview of the bookings of all venues
+type dashboardServer struct {
+	skedda   *skedda.Skedda
+	venues   skedda.VenueList
+	spaces   skedda.SpaceList
+	dayStart int
+	dayEnd   int
+}
+
+type dashboardBlock struct {
+	Title string
+	Start time.Time
+	End   time.Time
+	Left  float64
+	Width float64
+}
+
+type dashboardSpace struct {
+	Space  *skedda.Space
+	Blocks []dashboardBlock
+}
+
+type dashboardVenue struct {
+	Venue  *skedda.Venue
+	Spaces []dashboardSpace
+}
+
+type dashboardHour struct {
+	Label string
+	Left  float64
+}
+
+type dashboardPage struct {
+	Date    time.Time
+	Prev    string
+	Next    string
+	Venues  skedda.VenueList
+	VenueID int
+	Hours   []dashboardHour
+	Groups  []dashboardVenue
+	Error   string
+}
+
+// Handler returns the routes of the dashboard
+func (d *dashboardServer) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", d.handleIndex)
+	return mux
+}
+
+// GET /?date=YYYY-MM-DD&venue=ID
+func (d *dashboardServer) handleIndex(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/" {
+		http.NotFound(w, r)
+		return
+	}
+
+	date := time.Now().UTC().Truncate(24 * time.Hour)
+	if dateStr := r.URL.Query().Get("date"); dateStr != "" {
+		t, err := time.Parse("2006-01-02", dateStr)
+		if err != nil {
+			http.Error(w, "invalid date", http.StatusBadRequest)
+			return
+		}
+		date = t
+	}
+
+	venueID, _ := strconv.Atoi(r.URL.Query().Get("venue"))
+
+	page := dashboardPage{
+		Date:    date,
+		Prev:    date.Add(-24 * time.Hour).Format("2006-01-02"),
+		Next:    date.Add(24 * time.Hour).Format("2006-01-02"),
+		Venues:  d.venues,
+		VenueID: venueID,
+	}
+
+	from := date.Add(time.Duration(d.dayStart) * time.Hour)
+	till := date.Add(time.Duration(d.dayEnd) * time.Hour)
+	for hour := d.dayStart; hour <= d.dayEnd; hour++ {
+		t := date.Add(time.Duration(hour) * time.Hour)
+		page.Hours = append(page.Hours, dashboardHour{t.Format("3pm"), d.position(t, from, till)})
+	}
+
+	// Spaces of venues no longer listed are left out
+	spaces := skedda.SpaceList{}
+	for _, space := range d.spaces {
+		if venueID != 0 && space.VenueID != venueID {
+			continue
+		}
+
+		if d.venues.FindByID(space.VenueID) != nil {
+			spaces = append(spaces, space)
+		}
+	}
+	sort.Sort(spaces)
+
+	spaceBookings, err := fetchSpaceBookings(d.skedda, d.venues, spaces, from, till)
+	if err != nil {
+		page.Error = err.Error()
+	}
+
+	for _, space := range spaces {
+		if len(page.Groups) == 0 || page.Groups[len(page.Groups)-1].Venue.ID != space.VenueID {
+			page.Groups = append(page.Groups, dashboardVenue{Venue: d.venues.FindByID(space.VenueID)})
+		}
+		group := &page.Groups[len(page.Groups)-1]
+
+		row := dashboardSpace{Space: space}
+		for _, booking := range spaceBookings[space] {
+			title := booking.Title
+			if title == "" {
+				title = "[Unknown]"
+			}
+
+			for _, occurrence := range booking.Occurrences(from, till) {
+				left := d.position(occurrence.Start, from, till)
+				row.Blocks = append(row.Blocks, dashboardBlock{
+					Title: title,
+					Start: occurrence.Start,
+					End:   occurrence.End,
+					Left:  left,
+					Width: d.position(occurrence.End, from, till) - left,
+				})
+			}
+		}
+		group.Spaces = append(group.Spaces, row)
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := dashboardTemplate.Execute(w, page); err != nil {
+		log.Println("Failed to render dashboard:", err)
+	}
+}
+
+// position returns where t falls in the period, as a percentage clamped to
+// the period
+func (d *dashboardServer) position(t, from, till time.Time) float64 {
+	if t.Before(from) {
+		return 0
+	}
+
+	if t.After(till) {
+		return 100
+	}
+
+	return float64(t.Sub(from)) / float64(till.Sub(from)) * 100
+}
+
+var dashboardTemplate = template.Must(template.New("dashboard").Parse(`<!DOCTYPE html>
+<html>
+<head>
+	<meta charset="utf-8">
+	<title>Skedda -- {{.Date.Format "Mon 02 Jan"}}</title>
+	<style>
+		body { font-family: sans-serif; margin: 2em; color: #333; }
+		nav { margin-bottom: 1em; }
+		nav a, nav form { margin-right: 1em; display: inline; }
+		h2 { margin: 1.5em 0 .5em; }
+		.row { display: flex; align-items: center; height: 2em; border-bottom: 1px solid #eee; }
+		.name { width: 14em; flex-shrink: 0; overflow: hidden; white-space: nowrap; text-overflow: ellipsis; }
+		.timeline { position: relative; flex-grow: 1; height: 100%; background: #f7f7f7; }
+		.block { position: absolute; top: 15%; height: 70%; background: #3498db; color: #fff; font-size: .75em; overflow: hidden; white-space: nowrap; border-radius: 3px; }
+		.hour { position: absolute; font-size: .75em; color: #999; }
+		.error { color: #c0392b; }
+	</style>
+</head>
+<body>
+	<h1>{{.Date.Format "Monday 02 January 2006"}}</h1>
+	<nav>
+		<a href="?date={{.Prev}}&venue={{.VenueID}}">&larr; Previous</a>
+		<a href="?venue={{.VenueID}}">Today</a>
+		<a href="?date={{.Next}}&venue={{.VenueID}}">Next &rarr;</a>
+		<form method="get">
+			<input type="hidden" name="date" value="{{.Date.Format "2006-01-02"}}">
+			<select name="venue" onchange="this.form.submit()">
+				<option value="0">All venues</option>
+				{{$venueID := .VenueID}}
+				{{range .Venues}}<option value="{{.ID}}"{{if eq .ID $venueID}} selected{{end}}>{{.Name}}</option>{{end}}
+			</select>
+		</form>
+	</nav>
+	{{if .Error}}<p class="error">Error: {{.Error}}</p>{{end}}
+	<div class="row">
+		<div class="name"></div>
+		<div class="timeline" style="background: none">
+			{{range .Hours}}<span class="hour" style="left: {{.Left}}%">{{.Label}}</span>{{end}}
+		</div>
+	</div>
+	{{range .Groups}}
+	<h2>{{.Venue.Name}}</h2>
+	{{range .Spaces}}
+	<div class="row">
+		<div class="name" title="{{.Space.Name}}">{{.Space.Name}}</div>
+		<div class="timeline">
+			{{range .Blocks}}<div class="block" style="left: {{.Left}}%; width: {{.Width}}%" title="{{.Title}} ({{.Start.Format "3:04pm"}} - {{.End.Format "3:04pm"}})">{{.Title}}</div>{{end}}
+		</div>
+	</div>
+	{{end}}
+	{{end}}
+</body>
+</html>
+`))
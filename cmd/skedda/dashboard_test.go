@@ -0,0 +1,93 @@
+package main
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alyyousuf7/skedda"
+)
+
+func TestDashboardPosition(t *testing.T) {
+	at := func(hour, minute int) time.Time {
+		return time.Date(2020, time.March, 4, hour, minute, 0, 0, time.UTC)
+	}
+
+	testCases := []struct {
+		Time     time.Time
+		Expected float64
+	}{
+		{at(7, 0), 0},
+		{at(8, 0), 0},
+		{at(9, 0), 10},
+		{at(13, 0), 50},
+		{at(18, 0), 100},
+		{at(19, 30), 100},
+	}
+
+	d := &dashboardServer{}
+	for i, testCase := range testCases {
+		if result := d.position(testCase.Time, at(8, 0), at(18, 0)); result != testCase.Expected {
+			t.Errorf("Expected %v but got %v: Test case %d", testCase.Expected, result, i)
+		}
+	}
+}
+
+func TestDashboardIndex(t *testing.T) {
+	s := fakeSkedda(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"bookings":[{"id":1,"title":"Standup","start":"2020-03-04T09:00:00","end":"2020-03-04T09:30:00","recurrenceRule":null,"spaces":[10],"venue":1}]}`))
+	})
+
+	d := &dashboardServer{
+		skedda: s,
+		venues: skedda.VenueList{{ID: 1, Name: "London Office", Domain: "acme"}, {ID: 2, Name: "Lisbon Office", Domain: "acme-lisbon"}},
+		spaces: skedda.SpaceList{
+			{ID: 10, Name: "Thames", VenueID: 1},
+			{ID: 20, Name: "Tagus", VenueID: 2},
+			// Space of a venue no longer listed
+			{ID: 30, Name: "Seine", VenueID: 3},
+		},
+		dayStart: 8,
+		dayEnd:   18,
+	}
+
+	testCases := []struct {
+		URL      string
+		Status   int
+		Expected []string
+		Missing  []string
+	}{
+		{"/?date=2020-03-04", http.StatusOK, []string{"Wednesday 04 March 2020", "London Office", "Thames", "Lisbon Office", "Tagus", "Standup (9:00am - 9:30am)"}, []string{"Seine"}},
+		{"/?date=2020-03-04&venue=2", http.StatusOK, []string{"Tagus"}, []string{"Thames", "Standup"}},
+		{"/?date=2020-03-04&venue=3", http.StatusOK, nil, []string{"Seine"}},
+		{"/?date=tomorrow", http.StatusBadRequest, nil, nil},
+		{"/elsewhere", http.StatusNotFound, nil, nil},
+	}
+
+	for i, testCase := range testCases {
+		req := httptest.NewRequest("GET", testCase.URL, nil)
+		rec := httptest.NewRecorder()
+		d.Handler().ServeHTTP(rec, req)
+
+		body, _ := ioutil.ReadAll(rec.Body)
+		if rec.Code != testCase.Status {
+			t.Errorf("Expected %d but got %d: Test case %d", testCase.Status, rec.Code, i)
+			continue
+		}
+
+		for _, expected := range testCase.Expected {
+			if !strings.Contains(string(body), expected) {
+				t.Errorf("Expected %q in the page: Test case %d", expected, i)
+			}
+		}
+
+		for _, missing := range testCase.Missing {
+			if strings.Contains(string(body), missing) {
+				t.Errorf("Expected no %q in the page: Test case %d", missing, i)
+			}
+		}
+	}
+}
@@ -0,0 +1,269 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/alyyousuf7/skedda"
+)
+
+// latencyBuckets are the upper bounds, in seconds, of the buckets of the
+// latency histogram
+var latencyBuckets = []float64{0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// metricsTransport counts the requests made to Skedda along with their
+// failures and latency
+type metricsTransport struct {
+	next http.RoundTripper
+
+	mu       sync.Mutex
+	requests map[string]float64
+	errors   map[string]float64
+	seconds  map[string]float64
+	// buckets counts the requests of each label set taking at most each of
+	// latencyBuckets
+	buckets map[string][]float64
+}
+
+func newMetricsTransport() *metricsTransport {
+	return &metricsTransport{
+		next:     http.DefaultTransport,
+		requests: map[string]float64{},
+		errors:   map[string]float64{},
+		seconds:  map[string]float64{},
+		buckets:  map[string][]float64{},
+	}
+}
+
+// RoundTrip performs the request and records it
+func (t *metricsTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	start := time.Now()
+	res, err := t.next.RoundTrip(req)
+	elapsed := time.Since(start)
+
+	labels := formatLabels("method", req.Method, "path", req.URL.Path)
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.requests[labels]++
+	t.seconds[labels] += elapsed.Seconds()
+	if t.buckets[labels] == nil {
+		t.buckets[labels] = make([]float64, len(latencyBuckets))
+	}
+	for i, le := range latencyBuckets {
+		if elapsed.Seconds() <= le {
+			t.buckets[labels][i]++
+		}
+	}
+	if err != nil || res.StatusCode >= 400 {
+		t.errors[labels]++
+	}
+
+	return res, err
+}
+
+type spaceStat struct {
+	venue         *skedda.Venue
+	space         *skedda.Space
+	occupied      bool
+	bookedMinutes float64
+	bookings      int
+}
+
+// exporter periodically collects the occupancy of spaces and exposes it in
+// the Prometheus text format
+type exporter struct {
+	skedda    *skedda.Skedda
+	venues    skedda.VenueList
+	spaces    skedda.SpaceList
+	locations map[int]*time.Location
+	transport *metricsTransport
+
+	mu              sync.Mutex
+	stats           []spaceStat
+	lastScrape      time.Time
+	lastScrapeError bool
+}
+
+// Run collects the occupancy every interval until stop is closed
+func (e *exporter) Run(interval time.Duration, stop <-chan struct{}) {
+	for {
+		e.collect()
+
+		select {
+		case <-time.After(interval):
+		case <-stop:
+			return
+		}
+	}
+}
+
+func (e *exporter) collect() {
+	stats := []spaceStat{}
+	failed := false
+	for _, venue := range e.venues {
+		now := venueNow(e.locations[venue.ID])
+		from := now.Truncate(24 * time.Hour)
+		till := from.Add(24 * time.Hour)
+
+		bookings, err := e.skedda.Bookings(venue.Domain, from, till)
+		if err != nil {
+			fmt.Printf("Failed to fetch bookings of %s: %s\n", venue.Name, err)
+			failed = true
+			continue
+		}
+
+		for _, space := range e.spaces {
+			if space.VenueID != venue.ID {
+				continue
+			}
+
+			stat := spaceStat{venue: venue, space: space}
+			intervals := []skedda.Interval{}
+			for _, booking := range bookings {
+				if !containsID(booking.SpaceIDs, space.ID) {
+					continue
+				}
+
+				for _, occurrence := range booking.Occurrences(from, till) {
+					stat.bookings++
+					if clipped, ok := occurrence.Clip(from, till); ok {
+						intervals = append(intervals, clipped)
+					}
+				}
+			}
+
+			for _, interval := range skedda.MergeIntervals(intervals) {
+				stat.bookedMinutes += interval.Duration().Minutes()
+				if !now.Before(interval.Start) && now.Before(interval.End) {
+					stat.occupied = true
+				}
+			}
+
+			stats = append(stats, stat)
+		}
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if !failed || len(e.stats) == 0 {
+		e.stats = stats
+	}
+	e.lastScrape = time.Now()
+	e.lastScrapeError = failed
+}
+
+// ServeHTTP writes the metrics
+func (e *exporter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	buf := &bytes.Buffer{}
+
+	e.mu.Lock()
+	occupied := map[string]float64{}
+	bookedMinutes := map[string]float64{}
+	bookings := map[string]float64{}
+	for _, stat := range e.stats {
+		labels := formatLabels("venue", stat.venue.Name, "space", stat.space.Name)
+		occupied[labels] = 0
+		if stat.occupied {
+			occupied[labels] = 1
+		}
+		bookedMinutes[labels] = stat.bookedMinutes
+		bookings[labels] = float64(stat.bookings)
+	}
+	scrapeSuccess := map[string]float64{"": 1}
+	if e.lastScrapeError {
+		scrapeSuccess[""] = 0
+	}
+	scrapeTime := map[string]float64{"": float64(e.lastScrape.Unix())}
+	e.mu.Unlock()
+
+	writeMetric(buf, "skedda_space_occupied", "gauge", "Whether the space is booked right now", occupied)
+	writeMetric(buf, "skedda_space_booked_minutes_today", "gauge", "Minutes the space is booked today", bookedMinutes)
+	writeMetric(buf, "skedda_space_bookings_today", "gauge", "Number of bookings of the space today", bookings)
+	writeMetric(buf, "skedda_last_collection_success", "gauge", "Whether the last collection of bookings succeeded", scrapeSuccess)
+	writeMetric(buf, "skedda_last_collection_timestamp_seconds", "gauge", "Time of the last collection of bookings", scrapeTime)
+
+	e.transport.mu.Lock()
+	writeMetric(buf, "skedda_api_requests_total", "counter", "Requests made to Skedda", e.transport.requests)
+	writeMetric(buf, "skedda_api_errors_total", "counter", "Requests made to Skedda which failed", e.transport.errors)
+	writeHistogram(buf, "skedda_api_request_duration_seconds", "Latency of the requests made to Skedda", latencyBuckets, e.transport.buckets, e.transport.seconds, e.transport.requests)
+	e.transport.mu.Unlock()
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	w.Write(buf.Bytes())
+}
+
+// writeMetric writes a metric family with its samples keyed by their
+// formatted labels
+func writeMetric(buf *bytes.Buffer, name, kind, help string, samples map[string]float64) {
+	fmt.Fprintf(buf, "# HELP %s %s\n", name, help)
+	fmt.Fprintf(buf, "# TYPE %s %s\n", name, kind)
+
+	keys := []string{}
+	for labels := range samples {
+		keys = append(keys, labels)
+	}
+	sort.Strings(keys)
+
+	for _, labels := range keys {
+		fmt.Fprintf(buf, "%s%s %s\n", name, labels, strconv.FormatFloat(samples[labels], 'f', -1, 64))
+	}
+}
+
+// writeHistogram writes a histogram family with the cumulative counts of its
+// buckets bounded by bounds, the sum and the count of the observations keyed
+// by their formatted labels
+func writeHistogram(buf *bytes.Buffer, name, help string, bounds []float64, buckets map[string][]float64, sums, counts map[string]float64) {
+	fmt.Fprintf(buf, "# HELP %s %s\n", name, help)
+	fmt.Fprintf(buf, "# TYPE %s histogram\n", name)
+
+	keys := []string{}
+	for labels := range counts {
+		keys = append(keys, labels)
+	}
+	sort.Strings(keys)
+
+	for _, labels := range keys {
+		prefix := strings.TrimSuffix(labels, "}")
+		if prefix != "{" {
+			prefix += ","
+		}
+
+		for i, le := range bounds {
+			var count float64
+			if i < len(buckets[labels]) {
+				count = buckets[labels][i]
+			}
+			fmt.Fprintf(buf, "%s_bucket%sle=\"%s\"} %s\n", name, prefix, strconv.FormatFloat(le, 'f', -1, 64), strconv.FormatFloat(count, 'f', -1, 64))
+		}
+		fmt.Fprintf(buf, "%s_bucket%sle=\"+Inf\"} %s\n", name, prefix, strconv.FormatFloat(counts[labels], 'f', -1, 64))
+		fmt.Fprintf(buf, "%s_sum%s %s\n", name, labels, strconv.FormatFloat(sums[labels], 'f', -1, 64))
+		fmt.Fprintf(buf, "%s_count%s %s\n", name, labels, strconv.FormatFloat(counts[labels], 'f', -1, 64))
+	}
+}
+
+// formatLabels formats label name and value pairs as {name="value",...}
+func formatLabels(pairs ...string) string {
+	escaper := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+	labels := []string{}
+	for i := 0; i+1 < len(pairs); i += 2 {
+		labels = append(labels, fmt.Sprintf(`%s="%s"`, pairs[i], escaper.Replace(pairs[i+1])))
+	}
+
+	return "{" + strings.Join(labels, ",") + "}"
+}
+
+func containsID(ids []int, id int) bool {
+	for _, v := range ids {
+		if v == id {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alyyousuf7/skedda"
+)
+
+func TestFormatLabels(t *testing.T) {
+	testCases := []struct {
+		Pairs    []string
+		Expected string
+	}{
+		{nil, "{}"},
+		{[]string{"venue", "London"}, `{venue="London"}`},
+		{[]string{"venue", "London", "space", "Desk 1"}, `{venue="London",space="Desk 1"}`},
+		{[]string{"space", `The "Big" Room`}, `{space="The \"Big\" Room"}`},
+		{[]string{"space", "a\\b\nc"}, `{space="a\\b\nc"}`},
+		{[]string{"venue"}, "{}"},
+	}
+
+	for i, testCase := range testCases {
+		if result := formatLabels(testCase.Pairs...); result != testCase.Expected {
+			t.Errorf("Expected %s but got %s: Test case %d", testCase.Expected, result, i)
+		}
+	}
+}
+
+func TestWriteMetric(t *testing.T) {
+	buf := &bytes.Buffer{}
+	writeMetric(buf, "skedda_space_occupied", "gauge", "Whether the space is booked right now", map[string]float64{
+		`{space="Thames"}`: 1,
+		`{space="Desk 1"}`: 0,
+	})
+
+	expected := `# HELP skedda_space_occupied Whether the space is booked right now
+# TYPE skedda_space_occupied gauge
+skedda_space_occupied{space="Desk 1"} 0
+skedda_space_occupied{space="Thames"} 1
+`
+	if buf.String() != expected {
+		t.Errorf("Expected %q but got %q", expected, buf.String())
+	}
+}
+
+func TestWriteHistogram(t *testing.T) {
+	labels := `{method="GET"}`
+	buf := &bytes.Buffer{}
+	writeHistogram(buf, "latency_seconds", "Latency", []float64{0.1, 1},
+		map[string][]float64{labels: {1, 2}},
+		map[string]float64{labels: 2.55},
+		map[string]float64{labels: 3},
+	)
+
+	expected := `# HELP latency_seconds Latency
+# TYPE latency_seconds histogram
+latency_seconds_bucket{method="GET",le="0.1"} 1
+latency_seconds_bucket{method="GET",le="1"} 2
+latency_seconds_bucket{method="GET",le="+Inf"} 3
+latency_seconds_sum{method="GET"} 2.55
+latency_seconds_count{method="GET"} 3
+`
+	if buf.String() != expected {
+		t.Errorf("Expected %q but got %q", expected, buf.String())
+	}
+}
+
+func TestMetricsTransport(t *testing.T) {
+	transport := newMetricsTransport()
+	transport.next = roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		rec := httptest.NewRecorder()
+		if req.URL.Path == "/missing" {
+			rec.WriteHeader(http.StatusNotFound)
+		}
+
+		return rec.Result(), nil
+	})
+
+	for _, path := range []string{"/booking", "/booking", "/missing"} {
+		req := httptest.NewRequest("GET", "https://acme.skedda.com"+path, nil)
+		if _, err := transport.RoundTrip(req); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	testCases := []struct {
+		Labels   string
+		Requests float64
+		Errors   float64
+	}{
+		{formatLabels("method", "GET", "path", "/booking"), 2, 0},
+		{formatLabels("method", "GET", "path", "/missing"), 1, 1},
+	}
+
+	for i, testCase := range testCases {
+		if transport.requests[testCase.Labels] != testCase.Requests || transport.errors[testCase.Labels] != testCase.Errors {
+			t.Errorf("Expected %v requests and %v errors but got %v and %v: Test case %d", testCase.Requests, testCase.Errors, transport.requests[testCase.Labels], transport.errors[testCase.Labels], i)
+		}
+
+		// The requests are fast enough for every bucket
+		for _, count := range transport.buckets[testCase.Labels] {
+			if count != testCase.Requests {
+				t.Errorf("Expected %v in every bucket but got %v: Test case %d", testCase.Requests, transport.buckets[testCase.Labels], i)
+				break
+			}
+		}
+	}
+}
+
+func TestExporterCollect(t *testing.T) {
+	today := venueNow(time.UTC).Truncate(24 * time.Hour)
+	format := func(t time.Time) string {
+		return t.Format("2006-01-02T15:04:05")
+	}
+
+	// Thames is booked all day, Desk 1 twice for an overlapping hour
+	bookings := `{"bookings":[` +
+		`{"id":1,"title":"Offsite","start":"` + format(today) + `","end":"` + format(today.Add(24*time.Hour)) + `","recurrenceRule":null,"spaces":[10],"venue":1},` +
+		`{"id":2,"title":"Focus","start":"` + format(today.Add(time.Hour)) + `","end":"` + format(today.Add(2*time.Hour)) + `","recurrenceRule":null,"spaces":[11],"venue":1},` +
+		`{"id":3,"title":"Call","start":"` + format(today.Add(90*time.Minute)) + `","end":"` + format(today.Add(150*time.Minute)) + `","recurrenceRule":null,"spaces":[11],"venue":1}` +
+		`]}`
+
+	s := fakeSkedda(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(bookings))
+	})
+
+	e := &exporter{
+		skedda:    s,
+		venues:    skedda.VenueList{{ID: 1, Name: "London", Domain: "acme"}},
+		spaces:    skedda.SpaceList{{ID: 10, Name: "Thames", VenueID: 1}, {ID: 11, Name: "Desk 1", VenueID: 1}, {ID: 12, Name: "Desk 2", VenueID: 1}},
+		locations: map[int]*time.Location{1: time.UTC},
+		transport: newMetricsTransport(),
+	}
+	e.collect()
+
+	req := httptest.NewRequest("GET", "/metrics", nil)
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	body, _ := ioutil.ReadAll(rec.Body)
+
+	expected := []string{
+		`skedda_space_occupied{venue="London",space="Thames"} 1`,
+		`skedda_space_booked_minutes_today{venue="London",space="Thames"} 1440`,
+		`skedda_space_booked_minutes_today{venue="London",space="Desk 1"} 90`,
+		`skedda_space_bookings_today{venue="London",space="Desk 1"} 2`,
+		`skedda_space_bookings_today{venue="London",space="Desk 2"} 0`,
+		`skedda_last_collection_success 1`,
+	}
+
+	for i, line := range expected {
+		if !strings.Contains(string(body), line+"\n") {
+			t.Errorf("Expected %q in the metrics: Test case %d", line, i)
+		}
+	}
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"text/tabwriter"
+	"time"
+
+	"github.com/alyyousuf7/skedda"
+)
+
+// Forecast of a space staying free during a time period
+type Forecast struct {
+	Space       *skedda.Space
+	From        time.Time
+	Till        time.Time
+	Probability float64
+	Free        int
+	Samples     int
+	Booked      bool
+}
+
+// forecastDays returns the dates of the same weekday as onDate in the weeks
+// before today, most recent first
+func forecastDays(onDate, today time.Time, weeks int) []time.Time {
+	last := onDate
+	for !last.Before(today) {
+		last = last.Add(-7 * 24 * time.Hour)
+	}
+
+	days := []time.Time{}
+	for i := 0; i < weeks; i++ {
+		days = append(days, last.Add(-time.Duration(i)*7*24*time.Hour))
+	}
+
+	return days
+}
+
+// forecastFree estimates the probability of a space staying free during a
+// time period from how often the same period was free on the past days of the
+// same weekday. The estimate is smoothed so that a few samples never yield a
+// certainty. A period which is already booked is never free.
+func forecastFree(space *skedda.Space, occurrences []skedda.Interval, pastDays []time.Time, from, till time.Time) Forecast {
+	f := Forecast{Space: space, From: from, Till: till}
+	for _, occurrence := range occurrences {
+		if occurrence.Overlaps(from, till) {
+			f.Booked = true
+			return f
+		}
+	}
+
+	day := from.Truncate(24 * time.Hour)
+	for _, pastDay := range pastDays {
+		if pastDay.Weekday() != from.Weekday() {
+			continue
+		}
+
+		pastFrom := pastDay.Add(from.Sub(day))
+		pastTill := pastDay.Add(till.Sub(day))
+
+		f.Samples++
+		free := true
+		for _, occurrence := range occurrences {
+			if occurrence.Overlaps(pastFrom, pastTill) {
+				free = false
+				break
+			}
+		}
+		if free {
+			f.Free++
+		}
+	}
+
+	f.Probability = float64(f.Free+1) / float64(f.Samples+2)
+	return f
+}
+
+// forecastAlternatives forecasts the periods of the same length starting on
+// every slot of the grid between opens and closes in all the spaces, and
+// returns the ones most likely to stay free, closest to the requested period
+// first on a tie
+func forecastAlternatives(occurrences map[*skedda.Space][]skedda.Interval, pastDays []time.Time, from, till time.Time, opens, closes time.Duration, grid skedda.Grid, count int) []Forecast {
+	day := from.Truncate(24 * time.Hour)
+	duration := till.Sub(from)
+
+	slot := grid.Slot
+	if slot <= 0 {
+		slot = skedda.DefaultSlot
+	}
+
+	spaces := skedda.SpaceList{}
+	for space := range occurrences {
+		spaces = append(spaces, space)
+	}
+	sort.Sort(spaces)
+
+	candidates := []Forecast{}
+	for _, space := range spaces {
+		for start := grid.Round(day.Add(opens), skedda.SnapUp); !start.Add(duration).After(day.Add(closes)); start = start.Add(slot) {
+			if start.Equal(from) {
+				continue
+			}
+
+			f := forecastFree(space, occurrences[space], pastDays, start, start.Add(duration))
+			if !f.Booked {
+				candidates = append(candidates, f)
+			}
+		}
+	}
+
+	distance := func(f Forecast) time.Duration {
+		d := f.From.Sub(from)
+		if d < 0 {
+			return -d
+		}
+		return d
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		if candidates[i].Probability != candidates[j].Probability {
+			return candidates[i].Probability > candidates[j].Probability
+		}
+		return distance(candidates[i]) < distance(candidates[j])
+	})
+
+	if len(candidates) > count {
+		candidates = candidates[:count]
+	}
+
+	return candidates
+}
+
+func writeForecasts(w io.Writer, forecasts []Forecast) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	for _, f := range forecasts {
+		period := fmt.Sprintf("%s - %s", f.From.Format("3:04pm"), f.Till.Format("3:04pm"))
+		if f.Booked {
+			fmt.Fprintf(tw, "  %s\t%s\talready booked\n", f.Space.Name, period)
+			continue
+		}
+
+		fmt.Fprintf(tw, "  %s\t%s\t%.0f%% likely to stay free\t(free on %d of %d past %ss)\n", f.Space.Name, period, f.Probability*100, f.Free, f.Samples, f.From.Weekday())
+	}
+
+	return tw.Flush()
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/alyyousuf7/skedda"
+)
+
+func TestForecastDays(t *testing.T) {
+	day := func(d int) time.Time {
+		return time.Date(2020, time.March, d, 0, 0, 0, 0, time.UTC)
+	}
+
+	testCases := []struct {
+		OnDate   time.Time
+		Today    time.Time
+		Weeks    int
+		Expected []time.Time
+	}{
+		// Wednesday 25 March, today being Wednesday 11 March
+		{day(25), day(11), 3, []time.Time{day(4), day(-3), day(-10)}},
+		{day(25), day(12), 2, []time.Time{day(11), day(4)}},
+		{day(11), day(11), 1, []time.Time{day(4)}},
+		{day(4), day(11), 2, []time.Time{day(4), day(-3)}},
+	}
+
+	for i, testCase := range testCases {
+		days := forecastDays(testCase.OnDate, testCase.Today, testCase.Weeks)
+		if len(days) != len(testCase.Expected) {
+			t.Errorf("Expected %v but got %v: Test case %d", testCase.Expected, days, i)
+			continue
+		}
+
+		for j := range days {
+			if !days[j].Equal(testCase.Expected[j]) {
+				t.Errorf("Expected %v but got %v: Test case %d", testCase.Expected, days, i)
+				break
+			}
+		}
+	}
+}
+
+func TestForecastFree(t *testing.T) {
+	at := func(d, hour int) time.Time {
+		return time.Date(2020, time.March, d, hour, 0, 0, 0, time.UTC)
+	}
+
+	// Wednesdays 4, 11 and 18 March, forecasting Wednesday 25
+	pastDays := []time.Time{at(18, 0), at(11, 0), at(4, 0), at(5, 0)}
+	occurrences := []skedda.Interval{
+		{Start: at(18, 9), End: at(18, 10)},
+		{Start: at(11, 9), End: at(11, 11)},
+		{Start: at(25, 14), End: at(25, 15)},
+	}
+
+	testCases := []struct {
+		From, Till  time.Time
+		Booked      bool
+		Free        int
+		Samples     int
+		Probability float64
+	}{
+		{at(25, 9), at(25, 10), false, 1, 3, 2.0 / 5},
+		{at(25, 10), at(25, 11), false, 2, 3, 3.0 / 5},
+		{at(25, 11), at(25, 12), false, 3, 3, 4.0 / 5},
+		{at(25, 14), at(25, 16), true, 0, 0, 0},
+	}
+
+	for i, testCase := range testCases {
+		f := forecastFree(&skedda.Space{ID: 10}, occurrences, pastDays, testCase.From, testCase.Till)
+		if f.Booked != testCase.Booked || f.Free != testCase.Free || f.Samples != testCase.Samples || f.Probability != testCase.Probability {
+			t.Errorf("Expected %v, %d/%d, %v but got %v, %d/%d, %v: Test case %d", testCase.Booked, testCase.Free, testCase.Samples, testCase.Probability, f.Booked, f.Free, f.Samples, f.Probability, i)
+		}
+	}
+}
+
+func TestForecastAlternatives(t *testing.T) {
+	at := func(d, hour, minute int) time.Time {
+		return time.Date(2020, time.March, d, hour, minute, 0, 0, time.UTC)
+	}
+
+	thames := &skedda.Space{ID: 10, Name: "Thames", VenueID: 1}
+	desk := &skedda.Space{ID: 11, Name: "Desk 1", VenueID: 1}
+
+	// Thames was busy 9-10am in the past, Desk 1 is booked 9:30-10am. The
+	// requested period itself is not an alternative.
+	occurrences := map[*skedda.Space][]skedda.Interval{
+		thames: {{Start: at(18, 9, 0), End: at(18, 10, 0)}, {Start: at(11, 9, 0), End: at(11, 10, 0)}},
+		desk:   {{Start: at(25, 9, 30), End: at(25, 10, 0)}},
+	}
+	pastDays := []time.Time{at(18, 0, 0), at(11, 0, 0)}
+
+	testCases := []struct {
+		From, Till time.Time
+		Grid       skedda.Grid
+		Count      int
+		Expected   []Forecast
+	}{
+		{
+			at(25, 9, 0), at(25, 9, 30), skedda.Grid{}, 3,
+			[]Forecast{
+				{Space: desk, From: at(25, 8, 45)},
+				{Space: desk, From: at(25, 8, 30)},
+				{Space: thames, From: at(25, 8, 30)},
+			},
+		},
+		{
+			at(25, 9, 0), at(25, 10, 0), skedda.Grid{}, 4,
+			[]Forecast{
+				{Space: desk, From: at(25, 8, 30)},
+				{Space: desk, From: at(25, 8, 15)},
+				{Space: desk, From: at(25, 8, 0)},
+				{Space: desk, From: at(25, 10, 0)},
+			},
+		},
+		{
+			at(25, 9, 0), at(25, 9, 30), skedda.Grid{Slot: 30 * time.Minute}, 3,
+			[]Forecast{
+				{Space: desk, From: at(25, 8, 30)},
+				{Space: thames, From: at(25, 8, 30)},
+				{Space: desk, From: at(25, 8, 0)},
+			},
+		},
+	}
+
+	for i, testCase := range testCases {
+		result := forecastAlternatives(occurrences, pastDays, testCase.From, testCase.Till, 8*time.Hour, 11*time.Hour, testCase.Grid, testCase.Count)
+		if len(result) != len(testCase.Expected) {
+			t.Errorf("Expected %d alternatives but got %v: Test case %d", len(testCase.Expected), result, i)
+			continue
+		}
+
+		for j, expected := range testCase.Expected {
+			if result[j].Space != expected.Space || !result[j].From.Equal(expected.From) || result[j].Till.Sub(result[j].From) != testCase.Till.Sub(testCase.From) {
+				t.Errorf("Expected %s at %v but got %s at %v: Test case %d", expected.Space.Name, expected.From, result[j].Space.Name, result[j].From, i)
+			}
+		}
+	}
+}
+
+func TestWriteForecasts(t *testing.T) {
+	from := time.Date(2020, time.March, 25, 9, 0, 0, 0, time.UTC)
+	forecasts := []Forecast{
+		{Space: &skedda.Space{Name: "Thames"}, From: from, Till: from.Add(time.Hour), Probability: 0.6, Free: 2, Samples: 3},
+		{Space: &skedda.Space{Name: "Desk 1"}, From: from, Till: from.Add(time.Hour), Booked: true},
+	}
+
+	buf := &bytes.Buffer{}
+	if err := writeForecasts(buf, forecasts); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "  Thames  9:00am - 10:00am  60% likely to stay free  (free on 2 of 3 past Wednesdays)\n" +
+		"  Desk 1  9:00am - 10:00am  already booked\n"
+	if buf.String() != expected {
+		t.Errorf("Expected %q but got %q", expected, buf.String())
+	}
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/alyyousuf7/skedda"
+)
+
+// heatmapSlot is the granularity of the heatmap
+const heatmapSlot = 15 * time.Minute
+
+// heatmapWeekdays lists the rows of the heatmap, starting on Monday
+var heatmapWeekdays = []time.Weekday{time.Monday, time.Tuesday, time.Wednesday, time.Thursday, time.Friday, time.Saturday, time.Sunday}
+
+// HeatmapCell is the load of a slot of a weekday
+type HeatmapCell struct {
+	Weekday       string  `json:"weekday"`
+	Slot          string  `json:"slot"`
+	BookedMinutes float64 `json:"bookedMinutes"`
+	Occupancy     float64 `json:"occupancy"`
+}
+
+// computeHeatmap aggregates the booked minutes of the spaces per weekday and
+// slot between opens and closes. Slots are counted from midnight, so the first
+// and the last ones are partly open when opens or closes fall in between. The
+// occupancy of a cell is its booked minutes relative to the minutes all spaces
+// were available in that slot.
+func computeHeatmap(occurrences map[*skedda.Space][]skedda.Interval, from, till time.Time, opens, closes time.Duration, skipWeekends bool) [][]HeatmapCell {
+	first := opens.Truncate(heatmapSlot)
+	slots := int((closes - first + heatmapSlot - 1) / heatmapSlot)
+	booked := map[time.Weekday][]float64{}
+	days := map[time.Weekday]int{}
+	for _, weekday := range heatmapWeekdays {
+		booked[weekday] = make([]float64, slots)
+	}
+
+	for day := from; day.Before(till); day = day.Add(24 * time.Hour) {
+		days[day.Weekday()]++
+	}
+
+	for _, intervals := range occurrences {
+		for _, interval := range skedda.MergeIntervals(intervals) {
+			for t := interval.Start.Truncate(heatmapSlot); t.Before(interval.End); t = t.Add(heatmapSlot) {
+				day := t.Truncate(24 * time.Hour)
+				slot := int((t.Sub(day) - first) / heatmapSlot)
+				if slot < 0 || slot >= slots {
+					continue
+				}
+
+				start, end := t, t.Add(heatmapSlot)
+				if opening := day.Add(opens); start.Before(opening) {
+					start = opening
+				}
+				if closing := day.Add(closes); end.After(closing) {
+					end = closing
+				}
+
+				if part, ok := interval.Clip(start, end); ok {
+					booked[t.Weekday()][slot] += part.Duration().Minutes()
+				}
+			}
+		}
+	}
+
+	rows := [][]HeatmapCell{}
+	for _, weekday := range heatmapWeekdays {
+		if skipWeekends && (weekday == time.Saturday || weekday == time.Sunday) {
+			continue
+		}
+
+		row := []HeatmapCell{}
+		for slot, minutes := range booked[weekday] {
+			start := first + time.Duration(slot)*heatmapSlot
+			open := heatmapSlot
+			if start < opens {
+				open -= opens - start
+			}
+			if end := start + heatmapSlot; end > closes {
+				open -= end - closes
+			}
+
+			cell := HeatmapCell{
+				Weekday:       weekday.String(),
+				Slot:          time.Time{}.Add(start).Format("15:04"),
+				BookedMinutes: minutes,
+			}
+			if available := float64(days[weekday]*len(occurrences)) * open.Minutes(); available > 0 {
+				cell.Occupancy = minutes / available
+			}
+			row = append(row, cell)
+		}
+		rows = append(rows, row)
+	}
+
+	return rows
+}
+
+func writeHeatmap(w io.Writer, format string, rows [][]HeatmapCell) error {
+	switch format {
+	case "json":
+		cells := []HeatmapCell{}
+		for _, row := range rows {
+			cells = append(cells, row...)
+		}
+
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(cells)
+	case "csv":
+		cw := csv.NewWriter(w)
+		cw.Write([]string{"weekday", "slot", "booked_minutes", "occupancy"})
+		for _, row := range rows {
+			for _, cell := range row {
+				cw.Write([]string{
+					cell.Weekday,
+					cell.Slot,
+					strconv.FormatFloat(cell.BookedMinutes, 'f', -1, 64),
+					strconv.FormatFloat(cell.Occupancy, 'f', 3, 64),
+				})
+			}
+		}
+		cw.Flush()
+		return cw.Error()
+	case "table":
+		shades := []rune(" ░▒▓█")
+		if len(rows) == 0 || len(rows[0]) == 0 {
+			return nil
+		}
+
+		header := strings.Builder{}
+		for i, cell := range rows[0] {
+			if i%4 == 0 {
+				header.WriteString(fmt.Sprintf("%-4s", cell.Slot[:2]))
+			}
+		}
+		fmt.Fprintf(w, "     %s\n", header.String())
+
+		for _, row := range rows {
+			line := strings.Builder{}
+			for _, cell := range row {
+				shade := int(cell.Occupancy * float64(len(shades)))
+				if shade >= len(shades) {
+					shade = len(shades) - 1
+				}
+				line.WriteRune(shades[shade])
+			}
+			fmt.Fprintf(w, "%s  %s\n", row[0].Weekday[:3], line.String())
+		}
+
+		fmt.Fprintf(w, "\n     ' ' <20%%  '░' <40%%  '▒' <60%%  '▓' <80%%  '█' 80%%+ of the time booked\n")
+		return nil
+	}
+
+	return fmt.Errorf("unknown format: %s", format)
+}
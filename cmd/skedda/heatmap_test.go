@@ -0,0 +1,137 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alyyousuf7/skedda"
+)
+
+func TestComputeHeatmap(t *testing.T) {
+	interval := func(day, fromHour, fromMinute, tillHour, tillMinute int) skedda.Interval {
+		return skedda.Interval{
+			Start: time.Date(2020, time.March, day, fromHour, fromMinute, 0, 0, time.UTC),
+			End:   time.Date(2020, time.March, day, tillHour, tillMinute, 0, 0, time.UTC),
+		}
+	}
+
+	// Wednesday 4, Thursday 5 and Saturday 7 March
+	occurrences := map[*skedda.Space][]skedda.Interval{
+		{ID: 10, Name: "Thames"}: {interval(4, 9, 0, 9, 20), interval(4, 9, 10, 9, 30)},
+		{ID: 11, Name: "Desk 1"}: {interval(4, 9, 0, 9, 15), interval(5, 8, 0, 9, 5), interval(7, 9, 45, 11, 0)},
+	}
+
+	type cell struct {
+		Weekday       string
+		Slot          string
+		BookedMinutes float64
+		Occupancy     float64
+	}
+
+	testCases := []struct {
+		Till         time.Time
+		Opens        time.Duration
+		Closes       time.Duration
+		SkipWeekends bool
+		Rows         int
+		Slots        int
+		Expected     []cell
+	}{
+		{
+			time.Date(2020, time.March, 11, 0, 0, 0, 0, time.UTC), 9 * time.Hour, 10 * time.Hour, false, 7, 4,
+			[]cell{
+				{"Wednesday", "09:00", 30, 1},
+				{"Wednesday", "09:15", 15, 0.5},
+				{"Wednesday", "09:30", 0, 0},
+				{"Thursday", "09:00", 5, 5.0 / 30},
+				{"Saturday", "09:45", 15, 0.5},
+				{"Monday", "09:00", 0, 0},
+			},
+		},
+		// Two weeks halve the occupancy
+		{
+			time.Date(2020, time.March, 18, 0, 0, 0, 0, time.UTC), 9 * time.Hour, 10 * time.Hour, true, 5, 4,
+			[]cell{
+				{"Wednesday", "09:00", 30, 0.5},
+				{"Wednesday", "09:15", 15, 0.25},
+			},
+		},
+		// Slots stay on the quarters when opening and closing in between
+		{
+			time.Date(2020, time.March, 11, 0, 0, 0, 0, time.UTC), 8*time.Hour + 10*time.Minute, 9*time.Hour + 20*time.Minute, false, 7, 6,
+			[]cell{
+				{"Thursday", "08:00", 5, 0.5},
+				{"Thursday", "08:15", 15, 0.5},
+				{"Thursday", "09:00", 5, 5.0 / 30},
+				{"Wednesday", "09:00", 30, 1},
+				{"Wednesday", "09:15", 5, 0.5},
+			},
+		},
+	}
+
+	for i, testCase := range testCases {
+		rows := computeHeatmap(occurrences, time.Date(2020, time.March, 4, 0, 0, 0, 0, time.UTC), testCase.Till, testCase.Opens, testCase.Closes, testCase.SkipWeekends)
+		if len(rows) != testCase.Rows {
+			t.Errorf("Expected %d rows but got %d: Test case %d", testCase.Rows, len(rows), i)
+			continue
+		}
+
+		cells := map[string]HeatmapCell{}
+		for _, row := range rows {
+			if len(row) != testCase.Slots {
+				t.Errorf("Expected %d slots but got %d: Test case %d", testCase.Slots, len(row), i)
+			}
+
+			for _, c := range row {
+				cells[c.Weekday+" "+c.Slot] = c
+			}
+		}
+
+		if rows[0][0].Weekday != "Monday" {
+			t.Errorf("Expected the rows to start on Monday but got %s: Test case %d", rows[0][0].Weekday, i)
+		}
+
+		for _, expected := range testCase.Expected {
+			c, ok := cells[expected.Weekday+" "+expected.Slot]
+			if !ok || c.BookedMinutes != expected.BookedMinutes || c.Occupancy != expected.Occupancy {
+				t.Errorf("Expected %+v but got %+v: Test case %d", expected, c, i)
+			}
+		}
+	}
+}
+
+func TestWriteHeatmap(t *testing.T) {
+	rows := [][]HeatmapCell{
+		{
+			{"Monday", "09:00", 15, 1},
+			{"Monday", "09:15", 6, 0.4},
+			{"Monday", "09:30", 0, 0},
+			{"Monday", "09:45", 3, 0.2},
+		},
+	}
+
+	testCases := []struct {
+		Format   string
+		Expected string
+		Valid    bool
+	}{
+		{"csv", "weekday,slot,booked_minutes,occupancy\nMonday,09:00,15,1.000\nMonday,09:15,6,0.400\nMonday,09:30,0,0.000\nMonday,09:45,3,0.200\n", true},
+		{"table", "     09  \nMon  █▒ ░\n", true},
+		{"xml", "", false},
+	}
+
+	for i, testCase := range testCases {
+		buf := &bytes.Buffer{}
+		err := writeHeatmap(buf, testCase.Format, rows)
+		if (err == nil) != testCase.Valid {
+			t.Errorf("Expected valid: %v but got %v: Test case %d", testCase.Valid, err, i)
+			continue
+		}
+
+		if !strings.HasPrefix(buf.String(), testCase.Expected) {
+			t.Errorf("Expected %q but got %q: Test case %d", testCase.Expected, buf.String(), i)
+		}
+	}
+}
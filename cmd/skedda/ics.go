@@ -0,0 +1,213 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/alyyousuf7/skedda"
+)
+
+// icsServer serves iCalendar feeds of the bookings of spaces and venues
+type icsServer struct {
+	skedda    *skedda.Skedda
+	venues    skedda.VenueList
+	spaces    skedda.SpaceList
+	lookAhead time.Duration
+	ttl       time.Duration
+
+	mu    sync.Mutex
+	cache map[string]icsCacheEntry
+}
+
+type icsCacheEntry struct {
+	body    []byte
+	expires time.Time
+}
+
+// Handler returns the routes of the feeds
+func (f *icsServer) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/spaces/", f.handleFeed)
+	mux.HandleFunc("/venues/", f.handleFeed)
+	return mux
+}
+
+// GET /spaces/{id}.ics
+// GET /venues/{id}.ics
+func (f *icsServer) handleFeed(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" && r.Method != "HEAD" {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	if len(parts) != 2 || !strings.HasSuffix(parts[1], ".ics") {
+		http.NotFound(w, r)
+		return
+	}
+
+	id, err := strconv.Atoi(strings.TrimSuffix(parts[1], ".ics"))
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	body, err := f.feed(parts[0], id)
+	if err == errFeedNotFound {
+		http.NotFound(w, r)
+		return
+	} else if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/calendar; charset=utf-8")
+	w.Write(body)
+}
+
+var errFeedNotFound = fmt.Errorf("feed not found")
+
+// feed returns the calendar of a space or a venue, from cache if still fresh
+func (f *icsServer) feed(kind string, id int) ([]byte, error) {
+	key := fmt.Sprintf("%s/%d", kind, id)
+
+	f.mu.Lock()
+	entry, ok := f.cache[key]
+	f.mu.Unlock()
+	if ok && time.Now().Before(entry.expires) {
+		return entry.body, nil
+	}
+
+	var name string
+	var spaces skedda.SpaceList
+	switch kind {
+	case "spaces":
+		space := f.spaces.FindByID(id)
+		if space == nil {
+			return nil, errFeedNotFound
+		}
+		name = space.Name
+		spaces = skedda.SpaceList{space}
+	case "venues":
+		venue := f.venues.FindByID(id)
+		if venue == nil {
+			return nil, errFeedNotFound
+		}
+		name = venue.Name
+		for _, space := range f.spaces {
+			if space.VenueID == venue.ID {
+				spaces = append(spaces, space)
+			}
+		}
+	}
+
+	from := time.Now().UTC().Truncate(24 * time.Hour)
+	to := from.Add(f.lookAhead)
+	spaceBookings, err := fetchSpaceBookings(f.skedda, f.venues, spaces, from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	body := f.calendar(name, spaceBookings, from, to)
+
+	f.mu.Lock()
+	f.cache[key] = icsCacheEntry{body, time.Now().Add(f.ttl)}
+	f.mu.Unlock()
+
+	return body, nil
+}
+
+// calendar renders the bookings as an iCalendar document. A booking covering
+// several spaces is rendered once, listing all of them as its location.
+func (f *icsServer) calendar(name string, spaceBookings map[*skedda.Space][]*skedda.Booking, from, to time.Time) []byte {
+	bookings := map[int]*skedda.Booking{}
+	for _, list := range spaceBookings {
+		for _, booking := range list {
+			bookings[booking.ID] = booking
+		}
+	}
+
+	ids := []int{}
+	for id := range bookings {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+
+	buf := &bytes.Buffer{}
+	writeICSLine(buf, "BEGIN:VCALENDAR")
+	writeICSLine(buf, "VERSION:2.0")
+	writeICSLine(buf, "PRODID:-//alyyousuf7//skedda//EN")
+	writeICSLine(buf, "CALSCALE:GREGORIAN")
+	writeICSLine(buf, "X-WR-CALNAME:"+escapeICSText(name))
+
+	stamp := time.Now().UTC().Format("20060102T150405Z")
+	for _, id := range ids {
+		booking := bookings[id]
+		venue := f.venues.FindByID(booking.VenueID)
+		location := bookingSpaceNames(booking, f.spaces)
+
+		title := booking.Title
+		if title == "" {
+			title = "[Unknown]"
+		}
+
+		for _, occurrence := range booking.Occurrences(from, to) {
+			writeICSLine(buf, "BEGIN:VEVENT")
+			writeICSLine(buf, fmt.Sprintf("UID:%d-%s@skedda.com", booking.ID, occurrence.Start.Format("20060102T1504")))
+			writeICSLine(buf, "DTSTAMP:"+stamp)
+			writeICSLine(buf, "DTSTART:"+formatICSTime(occurrence.Start, venue))
+			writeICSLine(buf, "DTEND:"+formatICSTime(occurrence.End, venue))
+			writeICSLine(buf, "SUMMARY:"+escapeICSText(title))
+			writeICSLine(buf, "LOCATION:"+escapeICSText(location))
+			writeICSLine(buf, "END:VEVENT")
+		}
+	}
+
+	writeICSLine(buf, "END:VCALENDAR")
+	return buf.Bytes()
+}
+
+// formatICSTime formats a venue's local time in UTC, or as floating time when
+// the time zone of the venue is unknown
+func formatICSTime(t time.Time, venue *skedda.Venue) string {
+	if venue != nil {
+		if loc, err := venue.Location(); err == nil {
+			local := time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), 0, loc)
+			return local.UTC().Format("20060102T150405Z")
+		}
+	}
+
+	return t.Format("20060102T150405")
+}
+
+// writeICSLine writes a content line, folding it at 75 octets as required by
+// RFC 5545
+func writeICSLine(buf *bytes.Buffer, line string) {
+	limit := 75
+	for len(line) > limit {
+		cut := limit
+		for cut > 0 && !isRuneStart(line[cut]) {
+			cut--
+		}
+		buf.WriteString(line[:cut] + "\r\n ")
+		line = line[cut:]
+
+		// Continuation lines start with a space
+		limit = 74
+	}
+	buf.WriteString(line + "\r\n")
+}
+
+func isRuneStart(b byte) bool {
+	return b&0xC0 != 0x80
+}
+
+func escapeICSText(s string) string {
+	return strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\n", `\n`).Replace(s)
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestWriteICSLine(t *testing.T) {
+	testCases := []struct {
+		Line     string
+		Expected []string
+	}{
+		{"SUMMARY:Standup", []string{"SUMMARY:Standup"}},
+		{"SUMMARY:" + strings.Repeat("a", 67), []string{"SUMMARY:" + strings.Repeat("a", 67)}},
+		{"SUMMARY:" + strings.Repeat("a", 68), []string{"SUMMARY:" + strings.Repeat("a", 67), " a"}},
+		{"SUMMARY:" + strings.Repeat("a", 67+74+1), []string{"SUMMARY:" + strings.Repeat("a", 67), " " + strings.Repeat("a", 74), " a"}},
+		// Multi-byte characters are not split across lines
+		{"SUMMARY:" + strings.Repeat("a", 66) + "é", []string{"SUMMARY:" + strings.Repeat("a", 66), " é"}},
+	}
+
+	for i, testCase := range testCases {
+		buf := &bytes.Buffer{}
+		writeICSLine(buf, testCase.Line)
+
+		expected := strings.Join(testCase.Expected, "\r\n") + "\r\n"
+		if buf.String() != expected {
+			t.Errorf("Expected %q but got %q: Test case %d", expected, buf.String(), i)
+		}
+
+		for _, line := range strings.Split(strings.TrimSuffix(buf.String(), "\r\n"), "\r\n") {
+			if len(line) > 75 {
+				t.Errorf("Expected at most 75 octets but got %d: Test case %d", len(line), i)
+			}
+		}
+	}
+}
+
+func TestEscapeICSText(t *testing.T) {
+	testCases := []struct {
+		Text     string
+		Expected string
+	}{
+		{"Standup", "Standup"},
+		{"Thames, Desk 1", `Thames\, Desk 1`},
+		{"Plan; review", `Plan\; review`},
+		{`C:\path`, `C:\\path`},
+		{"line\nbreak", `line\nbreak`},
+	}
+
+	for i, testCase := range testCases {
+		if result := escapeICSText(testCase.Text); result != testCase.Expected {
+			t.Errorf("Expected %q but got %q: Test case %d", testCase.Expected, result, i)
+		}
+	}
+}
+
+func TestIsLoopback(t *testing.T) {
+	testCases := []struct {
+		Addr     string
+		Expected bool
+	}{
+		{"localhost:8080", true},
+		{"127.0.0.1:8080", true},
+		{"[::1]:8080", true},
+		{":8080", false},
+		{"0.0.0.0:8080", false},
+		{"10.0.0.2:8080", false},
+		{"8080", false},
+	}
+
+	for i, testCase := range testCases {
+		if result := isLoopback(testCase.Addr); result != testCase.Expected {
+			t.Errorf("Expected %v but got %v: Test case %d", testCase.Expected, result, i)
+		}
+	}
+}
+
+func TestCheckListen(t *testing.T) {
+	testCases := []struct {
+		Addr     string
+		Token    string
+		Expected bool
+	}{
+		{"127.0.0.1:8080", "", true},
+		{":8080", "", false},
+		{"0.0.0.0:8080", "", false},
+		{":8080", "s3cr3t", true},
+	}
+
+	for i, testCase := range testCases {
+		if err := checkListen(testCase.Addr, testCase.Token, "the dashboard"); (err == nil) != testCase.Expected {
+			t.Errorf("Expected allowed: %v but got %v: Test case %d", testCase.Expected, err, i)
+		}
+	}
+}
@@ -0,0 +1,309 @@
+package main
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"html/template"
+	"log"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/alyyousuf7/skedda"
+)
+
+// kioskServer serves a self-refreshing page showing the schedule of a space
+type kioskServer struct {
+	skedda  *skedda.Skedda
+	grid    skedda.Grid
+	venue   *skedda.Venue
+	space   *skedda.Space
+	loc     *time.Location
+	title   string
+	refresh time.Duration
+
+	// token is required from the displays, if set, either in the query
+	// string or in the cookie set on their first visit
+	token string
+
+	// csrf is embedded in the booking forms so that other pages cannot
+	// book from the kiosk
+	csrf string
+
+	// mu serializes bookings made from the page
+	mu sync.Mutex
+}
+
+const kioskTokenCookie = "kiosk_token"
+
+// newCSRFToken returns a random token for the booking forms
+func newCSRFToken() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(b), nil
+}
+
+type kioskBooking struct {
+	Title string
+	Start time.Time
+	End   time.Time
+}
+
+type kioskPage struct {
+	Venue     *skedda.Venue
+	Space     *skedda.Space
+	Now       time.Time
+	Refresh   int
+	Current   *kioskBooking
+	Remaining time.Duration
+	Next      []kioskBooking
+	Options   []int
+	CSRF      string
+	Message   string
+	Error     string
+}
+
+// Handler returns the routes of the kiosk
+func (k *kioskServer) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", k.handleIndex)
+	mux.HandleFunc("/book", k.handleBook)
+	return k.requireToken(mux)
+}
+
+// requireToken rejects requests without the token of the kiosk, remembering
+// it in a cookie for the page to refresh and post its forms
+func (k *kioskServer) requireToken(next http.Handler) http.Handler {
+	if k.token == "" {
+		return next
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token := r.URL.Query().Get("token")
+		if token == "" {
+			if cookie, err := r.Cookie(kioskTokenCookie); err == nil {
+				token = cookie.Value
+			}
+		} else {
+			http.SetCookie(w, &http.Cookie{
+				Name:     kioskTokenCookie,
+				Value:    token,
+				Path:     "/",
+				HttpOnly: true,
+				SameSite: http.SameSiteStrictMode,
+			})
+		}
+
+		if subtle.ConstantTimeCompare([]byte(token), []byte(k.token)) != 1 {
+			http.Error(w, "invalid token", http.StatusUnauthorized)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// GET /
+func (k *kioskServer) handleIndex(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/" {
+		http.NotFound(w, r)
+		return
+	}
+
+	page := kioskPage{
+		Venue:   k.venue,
+		Space:   k.space,
+		Now:     venueNow(k.loc),
+		Refresh: int(k.refresh.Seconds()),
+		CSRF:    k.csrf,
+		Message: r.URL.Query().Get("message"),
+		Error:   r.URL.Query().Get("error"),
+	}
+
+	bookings, err := k.todaysBookings(page.Now)
+	if err != nil {
+		page.Error = err.Error()
+	}
+
+	for i, booking := range bookings {
+		if !booking.End.After(page.Now) {
+			continue
+		}
+
+		if booking.Start.After(page.Now) {
+			page.Next = append(page.Next, booking)
+		} else if page.Current == nil {
+			page.Current = &bookings[i]
+			page.Remaining = booking.End.Sub(page.Now).Round(time.Minute)
+		}
+	}
+
+	if page.Current == nil && err == nil {
+		for _, minutes := range []int{15, 30} {
+			if _, _, ok := k.freeSlot(page.Now, minutes, page.Next); ok {
+				page.Options = append(page.Options, minutes)
+			}
+		}
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := kioskTemplate.Execute(w, page); err != nil {
+		log.Println("Failed to render kiosk:", err)
+	}
+}
+
+// POST /book?minutes=15
+func (k *kioskServer) handleBook(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	redirect := func(key, value string) {
+		http.Redirect(w, r, "/?"+key+"="+url.QueryEscape(value), http.StatusSeeOther)
+	}
+
+	if subtle.ConstantTimeCompare([]byte(r.PostFormValue("csrf")), []byte(k.csrf)) != 1 {
+		http.Error(w, "invalid form", http.StatusForbidden)
+		return
+	}
+
+	minutes, err := strconv.Atoi(r.PostFormValue("minutes"))
+	if err != nil || (minutes != 15 && minutes != 30) {
+		redirect("error", "invalid duration")
+		return
+	}
+
+	now := venueNow(k.loc)
+	bookings, err := k.todaysBookings(now)
+	if err != nil {
+		redirect("error", err.Error())
+		return
+	}
+
+	from, till, ok := k.freeSlot(now, minutes, bookings)
+	if !ok {
+		redirect("error", "the space is not available")
+		return
+	}
+
+	if err := k.skedda.Book(k.venue.Domain, k.venue.ID, []int{k.space.ID}, k.title, from, till); err != nil {
+		redirect("error", err.Error())
+		return
+	}
+
+	redirect("message", fmt.Sprintf("Booked until %s", till.Format("3:04pm")))
+}
+
+// freeSlot returns the slot starting at the next slot of the venue's booking
+// grid, or now when it is on the grid, if it does not clash with any of the
+// bookings. Starting in the current slot would give away the part of it
+// already gone.
+func (k *kioskServer) freeSlot(now time.Time, minutes int, bookings []kioskBooking) (time.Time, time.Time, bool) {
+	from := k.grid.Round(now, skedda.SnapUp)
+	till := from.Add(time.Duration(minutes) * time.Minute)
+	for _, booking := range bookings {
+		if booking.Start.Before(till) && booking.End.After(from) {
+			return from, till, false
+		}
+	}
+
+	return from, till, true
+}
+
+// todaysBookings returns the bookings of the space today, sorted by start
+func (k *kioskServer) todaysBookings(now time.Time) ([]kioskBooking, error) {
+	from := now.Truncate(24 * time.Hour)
+	till := from.Add(24 * time.Hour)
+	bookings, err := k.skedda.Bookings(k.venue.Domain, from, till)
+	if err != nil {
+		return nil, err
+	}
+
+	result := []kioskBooking{}
+	for _, booking := range bookings {
+		inSpace := false
+		for _, id := range booking.SpaceIDs {
+			inSpace = inSpace || id == k.space.ID
+		}
+		if !inSpace {
+			continue
+		}
+
+		title := booking.Title
+		if title == "" {
+			title = "[Unknown]"
+		}
+
+		for _, occurrence := range booking.Occurrences(from, till) {
+			result = append(result, kioskBooking{title, occurrence.Start, occurrence.End})
+		}
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Start.Before(result[j].Start)
+	})
+
+	return result, nil
+}
+
+var kioskTemplate = template.Must(template.New("kiosk").Parse(`<!DOCTYPE html>
+<html>
+<head>
+	<meta charset="utf-8">
+	<meta name="viewport" content="width=device-width, initial-scale=1">
+	<meta http-equiv="refresh" content="{{.Refresh}};url=/">
+	<title>{{.Space.Name}}</title>
+	<style>
+		body { margin: 0; font-family: sans-serif; color: #fff; background: {{if .Current}}#c0392b{{else}}#27ae60{{end}}; }
+		main { padding: 4vw; }
+		h1 { font-size: 6vw; margin: 0; }
+		h2 { font-size: 4vw; margin: 2vw 0; }
+		.clock { float: right; font-size: 4vw; }
+		.status { font-size: 3vw; }
+		ul { list-style: none; padding: 0; font-size: 2.5vw; }
+		li { padding: 1vw 0; border-bottom: 1px solid rgba(255,255,255,.3); }
+		button { font-size: 3vw; padding: 2vw 4vw; margin-right: 2vw; border: 0; border-radius: 1vw; background: #fff; color: #27ae60; }
+		.notice { font-size: 2vw; padding: 1vw; background: rgba(0,0,0,.2); }
+	</style>
+</head>
+<body>
+<main>
+	<span class="clock">{{.Now.Format "3:04pm"}}</span>
+	<h1>{{.Space.Name}}</h1>
+	<div>{{.Venue.Name}}</div>
+	{{if .Error}}<p class="notice">Error: {{.Error}}</p>{{end}}
+	{{if .Message}}<p class="notice">{{.Message}}</p>{{end}}
+	{{if .Current}}
+		<h2>Occupied</h2>
+		<p class="status">{{.Current.Title}} until {{.Current.End.Format "3:04pm"}} ({{.Remaining}} left)</p>
+	{{else}}
+		<h2>Available</h2>
+		{{range .Options}}
+		<form method="post" action="/book" style="display: inline">
+			<input type="hidden" name="csrf" value="{{$.CSRF}}">
+			<input type="hidden" name="minutes" value="{{.}}">
+			<button type="submit">Book {{.}} min</button>
+		</form>
+		{{end}}
+	{{end}}
+	<h2>Next</h2>
+	<ul>
+		{{range .Next}}<li>{{.Start.Format "3:04pm"}} - {{.End.Format "3:04pm"}} &middot; {{.Title}}</li>
+		{{else}}<li>Nothing else today</li>{{end}}
+	</ul>
+</main>
+</body>
+</html>
+`))
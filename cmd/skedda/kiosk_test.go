@@ -0,0 +1,202 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alyyousuf7/skedda"
+)
+
+type roundTripFunc func(req *http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+// fakeSkedda returns a client whose requests to Skedda are served by handler,
+// the verification token being served on its behalf
+func fakeSkedda(t *testing.T, handler http.HandlerFunc) *skedda.Skedda {
+	s, err := skedda.NewWithSession("jane@acme.com", skedda.ParseCookieHeader("session=abc", "skedda.com"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s.SetTransport(roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		rec := httptest.NewRecorder()
+		if req.URL.Path == "/booking" {
+			rec.WriteString(`<input name="__RequestVerificationToken" type="hidden" value="token" />`)
+		} else {
+			handler(rec, req)
+		}
+
+		res := rec.Result()
+		res.Request = req
+		return res, nil
+	}))
+
+	return s
+}
+
+func TestKioskFreeSlot(t *testing.T) {
+	at := func(hour, minute int) time.Time {
+		return time.Date(2020, time.March, 4, hour, minute, 0, 0, time.UTC)
+	}
+
+	bookings := []kioskBooking{
+		{"Standup", at(9, 30), at(10, 0)},
+		{"Lunch", at(12, 0), at(13, 0)},
+	}
+
+	testCases := []struct {
+		Grid     skedda.Grid
+		Now      time.Time
+		Minutes  int
+		From     time.Time
+		Expected bool
+	}{
+		{skedda.Grid{}, at(9, 10), 15, at(9, 15), true},
+		{skedda.Grid{}, at(9, 10), 30, at(9, 15), false},
+		{skedda.Grid{}, at(9, 20), 30, at(9, 30), false},
+		{skedda.Grid{}, at(9, 40), 15, at(9, 45), false},
+		{skedda.Grid{}, at(10, 0), 15, at(10, 0), true},
+		{skedda.Grid{}, at(10, 14), 15, at(10, 15), true},
+		{skedda.Grid{}, at(11, 40), 15, at(11, 45), true},
+		{skedda.Grid{}, at(11, 50), 15, at(12, 0), false},
+		{skedda.Grid{Slot: time.Hour}, at(10, 50), 30, at(11, 0), true},
+		{skedda.Grid{Slot: 30 * time.Minute}, at(9, 20), 15, at(9, 30), false},
+	}
+
+	for i, testCase := range testCases {
+		k := &kioskServer{grid: testCase.Grid}
+		from, till, ok := k.freeSlot(testCase.Now, testCase.Minutes, bookings)
+		if ok != testCase.Expected || !from.Equal(testCase.From) || till.Sub(from) != time.Duration(testCase.Minutes)*time.Minute {
+			t.Errorf("Expected %v (%v) but got %v - %v (%v): Test case %d", testCase.From, testCase.Expected, from, till, ok, i)
+		}
+	}
+}
+
+func TestKioskBook(t *testing.T) {
+	today := venueNow(time.UTC).Truncate(24 * time.Hour)
+	occupied := `{"bookings":[{"id":1,"title":"Offsite","start":"` + today.Format("2006-01-02T15:04:05") + `","end":"` + today.Add(24*time.Hour).Format("2006-01-02T15:04:05") + `","recurrenceRule":null,"spaces":[2],"venue":1}]}`
+
+	testCases := []struct {
+		Method   string
+		Form     url.Values
+		Bookings string
+		Status   int
+		Location string
+		Booked   bool
+	}{
+		{"GET", nil, `{"bookings":[]}`, http.StatusMethodNotAllowed, "", false},
+		{"POST", url.Values{"minutes": {"15"}}, `{"bookings":[]}`, http.StatusForbidden, "", false},
+		{"POST", url.Values{"minutes": {"15"}, "csrf": {"other"}}, `{"bookings":[]}`, http.StatusForbidden, "", false},
+		{"POST", url.Values{"minutes": {"45"}, "csrf": {"secret"}}, `{"bookings":[]}`, http.StatusSeeOther, "/?error=invalid+duration", false},
+		{"POST", url.Values{"minutes": {"15"}, "csrf": {"secret"}}, occupied, http.StatusSeeOther, "/?error=the+space+is+not+available", false},
+		{"POST", url.Values{"minutes": {"15"}, "csrf": {"secret"}}, `{"bookings":[]}`, http.StatusSeeOther, "/?message=Booked+until", true},
+	}
+
+	for i, testCase := range testCases {
+		booked := false
+		s := fakeSkedda(t, func(w http.ResponseWriter, r *http.Request) {
+			if r.Method == "POST" && r.URL.Path == "/bookings" {
+				booked = true
+				w.Write([]byte("{}"))
+				return
+			}
+
+			w.Write([]byte(testCase.Bookings))
+		})
+
+		k := &kioskServer{
+			skedda: s,
+			venue:  &skedda.Venue{ID: 1, Name: "London Office", Domain: "acme"},
+			space:  &skedda.Space{ID: 2, Name: "Thames", VenueID: 1},
+			loc:    time.UTC,
+			title:  "Ad hoc meeting",
+			csrf:   "secret",
+		}
+
+		req := httptest.NewRequest(testCase.Method, "/book", strings.NewReader(testCase.Form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		rec := httptest.NewRecorder()
+		k.Handler().ServeHTTP(rec, req)
+
+		location := rec.Header().Get("Location")
+		if rec.Code != testCase.Status || !strings.HasPrefix(location, testCase.Location) || booked != testCase.Booked {
+			t.Errorf("Expected %d %q (booked: %v) but got %d %q (booked: %v): Test case %d", testCase.Status, testCase.Location, testCase.Booked, rec.Code, location, booked, i)
+		}
+	}
+}
+
+func TestKioskIndex(t *testing.T) {
+	s := fakeSkedda(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"bookings":[]}`))
+	})
+
+	k := &kioskServer{
+		skedda:  s,
+		venue:   &skedda.Venue{ID: 1, Name: "London Office", Domain: "acme"},
+		space:   &skedda.Space{ID: 2, Name: "Thames", VenueID: 1},
+		loc:     time.UTC,
+		refresh: time.Minute,
+		token:   "display",
+		csrf:    "secret",
+	}
+
+	testCases := []struct {
+		URL    string
+		Cookie string
+		Status int
+	}{
+		{"/", "", http.StatusUnauthorized},
+		{"/?token=other", "", http.StatusUnauthorized},
+		{"/", "other", http.StatusUnauthorized},
+		{"/?token=display", "", http.StatusOK},
+		{"/", "display", http.StatusOK},
+		{"/elsewhere", "display", http.StatusNotFound},
+	}
+
+	for i, testCase := range testCases {
+		req := httptest.NewRequest("GET", testCase.URL, nil)
+		if testCase.Cookie != "" {
+			req.AddCookie(&http.Cookie{Name: kioskTokenCookie, Value: testCase.Cookie})
+		}
+		rec := httptest.NewRecorder()
+		k.Handler().ServeHTTP(rec, req)
+
+		if rec.Code != testCase.Status {
+			t.Errorf("Expected %d but got %d: Test case %d", testCase.Status, rec.Code, i)
+			continue
+		}
+
+		if rec.Code != http.StatusOK {
+			continue
+		}
+
+		body, _ := ioutil.ReadAll(rec.Body)
+		if !strings.Contains(string(body), "Available") || !strings.Contains(string(body), `name="csrf" value="secret"`) {
+			t.Errorf("Expected the page with the booking forms but got %s: Test case %d", body, i)
+		}
+	}
+}
+
+func TestLogRequestsHidesToken(t *testing.T) {
+	var buf bytes.Buffer
+	log.SetOutput(&buf)
+	defer log.SetOutput(os.Stderr)
+
+	handler := logRequests(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/?token=display", nil))
+
+	if !strings.Contains(buf.String(), "GET / 200") || strings.Contains(buf.String(), "display") {
+		t.Errorf("Expected the request without its query but got %q", buf.String())
+	}
+}
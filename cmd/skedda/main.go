@@ -1,16 +1,20 @@
 package main
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"net/http"
 	"os"
+	"os/signal"
 	"path"
 	"sort"
 	"strings"
-	"sync"
+	"syscall"
 	"time"
 
 	"github.com/alyyousuf7/skedda"
+	"github.com/alyyousuf7/skedda/timeparse"
 	"github.com/urfave/cli/v2"
 )
 
@@ -23,7 +27,11 @@ func main() {
 
 	var (
 		noCache    = false
+		cacheTTL   = 24 * time.Hour
 		configPath = defaultConfigPath
+		profile    = ""
+
+		credentialOpts = credentialOptions{}
 	)
 
 	noCacheFlag := cli.BoolFlag{
@@ -35,524 +43,1907 @@ func main() {
 		Destination: &noCache,
 	}
 
+	offlineFlag := cli.BoolFlag{
+		Name:  "offline",
+		Usage: "Answer from the cache without connecting to Skedda",
+	}
+
 	app := &cli.App{
 		Name:  "skedda",
 		Usage: "Book a space with Skedda",
+		Flags: []cli.Flag{
+			profileFlag(&profile),
+			&cli.DurationFlag{
+				Name:        "cache-ttl",
+				Usage:       "How long the cached venues and spaces are used before refreshing them in the background (0 to never expire)",
+				EnvVars:     []string{"SKEDDA_CACHE_TTL"},
+				Value:       cacheTTL,
+				Destination: &cacheTTL,
+			},
+			&cli.StringFlag{
+				Name:        "username",
+				Usage:       "Username to sign in with, instead of SKEDDA_USERNAME or the configured one",
+				Destination: &credentialOpts.Username,
+			},
+			&cli.StringFlag{
+				Name:        "password",
+				Usage:       "Password to sign in with, instead of SKEDDA_PASSWORD or the configured one",
+				Destination: &credentialOpts.Password,
+			},
+			&cli.BoolFlag{
+				Name:        "password-stdin",
+				Usage:       "Read the password from stdin",
+				Destination: &credentialOpts.PasswordStdin,
+			},
+		},
+		Before: func(c *cli.Context) error {
+			var err error
+			if profile, err = resolveProfile(defaultConfigPath, profile); err != nil {
+				return err
+			}
+
+			configPath = profilePath(defaultConfigPath, profile)
+			return nil
+		},
 		Commands: []*cli.Command{
 			{
 				Name:    "configure",
 				Aliases: []string{"config"},
 				Usage:   "Configure Skedda credentials",
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:  "credential-command",
+						Usage: "Shell `COMMAND` printing the password, instead of storing it",
+					},
+					&cli.StringFlag{
+						Name:    "profile",
+						Aliases: []string{"p"},
+						Usage:   "Profile to configure, created if missing",
+					},
+					&cli.BoolFlag{
+						Name:  "no-verify",
+						Usage: "Save the credentials without signing in to verify them",
+					},
+					&cli.BoolFlag{
+						Name:  "totp",
+						Usage: "Store the TOTP secret of the second factor with the password, to sign in without asking the code",
+					},
+				},
+				Action: func(c *cli.Context) error {
+					if c.IsSet("profile") {
+						profile = c.String("profile")
+						if err := validateProfileName(profile); err != nil {
+							return err
+						}
+						configPath = profilePath(defaultConfigPath, profile)
+					}
+
+					if profile != defaultProfile {
+						fmt.Printf("Configuring profile %s\n", profile)
+					}
+
+					if c.Bool("totp") {
+						v, err := loadVault(configPath)
+						if err != nil {
+							return fmt.Errorf("no password stored, try using `skedda configure` first")
+						}
+
+						_, key, err := unlockVault(configPath, v)
+						if err != nil {
+							return err
+						}
+
+						secret, err := readInput("TOTP secret (empty to remove)", "", true)
+						if err != nil {
+							return err
+						}
+						fmt.Println()
+						if secret == "" {
+							v.TOTPNonce, v.TOTPCiphertext = nil, nil
+						} else {
+							if _, err := skedda.TOTP(secret, time.Now()); err != nil {
+								return err
+							}
+
+							if err := v.sealTOTP(key, secret); err != nil {
+								return err
+							}
+						}
+
+						if err := saveVault(configPath, v); err != nil {
+							return err
+						}
+
+						fmt.Println("\nConfigured!")
+						return nil
+					}
+
+					config, _ := readConfig(configPath)
+
+					if c.IsSet("credential-command") {
+						username, err := readInput("Username", config.Username, false)
+						if err != nil {
+							return err
+						}
+
+						config.Username = username
+						config.Password = ""
+						config.CredentialCommand = c.String("credential-command")
+
+						if !c.Bool("no-verify") {
+							password, err := runCredentialCommand(config.CredentialCommand)
+							if err != nil {
+								return err
+							}
+
+							if err := verifyCredentials(configPath, config.Username, password); err != nil {
+								return err
+							}
+						}
+
+						if err := saveConfig(configPath, config); err != nil {
+							return err
+						}
+
+						fmt.Println("\nConfigured!")
+						return nil
+					}
+
+					var stored func() (string, error)
+					if v, err := loadVault(configPath); err == nil {
+						stored = func() (string, error) {
+							password, _, err := unlockVault(configPath, v)
+							return password, err
+						}
+					}
+
+					var verify func(username, password string) error
+					if !c.Bool("no-verify") {
+						verify = func(username, password string) error {
+							return verifyCredentials(configPath, username, password)
+						}
+					}
+
+					config, keepPassword, err := configureCredentials(config, readInput, stored, verify)
+					if err != nil {
+						return err
+					}
+
+					if keepPassword {
+						if err := saveConfig(configPath, config); err != nil {
+							return err
+						}
+					} else if err := saveCredentials(configPath, config); err != nil {
+						return err
+					}
+
+					fmt.Println("\nConfigured!")
+					return nil
+				},
+			}, {
+				Name:  "profiles",
+				Usage: "Manage the profiles",
+				Subcommands: []*cli.Command{
+					{
+						Name:  "list",
+						Usage: "List the profiles",
+						Action: func(c *cli.Context) error {
+							names, err := listProfiles(defaultConfigPath)
+							if err != nil {
+								return err
+							}
+
+							for _, name := range names {
+								marker := " "
+								if name == profile {
+									marker = "*"
+								}
+
+								config, err := readConfig(profilePath(defaultConfigPath, name))
+								if err != nil {
+									fmt.Printf("%s %s (not configured)\n", marker, name)
+									continue
+								}
+								fmt.Printf("%s %s (%s)\n", marker, name, config.Username)
+							}
+							return nil
+						},
+					}, {
+						Name:      "use",
+						Usage:     "Use a profile when none is given",
+						ArgsUsage: "NAME",
+						Action: func(c *cli.Context) error {
+							name := c.Args().First()
+							if err := validateProfileName(name); err != nil {
+								return err
+							}
+
+							if !profileExists(defaultConfigPath, name) {
+								return fmt.Errorf("no profile named %s, try using `skedda configure --profile %s`", name, name)
+							}
+
+							if err := useProfile(defaultConfigPath, name); err != nil {
+								return err
+							}
+
+							fmt.Printf("Using profile %s\n", name)
+							return nil
+						},
+					}, {
+						Name:      "delete",
+						Usage:     "Delete the credentials and cache of a profile",
+						ArgsUsage: "NAME",
+						Action: func(c *cli.Context) error {
+							name := c.Args().First()
+							if err := validateProfileName(name); err != nil {
+								return err
+							}
+
+							if err := deleteProfile(defaultConfigPath, name); err != nil {
+								return err
+							}
+
+							fmt.Printf("Deleted profile %s\n", name)
+							return nil
+						},
+					},
+				},
+			}, {
+				Name:  "whoami",
+				Usage: "Show the credentials in use and where they come from",
+				Flags: []cli.Flag{
+					&cli.BoolFlag{
+						Name:  "check",
+						Usage: "Sign in to check the credentials",
+					},
+				},
+				Action: func(c *cli.Context) error {
+					creds, err := resolveCredentials(credentialOpts, configPath)
+					if err != nil {
+						return err
+					}
+
+					fmt.Printf("Profile:  %s\n", profile)
+					fmt.Printf("Username: %s (from %s)\n", creds.Username, creds.UsernameSource)
+					if creds.PasswordSource == sessionSource {
+						fmt.Printf("Session:  browser session imported by `skedda login`\n")
+					} else {
+						fmt.Printf("Password: ***** (from %s)\n", creds.PasswordSource)
+					}
+
+					if !c.Bool("check") {
+						return nil
+					}
+
+					s, err := clientFor(creds, configPath)
+					if err != nil {
+						return err
+					}
+
+					if err := s.Auth(); err != nil {
+						return err
+					}
+
+					primaryDomain, err := s.PrimaryDomain()
+					if err != nil {
+						return err
+					}
+
+					fmt.Printf("Signed in to %s.skedda.com\n", primaryDomain)
+					return nil
+				},
+			}, {
+				Name:  "login",
+				Usage: "Sign in with a browser session, for accounts signing in through SSO",
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:     "cookies",
+						Usage:    "`FILE` with the cookies of the session, exported in the cookies.txt format or copied as a Cookie header (- for stdin)",
+						Required: true,
+					},
+				},
 				Action: func(c *cli.Context) error {
-					config, _ := loadConfig(configPath)
-					u, p := readCredentials(config.Username, config.Password)
+					cookies, err := readSessionCookies(c.String("cookies"))
+					if err != nil {
+						return err
+					}
+
+					if len(cookies) == 0 {
+						return fmt.Errorf("no cookies found in %s", c.String("cookies"))
+					}
+
+					config, _ := readConfig(configPath)
+					username := credentialOpts.Username
+					if username == "" {
+						username = os.Getenv("SKEDDA_USERNAME")
+					}
+					if username == "" && c.String("cookies") == "-" {
+						// stdin is taken by the cookies, the username cannot be
+						// asked for
+						if config.Username == "" {
+							return fmt.Errorf("give the username with --username or SKEDDA_USERNAME when reading the cookies from stdin")
+						}
+						username = config.Username
+					}
+					if username == "" {
+						username, err = readInput("Username", config.Username, false)
+						if err != nil {
+							return err
+						}
+					}
+
+					s, err := skedda.NewWithSession(username, cookies)
+					if err != nil {
+						return err
+					}
+
+					primaryDomain, err := s.PrimaryDomain()
+					if err != nil {
+						return fmt.Errorf("the session is not signed in: %w", err)
+					}
 
-					config.Username = u
-					config.Password = p
+					if err := saveSession(configPath, cookies); err != nil {
+						return err
+					}
 
+					config.Username = username
 					if err := saveConfig(configPath, config); err != nil {
 						return err
 					}
 
-					fmt.Println("\n\nConfigured!")
+					fmt.Printf("Signed in to %s.skedda.com\n", primaryDomain)
+					return nil
+				},
+			}, {
+				Name:  "logout",
+				Usage: "Sign out of Skedda and lock the credentials",
+				Flags: []cli.Flag{
+					&cli.BoolFlag{
+						Name:  "forget",
+						Usage: "Delete the stored username and password as well",
+					},
+				},
+				Action: func(c *cli.Context) error {
+					if hasSession(configPath) {
+						if err := logoutSession(configPath); err != nil {
+							fmt.Printf("Failed to sign out of Skedda (%s), forgetting the session anyway\n", err)
+						}
+
+						if err := os.Remove(path.Join(configPath, "cookies")); err != nil {
+							return err
+						}
+						fmt.Println("Signed out of the browser session")
+					} else if creds, err := resolveCredentials(credentialOpts, configPath); err == nil {
+						if err := logoutPassword(creds, configPath); err != nil {
+							fmt.Printf("Failed to sign out of Skedda (%s)\n", err)
+						} else {
+							fmt.Println("Signed out of Skedda")
+						}
+					}
+
+					if err := lock(configPath); err != nil {
+						return err
+					}
+
+					if c.Bool("forget") {
+						if err := forgetCredentials(configPath); err != nil {
+							return err
+						}
+						fmt.Println("Deleted the stored credentials")
+					}
+
+					fmt.Println("Logged out")
+					return nil
+				},
+			}, {
+				Name:  "unlock",
+				Usage: "Keep the credentials unlocked for a while, without asking the passphrase",
+				Flags: []cli.Flag{
+					&cli.DurationFlag{
+						Name:  "for",
+						Usage: "How long to keep the credentials unlocked",
+						Value: 8 * time.Hour,
+					},
+				},
+				Action: func(c *cli.Context) error {
+					v, err := loadVault(configPath)
+					if err != nil {
+						return skedda.ErrCredsMissing
+					}
+
+					_, key, err := unlockVault(configPath, v)
+					if err != nil {
+						return err
+					}
+
+					if err := unlock(configPath, key, c.Duration("for")); err != nil {
+						return err
+					}
+
+					fmt.Printf("Unlocked until %s\n", time.Now().Add(c.Duration("for")).Format("Mon 02 Jan 3:04pm"))
+					return nil
+				},
+			}, {
+				Name:  "lock",
+				Usage: "Forget the unlocked credentials",
+				Action: func(c *cli.Context) error {
+					if err := lock(configPath); err != nil {
+						return err
+					}
+
+					fmt.Println("Locked")
 					return nil
 				},
 			}, {
 				Name:    "cache",
 				Aliases: []string{"c"},
 				Usage:   "Cache the venues and spaces",
+				Flags: []cli.Flag{
+					&cli.BoolFlag{
+						Name:  "status",
+						Usage: "Describe the cache instead of refreshing it",
+					},
+					&cli.BoolFlag{
+						Name:  "clear",
+						Usage: "Clear the cache instead of refreshing it",
+					},
+					&cli.StringFlag{
+						Name:  "domain",
+						Usage: "Only clear the venue of the `DOMAIN`, to be fetched again on the next use",
+					},
+				},
+				Action: func(c *cli.Context) error {
+					if c.Bool("status") {
+						return writeCacheStatus(os.Stdout, configPath, cacheTTL)
+					}
+
+					if c.Bool("clear") {
+						if c.String("domain") != "" {
+							if err := invalidateDomain(configPath, c.String("domain")); err != nil {
+								return err
+							}
+
+							fmt.Printf("Invalidated %s\n", c.String("domain"))
+							return nil
+						}
+
+						if err := clearCache(configPath); err != nil {
+							return err
+						}
+
+						fmt.Println("Cleared the cache")
+						return nil
+					}
+
+					if c.String("domain") != "" {
+						return fmt.Errorf("--domain can only be used with --clear")
+					}
+
+					s, err := newClient(credentialOpts, configPath)
+					if err != nil {
+						return err
+					}
+
+					venues, spaces, err := loadFromSkedda(s)
+					if err != nil {
+						return err
+					}
+
+					if err := saveToCache(venues, spaces, s.Username(), configPath); err != nil {
+						fmt.Println("Failed to save cache")
+					}
+
+					return nil
+				},
+			}, {
+				Name:    "list",
+				Aliases: []string{"l"},
+				Usage:   "List venues and spaces",
+				Flags: []cli.Flag{
+					&noCacheFlag,
+					&offlineFlag,
+				},
+				Action: func(c *cli.Context) error {
+					var venues skedda.VenueList
+					var spaces skedda.SpaceList
+					if c.Bool("offline") {
+						var cachedAt time.Time
+						var err error
+						venues, spaces, cachedAt, err = loadOffline(configPath)
+						if err != nil {
+							return err
+						}
+						fmt.Printf("Offline: venues and spaces cached %s\n\n", dataAge(cachedAt))
+					} else {
+						s, err := newClient(credentialOpts, configPath)
+						if err != nil {
+							return err
+						}
+
+						venues, spaces, err = load(s, noCache, cacheTTL, configPath)
+						if err != nil {
+							return err
+						}
+					}
+
+					for _, v := range venues {
+						fmt.Println(v.Name)
+						for _, s := range spaces {
+							if s.VenueID == v.ID {
+								fmt.Println("\t", s.Name)
+							}
+						}
+					}
+					return nil
+				},
+			}, {
+				Name:    "find",
+				Aliases: []string{"f"},
+				Usage:   "Find bookings",
+				Flags: append([]cli.Flag{
+					&noCacheFlag,
+					&cli.StringFlag{
+						Name:    "venue",
+						Aliases: []string{"v"},
+						Usage:   "Venue to check (selects all spaces in the venue)",
+					},
+					&cli.StringSliceFlag{
+						Name:    "spaces",
+						Aliases: []string{"space", "s"},
+						Usage:   "Spaces to check",
+					},
+					&offlineFlag,
+				}, timeRangeFlags("check")...),
+				Action: func(c *cli.Context) error {
+					onDate, from, till, err := parseTimeRange(c)
+					if err != nil {
+						return err
+					}
+
+					offline := c.Bool("offline")
+
+					// Offline, the credentials are not needed and not resolved,
+					// which could ask for the passphrase or run a command
+					var s *skedda.Skedda
+					var venues skedda.VenueList
+					var spaces skedda.SpaceList
+					if offline {
+						venues, spaces, _, err = loadOffline(configPath)
+					} else {
+						s, err = newClient(credentialOpts, configPath)
+						if errors.Is(err, skedda.ErrCredsMissing) {
+							s, err = skedda.New()
+						}
+						if err != nil {
+							return err
+						}
+
+						venues, spaces, err = load(s, noCache, cacheTTL, configPath)
+					}
+					if err != nil {
+						return err
+					}
+
+					var filteredSpaces skedda.SpaceList
+					if c.String("venue") != "" {
+						venue, err := matchVenue(venues, c.String("venue"))
+						if err != nil {
+							return err
+						}
+
+						for _, s := range spaces {
+							if s.VenueID == venue.ID {
+								filteredSpaces = append(filteredSpaces, s)
+							}
+						}
+					} else {
+						filteredSpaces = matchSpaces(spaces, c.StringSlice("spaces"))
+					}
+
+					if len(filteredSpaces) == 0 {
+						return fmt.Errorf("no spaces found")
+					}
+
+					dateFormat := "Mon 02 Jan"
+					timeFormat := "3:04pm"
+					fmt.Printf("Finding bookings in %s on %s, between %s and %s...\n", strings.Join(filteredSpaces.Map(func(i int, s skedda.Space) string {
+						return s.Name
+					}), ", "), onDate.Format(dateFormat), from.Format(timeFormat), till.Format(timeFormat))
+
+					var venueBookings map[*skedda.Venue][]*skedda.Booking
+					if offline {
+						var fetchedAt time.Time
+						venueBookings, fetchedAt, err = cachedVenueBookings(configPath, venues, filteredSpaces, from, till)
+						if err != nil {
+							return fmt.Errorf("%w, try again without --offline", err)
+						}
+						fmt.Printf("Offline: bookings fetched %s\n", dataAge(fetchedAt))
+					} else {
+						if err := s.Auth(); err != nil {
+							fmt.Printf("Failed to authenticate. You will not see the title of the bookings.\n\n")
+						}
+
+						venueBookings, err = fetchVenueBookings(s, venues, filteredSpaces, from, till)
+						if err != nil {
+							return err
+						}
+
+						if err := saveBookingsToCache(configPath, from, till, venueBookings); err != nil {
+							fmt.Println("Failed to cache bookings")
+						}
+					}
+
+					spaceBookings := groupBySpace(filteredSpaces, venueBookings)
+
+					// sort
+					keys := skedda.SpaceList{}
+					for space := range spaceBookings {
+						keys = append(keys, space)
+					}
+					sort.Sort(keys)
+
+					for _, space := range keys {
+						bookings := spaceBookings[space]
+						venue := venues.FindByID(space.VenueID)
+						fmt.Printf("\n%s -- %s\n", venue.Name, space.Name)
+						if len(bookings) == 0 {
+							fmt.Printf("\t* Slot is available *\n")
+							continue
+						}
+
+						for i, booking := range bookings {
+							fmt.Printf("\t%d. %s\n", i+1, booking)
+						}
+					}
+					return nil
+				},
+			}, {
+				Name:    "book",
+				Aliases: []string{"f"},
+				Usage:   "Book spaces for meeting",
+				Flags: append([]cli.Flag{
+					&noCacheFlag,
+					&cli.StringSliceFlag{
+						Name:     "spaces",
+						Aliases:  []string{"space", "s"},
+						Usage:    "Spaces to book",
+						Required: true,
+					},
+					&cli.StringFlag{
+						Name:     "title",
+						Aliases:  []string{"t"},
+						Usage:    "Title for the booking",
+						Required: true,
+					},
+					&cli.BoolFlag{
+						Name:    "assume-yes",
+						Aliases: []string{"yes", "y"},
+						Usage:   "Assume yes to al prompts and run non-interactively",
+					},
+					&snapFlag,
+				}, append(append(timeRangeFlags("book"), gridFlags()...), multiDayFlags()...)...),
+				Action: func(c *cli.Context) error {
+					multiDay := isMultiDay(c)
+					onStr := c.String("on")
+					if multiDay {
+						onStr = ""
+					}
+
+					onDate, from, till, err := parseTimeRangeOn(c, onStr)
+					if err != nil {
+						return err
+					}
+
+					if (c.String("venue") != "") == (len(c.StringSlice("spaces")) > 0) {
+						return fmt.Errorf("either provide venue or spaces")
+					}
+
+					title := c.String("title")
+					title = strings.TrimSpace(title)
+					if title == "" {
+						return fmt.Errorf("--title is required")
+					}
+
+					s, err := newClient(credentialOpts, configPath)
+					if err != nil {
+						return err
+					}
+
+					venues, spaces, err := load(s, noCache, cacheTTL, configPath)
+					if err != nil {
+						return err
+					}
+
+					venue, filteredSpaces, err := matchVenueSpaces(venues, spaces, c.StringSlice("spaces"))
+					if err != nil {
+						return err
+					}
+
+					from, till, err = snapToGrid(c, s, venue, from, till)
+					if err != nil {
+						return err
+					}
+
+					var days []time.Time
+					dateFormat := "Mon 02 Jan"
+					timeFormat := "3:04pm"
+					if multiDay {
+						days, err = bookingDays(c.String("on"), c.String("dates"), c.Int("weeks"), timeparse.Now())
+						if err != nil {
+							return err
+						}
+
+						fmt.Printf("Booking %s on %d days from %s till %s, between %s and %s...\n", strings.Join(filteredSpaces.Map(func(i int, s skedda.Space) string {
+							return s.Name
+						}), ", "), len(days), days[0].Format(dateFormat), days[len(days)-1].Format(dateFormat), from.Format(timeFormat), till.Format(timeFormat))
+					} else {
+						fmt.Printf("Booking %s on %s, between %s and %s...\n", strings.Join(filteredSpaces.Map(func(i int, s skedda.Space) string {
+							return s.Name
+						}), ", "), onDate.Format(dateFormat), from.Format(timeFormat), till.Format(timeFormat))
+					}
+
+					if !c.Bool("assume-yes") {
+						fmt.Print("\nAre you sure? (y/N): ")
+						var answer string
+						fmt.Scanln(&answer)
+
+						if !strings.HasPrefix(strings.ToLower(answer), "y") {
+							return nil
+						}
+					}
+
+					if err := s.Auth(); err != nil {
+						return err
+					}
+
+					spaceIDs := []int{}
+					for _, space := range filteredSpaces {
+						spaceIDs = append(spaceIDs, space.ID)
+					}
+
+					if multiDay {
+						holidays, err := loadHolidays(configPath)
+						if err != nil {
+							return err
+						}
+
+						now := timeparse.Now()
+						for _, skip := range c.StringSlice("skip") {
+							day, err := timeparse.Date(skip, now)
+							if err != nil {
+								return fmt.Errorf("invalid --skip: %w", err)
+							}
+							holidays[day] = "skipped with --skip"
+						}
+
+						opens, closes := from.Sub(onDate), till.Sub(onDate)
+						outcomes, err := bookDays(s, venue, spaceIDs, title, days, opens, closes, func(day time.Time) (string, bool) {
+							return skipReason(day, day.Add(opens), c.Bool("weekdays"), holidays, now)
+						})
+						if err != nil {
+							return err
+						}
+
+						fmt.Println()
+						if err := writeDayOutcomes(os.Stdout, outcomes); err != nil {
+							return err
+						}
+
+						booked, failed := 0, 0
+						for _, o := range outcomes {
+							switch o.Status {
+							case dayBooked:
+								booked++
+							case dayUnavailable, dayFailed:
+								failed++
+							}
+						}
+
+						if failed > 0 {
+							return fmt.Errorf("booked %d of %d days", booked, booked+failed)
+						}
+
+						fmt.Printf("\nBooked %d days!\n", booked)
+						return nil
+					}
+
+					if err := s.Book(venue.Domain, venue.ID, spaceIDs, title, from, till); err != nil {
+						return err
+					}
+
+					fmt.Println("\nBooked!")
+					return nil
+				},
+			}, {
+				Name:    "watch",
+				Aliases: []string{"w"},
+				Usage:   "Watch spaces until they are available and optionally book them",
+				Flags: append([]cli.Flag{
+					&noCacheFlag,
+					&cli.StringSliceFlag{
+						Name:     "spaces",
+						Aliases:  []string{"space", "s"},
+						Usage:    "Spaces to watch",
+						Required: true,
+					},
+					&cli.BoolFlag{
+						Name:  "book",
+						Usage: "Book the spaces as soon as they are available",
+					},
+					&cli.StringFlag{
+						Name:    "title",
+						Aliases: []string{"t"},
+						Usage:   "Title for the booking (required with --book)",
+					},
+					&cli.DurationFlag{
+						Name:    "interval",
+						Aliases: []string{"i"},
+						Usage:   "Time to wait between checks",
+						Value:   1 * time.Minute,
+					},
+					&snapFlag,
+				}, append(timeRangeFlags("watch"), gridFlags()...)...),
+				Action: func(c *cli.Context) error {
+					interval, err := pollInterval(c)
+					if err != nil {
+						return err
+					}
+
+					onDate, from, till, err := parseTimeRange(c)
+					if err != nil {
+						return err
+					}
+
+					title := strings.TrimSpace(c.String("title"))
+					if c.Bool("book") {
+						if title == "" {
+							return fmt.Errorf("--title is required with --book")
+						}
+					}
+
+					s, err := newClient(credentialOpts, configPath)
+					if err != nil {
+						return err
+					}
+
+					venues, spaces, err := load(s, noCache, cacheTTL, configPath)
+					if err != nil {
+						return err
+					}
+
+					venue, filteredSpaces, err := matchVenueSpaces(venues, spaces, c.StringSlice("spaces"))
+					if err != nil {
+						return err
+					}
+
+					if c.Bool("book") {
+						from, till, err = snapToGrid(c, s, venue, from, till)
+						if err != nil {
+							return err
+						}
+					}
+
+					if err := s.Auth(); err != nil {
+						return err
+					}
+
+					dateFormat := "Mon 02 Jan"
+					timeFormat := "3:04pm"
+					fmt.Printf("Watching %s on %s, between %s and %s...\n", strings.Join(filteredSpaces.Map(func(i int, s skedda.Space) string {
+						return s.Name
+					}), ", "), onDate.Format(dateFormat), from.Format(timeFormat), till.Format(timeFormat))
+
+					ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+					defer stop()
+
+					spaceIDs := []int{}
+					for _, space := range filteredSpaces {
+						spaceIDs = append(spaceIDs, space.ID)
+					}
+
+					for event := range s.WatchAvailability(ctx, venue.Domain, spaceIDs, from, till, interval) {
+						eventTime := event.Time.Format("15:04:05")
+						if event.Err != nil {
+							fmt.Printf("[%s] Failed to check: %s\n", eventTime, event.Err)
+							continue
+						}
+
+						if !event.Available {
+							fmt.Printf("[%s] Occupied by:\n", eventTime)
+							for i, booking := range event.Bookings {
+								fmt.Printf("\t%d. %s\n", i+1, booking)
+							}
+							continue
+						}
+
+						fmt.Printf("[%s] * Slot is available *\n", eventTime)
+						if !c.Bool("book") {
+							return nil
+						}
+
+						// The slot staying available is not reported again, so
+						// failing to book ends the watch
+						if err := s.Book(venue.Domain, venue.ID, spaceIDs, title, from, till); err != nil {
+							return fmt.Errorf("failed to book: %w", err)
+						}
+
+						fmt.Println("\nBooked!")
+						return nil
+					}
+
+					// Interrupted
+					return nil
+				},
+			}, {
+				Name:  "snipe",
+				Usage: "Book spaces the moment the booking window opens",
+				Flags: append([]cli.Flag{
+					&noCacheFlag,
+					&cli.StringSliceFlag{
+						Name:     "spaces",
+						Aliases:  []string{"space", "s"},
+						Usage:    "Spaces to book",
+						Required: true,
+					},
+					&cli.StringFlag{
+						Name:     "title",
+						Aliases:  []string{"t"},
+						Usage:    "Title for the booking",
+						Required: true,
+					},
+					&cli.IntFlag{
+						Name:  "lead",
+						Usage: "Number of `DAYS` ahead the venue opens bookings",
+						Value: 14,
+					},
+					&cli.StringFlag{
+						Name:  "opens-at",
+						Usage: "`TIME` of the day bookings open, in the venue's time zone (e.g. 12am, 9:30am, 00:00)",
+						Value: "12:00am",
+					},
+					&cli.StringFlag{
+						Name:  "tz",
+						Usage: "Time zone of the venue, e.g. Europe/London (default: venue's time zone)",
+					},
+					&cli.DurationFlag{
+						Name:  "prepare",
+						Usage: "How long before the window opens to authenticate",
+						Value: 1 * time.Minute,
+					},
+					&cli.DurationFlag{
+						Name:  "retry-for",
+						Usage: "How long to keep retrying once the window opens",
+						Value: 30 * time.Second,
+					},
+					&cli.DurationFlag{
+						Name:  "retry-interval",
+						Usage: "Time to wait between retries",
+						Value: 250 * time.Millisecond,
+					},
+					&snapFlag,
+				}, append(timeRangeFlags("book"), gridFlags()...)...),
+				Action: func(c *cli.Context) error {
+					onDate, from, till, err := parseTimeRange(c)
+					if err != nil {
+						return err
+					}
+
+					title := strings.TrimSpace(c.String("title"))
+					if title == "" {
+						return fmt.Errorf("--title is required")
+					}
+
+					opensAtOffset, err := timeparse.Clock(c.String("opens-at"))
+					if err != nil {
+						return fmt.Errorf("invalid --opens-at: %w", err)
+					}
+					opensAt := time.Time{}.Add(opensAtOffset)
+
+					s, err := newClient(credentialOpts, configPath)
+					if err != nil {
+						return err
+					}
+
+					venues, spaces, err := load(s, noCache, cacheTTL, configPath)
+					if err != nil {
+						return err
+					}
+
+					venue, filteredSpaces, err := matchVenueSpaces(venues, spaces, c.StringSlice("spaces"))
+					if err != nil {
+						return err
+					}
+
+					from, till, err = snapToGrid(c, s, venue, from, till)
+					if err != nil {
+						return err
+					}
+
+					loc, err := venueLocation(venue, c.String("tz"))
+					if err != nil {
+						return err
+					}
+
+					spaceIDs := []int{}
+					for _, space := range filteredSpaces {
+						spaceIDs = append(spaceIDs, space.ID)
+					}
+
+					opensAtTime := bookingOpensAt(onDate, c.Int("lead"), opensAt, loc)
+					skew := measureClockSkew(s)
+
+					dateFormat := "Mon 02 Jan"
+					timeFormat := "3:04pm"
+					fmt.Printf("Booking %s on %s, between %s and %s...\n", strings.Join(filteredSpaces.Map(func(i int, s skedda.Space) string {
+						return s.Name
+					}), ", "), onDate.Format(dateFormat), from.Format(timeFormat), till.Format(timeFormat))
+					fmt.Printf("Booking window opens at %s (clock skew: %s)\n", opensAtTime.Format("Mon 02 Jan 3:04:05pm MST"), skew.Round(time.Millisecond))
+
+					ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+					defer stop()
+
+					if err := sleepUntil(ctx, snipeAt(opensAtTime, skew).Add(-c.Duration("prepare"))); err != nil {
+						return err
+					}
+
+					fmt.Println("Preparing...")
+					if err := s.Auth(); err != nil {
+						return err
+					}
+
+					token, err := s.VerificationToken(venue.Domain)
+					if err != nil {
+						return fmt.Errorf("failed to get verification token: %w", err)
+					}
+					skew = measureClockSkew(s)
+
+					if err := sleepUntil(ctx, snipeAt(opensAtTime, skew)); err != nil {
+						return err
+					}
+
+					err = retryBook(ctx, func() error {
+						return s.BookWithToken(venue.Domain, token, venue.ID, spaceIDs, title, from, till)
+					}, c.Duration("retry-for"), c.Duration("retry-interval"))
+					if err != nil {
+						return err
+					}
+
+					fmt.Println("\nBooked!")
+					return nil
+				},
+			}, {
+				Name:    "tail",
+				Aliases: []string{"t"},
+				Usage:   "Print changes to bookings as they happen",
+				Flags: []cli.Flag{
+					&noCacheFlag,
+					&cli.StringFlag{
+						Name:    "venue",
+						Aliases: []string{"v"},
+						Usage:   "Venue to watch (default: all venues)",
+					},
+					&cli.StringSliceFlag{
+						Name:    "spaces",
+						Aliases: []string{"space", "s"},
+						Usage:   "Spaces to watch",
+					},
+					&cli.IntFlag{
+						Name:  "days",
+						Usage: "Number of `DAYS` ahead to watch, starting today",
+						Value: 7,
+					},
+					&cli.DurationFlag{
+						Name:    "interval",
+						Aliases: []string{"i"},
+						Usage:   "Time to wait between checks",
+						Value:   1 * time.Minute,
+					},
+				},
+				Action: func(c *cli.Context) error {
+					interval, err := pollInterval(c)
+					if err != nil {
+						return err
+					}
+
+					s, err := newClient(credentialOpts, configPath)
+					if err != nil {
+						return err
+					}
+
+					venues, spaces, err := load(s, noCache, cacheTTL, configPath)
+					if err != nil {
+						return err
+					}
+
+					filteredSpaces, err := selectSpaces(venues, spaces, c.String("venue"), c.StringSlice("spaces"))
+					if err != nil {
+						return err
+					}
+
+					if err := s.Auth(); err != nil {
+						return err
+					}
+
+					ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+					defer stop()
+
+					lookAhead := time.Duration(c.Int("days")) * 24 * time.Hour
+					watcher, err := skedda.NewBookingWatcher(s, spaceDomains(venues, filteredSpaces), interval, lookAhead)
+					if err != nil {
+						return err
+					}
+
+					fmt.Printf("Watching bookings in %d spaces for the next %d days...\n", len(filteredSpaces), c.Int("days"))
+					for event := range watcher.Watch(ctx) {
+						if event.Err == nil && !eventInSpaces(event, filteredSpaces) {
+							continue
+						}
+
+						printBookingEvent(event, filteredSpaces)
+					}
+
+					return nil
+				},
+			}, {
+				Name:  "notify",
+				Usage: "Post changes to bookings to a webhook as they happen",
+				Flags: []cli.Flag{
+					&noCacheFlag,
+					&cli.StringFlag{
+						Name:     "webhook",
+						Usage:    "`URL` to post the changes to",
+						Required: true,
+					},
+					&cli.StringFlag{
+						Name:    "secret",
+						Usage:   "Secret to sign the payloads with (sent in X-Skedda-Signature)",
+						EnvVars: []string{"SKEDDA_WEBHOOK_SECRET"},
+					},
+					&cli.IntFlag{
+						Name:  "retries",
+						Usage: "Number of retries when a delivery fails",
+						Value: 3,
+					},
+					&cli.StringFlag{
+						Name:        "dead-letter",
+						Usage:       "`FILE` to append undeliverable payloads to",
+						DefaultText: "dead-letter.jsonl in the profile directory",
+					},
+					&cli.StringFlag{
+						Name:    "venue",
+						Aliases: []string{"v"},
+						Usage:   "Venue to watch (default: all venues)",
+					},
+					&cli.StringSliceFlag{
+						Name:    "spaces",
+						Aliases: []string{"space", "s"},
+						Usage:   "Spaces to watch",
+					},
+					&cli.IntFlag{
+						Name:  "days",
+						Usage: "Number of `DAYS` ahead to watch, starting today",
+						Value: 7,
+					},
+					&cli.DurationFlag{
+						Name:    "interval",
+						Aliases: []string{"i"},
+						Usage:   "Time to wait between checks",
+						Value:   1 * time.Minute,
+					},
+				},
+				Action: func(c *cli.Context) error {
+					interval, err := pollInterval(c)
+					if err != nil {
+						return err
+					}
+
+					s, err := newClient(credentialOpts, configPath)
+					if err != nil {
+						return err
+					}
+
+					venues, spaces, err := load(s, noCache, cacheTTL, configPath)
+					if err != nil {
+						return err
+					}
+
+					filteredSpaces, err := selectSpaces(venues, spaces, c.String("venue"), c.StringSlice("spaces"))
+					if err != nil {
+						return err
+					}
+
+					if err := s.Auth(); err != nil {
+						return err
+					}
+
+					deadLetter := c.String("dead-letter")
+					if deadLetter == "" {
+						deadLetter = path.Join(configPath, "dead-letter.jsonl")
+					}
+
+					notifier := &WebhookNotifier{
+						URL:        c.String("webhook"),
+						Secret:     c.String("secret"),
+						Retries:    c.Int("retries"),
+						RetryDelay: 1 * time.Second,
+						DeadLetter: deadLetter,
+						Client:     &http.Client{Timeout: 10 * time.Second},
+					}
+
+					ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+					defer stop()
+
+					lookAhead := time.Duration(c.Int("days")) * 24 * time.Hour
+					watcher, err := skedda.NewBookingWatcher(s, spaceDomains(venues, filteredSpaces), interval, lookAhead)
+					if err != nil {
+						return err
+					}
+
+					fmt.Printf("Posting changes to bookings in %d spaces for the next %d days to %s...\n", len(filteredSpaces), c.Int("days"), notifier.URL)
+					for event := range watcher.Watch(ctx) {
+						if event.Err == nil && !eventInSpaces(event, filteredSpaces) {
+							continue
+						}
+
+						printBookingEvent(event, filteredSpaces)
+						if event.Err != nil {
+							continue
+						}
+
+						if err := notifier.Notify(event); err != nil {
+							fmt.Println("\tFailed to deliver:", err)
+						}
+					}
+
+					return nil
+				},
+			}, {
+				Name:  "serve",
+				Usage: "Serve a JSON API for venues, spaces and bookings",
+				Flags: []cli.Flag{
+					&noCacheFlag,
+					&cli.StringFlag{
+						Name:    "listen",
+						Aliases: []string{"l"},
+						Usage:   "`ADDRESS` to listen on",
+						Value:   ":8080",
+					},
+					&cli.StringFlag{
+						Name:     "token",
+						Usage:    "Bearer token required from the clients",
+						EnvVars:  []string{"SKEDDA_API_TOKEN"},
+						Required: true,
+					},
+				},
+				Action: func(c *cli.Context) error {
+					s, err := newClient(credentialOpts, configPath)
+					if err != nil {
+						return err
+					}
+
+					venues, spaces, err := load(s, noCache, cacheTTL, configPath)
+					if err != nil {
+						return err
+					}
+
+					if err := s.Auth(); err != nil {
+						return err
+					}
+
+					api := newAPIServer(s, venues, spaces)
+					return runServer(c.String("listen"), logRequests(requireBearerToken(c.String("token"), api.Handler())))
+				},
+			}, {
+				Name:  "ics",
+				Usage: "Serve iCalendar feeds of spaces and venues",
+				Flags: []cli.Flag{
+					&noCacheFlag,
+					&cli.StringFlag{
+						Name:    "listen",
+						Aliases: []string{"l"},
+						Usage:   "`ADDRESS` to listen on",
+						Value:   "localhost:8080",
+					},
+					&cli.StringFlag{
+						Name:    "token",
+						Usage:   "Bearer token required from the clients, needed to listen beyond localhost",
+						EnvVars: []string{"SKEDDA_ICS_TOKEN"},
+					},
+					&cli.IntFlag{
+						Name:  "days",
+						Usage: "Number of `DAYS` ahead to include, starting today",
+						Value: 14,
+					},
+					&cli.DurationFlag{
+						Name:  "cache-ttl",
+						Usage: "How long to serve a feed before fetching it again",
+						Value: 5 * time.Minute,
+					},
+				},
+				Action: func(c *cli.Context) error {
+					if err := checkListen(c.String("listen"), c.String("token"), "the feeds"); err != nil {
+						return err
+					}
+
+					s, err := newClient(credentialOpts, configPath)
+					if err != nil {
+						return err
+					}
+
+					venues, spaces, err := load(s, noCache, cacheTTL, configPath)
+					if err != nil {
+						return err
+					}
+
+					if err := s.Auth(); err != nil {
+						return err
+					}
+
+					feeds := &icsServer{
+						skedda:    s,
+						venues:    venues,
+						spaces:    spaces,
+						lookAhead: time.Duration(c.Int("days")) * 24 * time.Hour,
+						ttl:       c.Duration("cache-ttl"),
+						cache:     map[string]icsCacheEntry{},
+					}
+
+					handler := feeds.Handler()
+					if token := c.String("token"); token != "" {
+						handler = requireBearerToken(token, handler)
+					}
+
+					fmt.Println("Feeds are served at /spaces/{id}.ics and /venues/{id}.ics")
+					return runServer(c.String("listen"), logRequests(handler))
+				},
+			}, {
+				Name:  "kiosk",
+				Usage: "Serve a room display page for a space",
+				Flags: append([]cli.Flag{
+					&noCacheFlag,
+					&cli.StringFlag{
+						Name:     "space",
+						Aliases:  []string{"s"},
+						Usage:    "Space to display",
+						Required: true,
+					},
+					&cli.StringFlag{
+						Name:    "listen",
+						Aliases: []string{"l"},
+						Usage:   "`ADDRESS` to listen on",
+						Value:   "localhost:8080",
+					},
+					&cli.StringFlag{
+						Name:    "token",
+						Usage:   "Token required from the displays as ?token=, needed to listen beyond localhost",
+						EnvVars: []string{"SKEDDA_KIOSK_TOKEN"},
+					},
+					&cli.StringFlag{
+						Name:    "title",
+						Aliases: []string{"t"},
+						Usage:   "Title for the bookings made from the display",
+						Value:   "Ad hoc meeting",
+					},
+					&cli.StringFlag{
+						Name:  "tz",
+						Usage: "Time zone of the venue, e.g. Europe/London (default: venue's time zone)",
+					},
+					&cli.DurationFlag{
+						Name:  "refresh",
+						Usage: "How often the page refreshes itself",
+						Value: 1 * time.Minute,
+					},
+				}, gridFlags()...),
+				Action: func(c *cli.Context) error {
+					if err := checkListen(c.String("listen"), c.String("token"), "the kiosk"); err != nil {
+						return err
+					}
+
+					s, err := newClient(credentialOpts, configPath)
+					if err != nil {
+						return err
+					}
+
+					venues, spaces, err := load(s, noCache, cacheTTL, configPath)
+					if err != nil {
+						return err
+					}
+
+					venue, filteredSpaces, err := matchVenueSpaces(venues, spaces, []string{c.String("space")})
+					if err != nil {
+						return err
+					}
+
+					grid, err := venueGrid(c, venue)
+					if err != nil {
+						return err
+					}
+					s.SetGrid(venue.ID, grid)
+
+					if len(filteredSpaces) > 1 {
+						spaceNames := filteredSpaces.Map(func(i int, s skedda.Space) string {
+							return s.Name
+						})
+
+						return fmt.Errorf("found multiple matching spaces, be more specific: %s", strings.Join(spaceNames, ", "))
+					}
+
+					loc, err := venueLocation(venue, c.String("tz"))
+					if err != nil {
+						return err
+					}
+
+					if err := s.Auth(); err != nil {
+						return err
+					}
+
+					csrf, err := newCSRFToken()
+					if err != nil {
+						return err
+					}
+
+					kiosk := &kioskServer{
+						skedda:  s,
+						grid:    grid,
+						venue:   venue,
+						space:   filteredSpaces[0],
+						loc:     loc,
+						title:   c.String("title"),
+						refresh: c.Duration("refresh"),
+						token:   c.String("token"),
+						csrf:    csrf,
+					}
+
+					fmt.Printf("Displaying %s -- %s\n", venue.Name, kiosk.space.Name)
+					return runServer(c.String("listen"), logRequests(kiosk.Handler()))
+				},
+			}, {
+				Name:  "dashboard",
+				Usage: "Serve a web dashboard of the bookings of all venues",
+				Flags: []cli.Flag{
+					&noCacheFlag,
+					&cli.StringFlag{
+						Name:    "listen",
+						Aliases: []string{"l"},
+						Usage:   "`ADDRESS` to listen on",
+						Value:   "127.0.0.1:8080",
+					},
+					&cli.StringFlag{
+						Name:    "token",
+						Usage:   "Bearer token required from the clients, needed to listen beyond localhost",
+						EnvVars: []string{"SKEDDA_DASHBOARD_TOKEN"},
+					},
+					&cli.IntFlag{
+						Name:  "day-start",
+						Usage: "`HOUR` the timelines start at",
+						Value: 7,
+					},
+					&cli.IntFlag{
+						Name:  "day-end",
+						Usage: "`HOUR` the timelines end at",
+						Value: 20,
+					},
+				},
 				Action: func(c *cli.Context) error {
-					config, err := loadConfig(configPath)
-					if err != nil {
-						return skedda.ErrCredsMissing
+					if c.Int("day-start") < 0 || c.Int("day-end") > 24 || c.Int("day-start") >= c.Int("day-end") {
+						return fmt.Errorf("--day-start and --day-end must be hours of the day in order")
+					}
+
+					if err := checkListen(c.String("listen"), c.String("token"), "the dashboard"); err != nil {
+						return err
 					}
 
-					s, err := skedda.NewWithCreds(config.Username, config.Password)
+					s, err := newClient(credentialOpts, configPath)
 					if err != nil {
 						return err
 					}
 
-					venues, spaces, err := loadFromSkedda(s)
+					venues, spaces, err := load(s, noCache, cacheTTL, configPath)
 					if err != nil {
 						return err
 					}
 
-					if err := saveToCache(venues, spaces, configPath); err != nil {
-						fmt.Println("Failed to save cache")
+					if err := s.Auth(); err != nil {
+						return err
 					}
 
-					return nil
+					dashboard := &dashboardServer{
+						skedda:   s,
+						venues:   venues,
+						spaces:   spaces,
+						dayStart: c.Int("day-start"),
+						dayEnd:   c.Int("day-end"),
+					}
+
+					handler := dashboard.Handler()
+					if token := c.String("token"); token != "" {
+						handler = requireBearerToken(token, handler)
+					}
+
+					return runServer(c.String("listen"), logRequests(handler))
 				},
 			}, {
-				Name:    "list",
-				Aliases: []string{"l"},
-				Usage:   "List venues and spaces",
+				Name:  "exporter",
+				Usage: "Export the occupancy of spaces as Prometheus metrics",
 				Flags: []cli.Flag{
 					&noCacheFlag,
+					&cli.StringFlag{
+						Name:    "listen",
+						Aliases: []string{"l"},
+						Usage:   "`ADDRESS` to listen on",
+						Value:   ":9090",
+					},
+					&cli.StringSliceFlag{
+						Name:    "venues",
+						Aliases: []string{"venue", "v"},
+						Usage:   "Venues to export (default: all venues)",
+					},
+					&cli.StringFlag{
+						Name:  "tz",
+						Usage: "Time zone of the venues, e.g. Europe/London (default: venue's time zone)",
+					},
+					&cli.DurationFlag{
+						Name:    "interval",
+						Aliases: []string{"i"},
+						Usage:   "Time to wait between collections",
+						Value:   1 * time.Minute,
+					},
 				},
 				Action: func(c *cli.Context) error {
-					config, err := loadConfig(configPath)
+					interval, err := pollInterval(c)
 					if err != nil {
-						return skedda.ErrCredsMissing
+						return err
 					}
 
-					s, err := skedda.NewWithCreds(config.Username, config.Password)
+					s, err := newClient(credentialOpts, configPath)
 					if err != nil {
 						return err
 					}
 
-					venues, spaces, err := load(s, noCache, configPath)
+					transport := newMetricsTransport()
+					s.SetTransport(transport)
+
+					venues, spaces, err := load(s, noCache, cacheTTL, configPath)
 					if err != nil {
 						return err
 					}
 
-					for _, v := range venues {
-						fmt.Println(v.Name)
-						for _, s := range spaces {
-							if s.VenueID == v.ID {
-								fmt.Println("\t", s.Name)
+					if len(c.StringSlice("venues")) > 0 {
+						filteredVenues := skedda.VenueList{}
+						for _, venueStr := range c.StringSlice("venues") {
+							venue, err := matchVenue(venues, venueStr)
+							if err != nil {
+								return err
 							}
+							filteredVenues = append(filteredVenues, venue)
 						}
+						venues = filteredVenues
 					}
-					return nil
+
+					locations := map[int]*time.Location{}
+					for _, venue := range venues {
+						loc, err := venueLocation(venue, c.String("tz"))
+						if err != nil {
+							return err
+						}
+						locations[venue.ID] = loc
+					}
+
+					if err := s.Auth(); err != nil {
+						return err
+					}
+
+					e := &exporter{
+						skedda:    s,
+						venues:    venues,
+						spaces:    spaces,
+						locations: locations,
+						transport: transport,
+					}
+
+					stop := make(chan struct{})
+					defer close(stop)
+					go e.Run(interval, stop)
+
+					mux := http.NewServeMux()
+					mux.Handle("/metrics", e)
+					return runServer(c.String("listen"), mux)
 				},
 			}, {
-				Name:    "find",
-				Aliases: []string{"f"},
-				Usage:   "Find bookings",
-				Flags: []cli.Flag{
+				Name:  "report",
+				Usage: "Report on the bookings over a period",
+				Subcommands: []*cli.Command{
+					{
+						Name:  "utilization",
+						Usage: "Report how much each space is booked during opening hours",
+						Flags: reportFlags(&noCacheFlag),
+						Action: func(c *cli.Context) error {
+							from, till, err := parseReportPeriod(c.String("from"), c.String("to"))
+							if err != nil {
+								return err
+							}
+
+							opens, closes, err := openingHours(c)
+							if err != nil {
+								return err
+							}
+
+							s, venues, spaces, err := reportSource(c, configPath, func() (*skedda.Skedda, error) {
+								return newClient(credentialOpts, configPath)
+							}, noCache, cacheTTL)
+							if err != nil {
+								return err
+							}
+
+							filteredSpaces, err := selectSpaces(venues, spaces, c.String("venue"), c.StringSlice("spaces"))
+							if err != nil {
+								return err
+							}
+
+							occurrences, err := reportOccurrences(c, s, configPath, venues, filteredSpaces, from, till)
+							if err != nil {
+								return err
+							}
+
+							result := computeUtilization(venues, occurrences, from, till, opens, closes, c.Bool("skip-weekends"))
+							return writeUtilization(os.Stdout, c.String("format"), result)
+						},
+					}, {
+						Name:  "heatmap",
+						Usage: "Report when the spaces are busy by weekday and time of the day",
+						Flags: reportFlags(&noCacheFlag),
+						Action: func(c *cli.Context) error {
+							from, till, err := parseReportPeriod(c.String("from"), c.String("to"))
+							if err != nil {
+								return err
+							}
+
+							opens, closes, err := openingHours(c)
+							if err != nil {
+								return err
+							}
+
+							s, venues, spaces, err := reportSource(c, configPath, func() (*skedda.Skedda, error) {
+								return newClient(credentialOpts, configPath)
+							}, noCache, cacheTTL)
+							if err != nil {
+								return err
+							}
+
+							filteredSpaces, err := selectSpaces(venues, spaces, c.String("venue"), c.StringSlice("spaces"))
+							if err != nil {
+								return err
+							}
+
+							occurrences, err := reportOccurrences(c, s, configPath, venues, filteredSpaces, from, till)
+							if err != nil {
+								return err
+							}
+
+							rows := computeHeatmap(occurrences, from, till, opens, closes, c.Bool("skip-weekends"))
+							return writeHeatmap(os.Stdout, c.String("format"), rows)
+						},
+					},
+				},
+			}, {
+				Name:  "forecast",
+				Usage: "Forecast how likely spaces stay free from the archived bookings of past weeks",
+				Flags: append(append([]cli.Flag{
 					&noCacheFlag,
 					&cli.StringFlag{
 						Name:    "venue",
 						Aliases: []string{"v"},
-						Usage:   "Venue to check (selects all spaces in the venue)",
+						Usage:   "Venue to forecast (selects all spaces in the venue)",
 					},
 					&cli.StringSliceFlag{
 						Name:    "spaces",
 						Aliases: []string{"space", "s"},
-						Usage:   "Spaces to check",
+						Usage:   "Spaces to forecast",
 					},
-					&cli.StringFlag{
-						Name:        "on",
-						Aliases:     []string{"d"},
-						Usage:       "`DATE` to check (possible values: today, tomorrow, YYYY-MM-DD)",
-						DefaultText: "today",
+					&cli.IntFlag{
+						Name:    "weeks",
+						Aliases: []string{"w"},
+						Usage:   "Number of past weeks to learn from",
+						Value:   8,
 					},
-					&cli.GenericFlag{
-						Name:        "from",
-						Aliases:     []string{"a"},
-						Usage:       "`TIME` from (H:mma)",
-						DefaultText: time.Now().Truncate(15 * time.Minute).Format("3:04pm"),
-						Value: &FlexibleTimestamp{
-							Layouts: []string{"3:04pm", "3pm"},
-						},
+					&cli.IntFlag{
+						Name:  "alternatives",
+						Usage: "Number of alternative periods to suggest",
+						Value: 3,
 					},
-					&cli.GenericFlag{
-						Name:        "till",
-						Aliases:     []string{"b"},
-						Usage:       "`TIME` till (H:mma)",
-						DefaultText: time.Now().Add(30 * time.Minute).Truncate(15 * time.Minute).Format("3:04pm"),
-						Value: &FlexibleTimestamp{
-							Layouts: []string{"3:04pm", "3pm"},
-						},
+					&cli.IntFlag{
+						Name:  "concurrency",
+						Usage: "Number of days fetched at once",
+						Value: 4,
 					},
-				},
+					archiveFlag(),
+				}, timeRangeFlags("forecast")...), append(openingHoursFlags(), gridFlags()...)...),
 				Action: func(c *cli.Context) error {
-					var from, till time.Time
-					onStr := strings.ToLower(c.String("on"))
-					fromTmp := c.Value("from").(*time.Time)
-					tillTmp := c.Value("till").(*time.Time)
-
-					now := time.Now().UTC().Truncate(24 * time.Hour)
-					var onDate time.Time
-					switch onStr {
-					case "":
-						fallthrough
-					case "today":
-						onDate = now
-					case "tomorrow":
-						onDate = now.Add(24 * time.Hour)
-					default:
-						onDate, err = time.Parse("2006-01-02", onStr)
-						if err != nil {
-							return err
-						}
-					}
-					if fromTmp == nil && tillTmp == nil { // Consider full day
-						from = onDate
-						till = from.Add(24 * time.Hour)
-					} else if fromTmp != nil && tillTmp != nil {
-						from = *fromTmp
-						from = time.Date(onDate.Year(), onDate.Month(), onDate.Day(), from.Hour(), from.Minute(), from.Second(), from.Nanosecond(), time.UTC)
-						till = *tillTmp
-						till = time.Date(onDate.Year(), onDate.Month(), onDate.Day(), till.Hour(), till.Minute(), till.Second(), till.Nanosecond(), time.UTC)
-					} else if fromTmp == nil && tillTmp != nil {
-						return fmt.Errorf("--from is required when --till is provided")
-					} else if fromTmp != nil && tillTmp == nil {
-						from = *fromTmp
-						from = time.Date(onDate.Year(), onDate.Month(), onDate.Day(), from.Hour(), from.Minute(), from.Second(), from.Nanosecond(), time.UTC)
-						till = from.Add(30 * time.Minute)
-					} else {
-						return fmt.Errorf("report the inputs to the developer")
+					onDate, from, till, err := parseTimeRange(c)
+					if err != nil {
+						return err
 					}
 
-					// Pull 'till' to the same date in case of full day
-					if !from.Truncate(24 * time.Hour).Equal(till.Truncate(24 * time.Hour)) {
-						till = till.Truncate(24 * time.Hour).Add(-15 * time.Minute)
+					opens, closes, err := openingHours(c)
+					if err != nil {
+						return err
 					}
 
-					if !from.Before(till) {
-						return fmt.Errorf("--from cannot be ahead of --till")
+					if c.Int("weeks") < 1 {
+						return fmt.Errorf("--weeks must be at least 1")
 					}
 
-					config, _ := loadConfig(configPath)
-					s, err := skedda.NewWithCreds(config.Username, config.Password)
+					s, err := newClient(credentialOpts, configPath)
 					if err != nil {
 						return err
 					}
 
-					venues, spaces, err := load(s, noCache, configPath)
+					venues, spaces, err := load(s, noCache, cacheTTL, configPath)
 					if err != nil {
 						return err
 					}
 
-					var filteredSpaces skedda.SpaceList
-					if c.String("venue") != "" {
-						l := make([]fmt.Stringer, len(venues))
-						for k, v := range venues {
-							l[k] = v
-						}
-						matcher := NewMatcher(l)
-						r := matcher.Match(c.String("venue"))
-
-						list := make(skedda.VenueList, len(r))
-						for k, v := range r {
-							list[k] = v.(*skedda.Venue)
-						}
-
-						if len(list) == 0 {
-							return fmt.Errorf("no venue found")
-						}
-
-						if len(list) > 1 {
-							venueNames := list.Map(func(i int, v skedda.Venue) string {
-								return v.Name
-							})
+					filteredSpaces, err := selectSpaces(venues, spaces, c.String("venue"), c.StringSlice("spaces"))
+					if err != nil {
+						return err
+					}
 
-							return fmt.Errorf("found multiple matching venues, be more specific: %s", strings.Join(venueNames, ", "))
-						}
+					// The alternatives follow the grid of the venue of the
+					// first space
+					grid, err := venueGrid(c, venues.FindByID(filteredSpaces[0].VenueID))
+					if err != nil {
+						return err
+					}
 
-						venue := list[0]
-						for _, s := range spaces {
-							if s.VenueID == venue.ID {
-								filteredSpaces = append(filteredSpaces, s)
-							}
+					// The past weeks are learned from the archive when it covers
+					// them, while the bookings of the day are always fetched
+					pastDays := forecastDays(onDate, time.Now().UTC().Truncate(24*time.Hour), c.Int("weeks"))
+					pastFrom, pastTill := pastDays[len(pastDays)-1], pastDays[0].Add(24*time.Hour)
+					fetchDays := []time.Time{onDate}
+					occurrences := map[*skedda.Space][]skedda.Interval{}
+					if c.Bool("archive") || isArchived(configPath, venues, filteredSpaces, pastFrom, pastTill) {
+						fmt.Printf("Learning from the archived bookings of the last %d %ss...\n", len(pastDays), onDate.Weekday())
+						occurrences, err = archiveOccurrences(configPath, venues, filteredSpaces, pastFrom, pastTill)
+						if err != nil {
+							return err
 						}
 					} else {
-						l := make([]fmt.Stringer, len(spaces))
-						for k, v := range spaces {
-							l[k] = v
-						}
-						matcher := NewMatcher(l)
-						r := matcher.MatchMultiple(c.StringSlice("spaces"))
-
-						list := make(skedda.SpaceList, len(r))
-						for k, v := range r {
-							list[k] = v.(*skedda.Space)
-						}
-
-						filteredSpaces = list
-					}
-
-					if len(filteredSpaces) == 0 {
-						return fmt.Errorf("no spaces found")
+						fmt.Printf("Learning from the bookings of the last %d %ss, fetched as they are not archived (see `skedda sync`)...\n", len(pastDays), onDate.Weekday())
+						fetchDays = append(pastDays, onDate)
 					}
 
-					dateFormat := "Mon 02 Jan"
-					timeFormat := "3:04pm"
-					fmt.Printf("Finding bookings in %s on %s, between %s and %s...\n", strings.Join(filteredSpaces.Map(func(i int, s skedda.Space) string {
-						return s.Name
-					}), ", "), onDate.Format(dateFormat), from.Format(timeFormat), till.Format(timeFormat))
-
 					if err := s.Auth(); err != nil {
-						fmt.Printf("Failed to authenticate. You will not see the title of the bookings.\n\n")
-					}
-
-					type Result struct {
-						Venue    *skedda.Venue
-						Bookings []*skedda.Booking
-						Error    error
-					}
-
-					worker := func(venue *skedda.Venue, resultCh chan<- Result, wg *sync.WaitGroup) {
-						bookings, err := s.Bookings(venue.Domain, from, till)
-						resultCh <- Result{venue, bookings, err}
-						wg.Done()
+						return err
 					}
 
-					filteredVenues := map[*skedda.Venue]bool{}
-					for _, s := range filteredSpaces {
-						venue := venues.FindByID(s.VenueID)
-						filteredVenues[venue] = true
+					fetched, err := fetchOccurrencesOn(s, venues, filteredSpaces, fetchDays, c.Int("concurrency"))
+					if err != nil {
+						return err
 					}
 
-					resultCh := make(chan Result, len(filteredVenues))
-					var wg sync.WaitGroup
-					for venue := range filteredVenues {
-						wg.Add(1)
-						go worker(venue, resultCh, &wg)
+					for space, intervals := range fetched {
+						occurrences[space] = append(occurrences[space], intervals...)
 					}
-					wg.Wait()
-					close(resultCh)
 
-					spaceBookings := map[*skedda.Space][]*skedda.Booking{}
-
-					// create empty keys
+					sort.Sort(filteredSpaces)
+					forecasts := []Forecast{}
 					for _, space := range filteredSpaces {
-						spaceBookings[space] = []*skedda.Booking{}
+						forecasts = append(forecasts, forecastFree(space, occurrences[space], pastDays, from, till))
 					}
 
-					// fill up with result
-					for result := range resultCh {
-						if result.Error != nil {
-							return result.Error
-						}
-
-						for _, booking := range result.Bookings {
-							for _, spaceID := range booking.SpaceIDs {
-								space := filteredSpaces.FindByID(spaceID)
-								if space != nil {
-									spaceBookings[space] = append(spaceBookings[space], booking)
-								}
-							}
-						}
+					fmt.Printf("\nForecast for %s:\n", onDate.Format("Mon 02 Jan"))
+					if err := writeForecasts(os.Stdout, forecasts); err != nil {
+						return err
 					}
 
-					// sort
-					keys := skedda.SpaceList{}
-					for space := range spaceBookings {
-						keys = append(keys, space)
+					alternatives := forecastAlternatives(occurrences, pastDays, from, till, opens, closes, grid, c.Int("alternatives"))
+					if len(alternatives) == 0 {
+						return nil
 					}
-					sort.Sort(keys)
-
-					for _, space := range keys {
-						bookings := spaceBookings[space]
-						venue := venues.FindByID(space.VenueID)
-						fmt.Printf("\n%s -- %s\n", venue.Name, space.Name)
-						if len(bookings) == 0 {
-							fmt.Printf("\t* Slot is available *\n")
-							continue
-						}
 
-						for i, booking := range bookings {
-							fmt.Printf("\t%d. %s\n", i+1, booking)
-						}
-					}
-					return nil
+					fmt.Printf("\nBest alternatives:\n")
+					return writeForecasts(os.Stdout, alternatives)
 				},
 			}, {
-				Name:    "book",
-				Aliases: []string{"f"},
-				Usage:   "Book spaces for meeting",
+				Name:  "sync",
+				Usage: "Sync the bookings into the local archive",
 				Flags: []cli.Flag{
 					&noCacheFlag,
-					&cli.StringSliceFlag{
-						Name:     "spaces",
-						Aliases:  []string{"space", "s"},
-						Usage:    "Spaces to book",
-						Required: true,
-					},
 					&cli.StringFlag{
-						Name:        "on",
-						Aliases:     []string{"d"},
-						Usage:       "`DATE` to book (possible values: today, tomorrow, YYYY-MM-DD)",
-						DefaultText: "today",
+						Name:    "venue",
+						Aliases: []string{"v"},
+						Usage:   "Venue to sync (default: all venues)",
 					},
-					&cli.GenericFlag{
+					&cli.StringFlag{
 						Name:        "from",
-						Aliases:     []string{"a"},
-						Usage:       "`TIME` from (H:mma)",
-						DefaultText: time.Now().Truncate(15 * time.Minute).Format("3:04pm"),
-						Value: &FlexibleTimestamp{
-							Layouts: []string{"3:04pm", "3pm"},
-						},
-					},
-					&cli.GenericFlag{
-						Name:        "till",
-						Aliases:     []string{"b"},
-						Usage:       "`TIME` till (H:mma)",
-						DefaultText: time.Now().Add(30 * time.Minute).Truncate(15 * time.Minute).Format("3:04pm"),
-						Value: &FlexibleTimestamp{
-							Layouts: []string{"3:04pm", "3pm"},
-						},
+						Usage:       "First `DATE` to sync (e.g. YYYY-MM-DD, -30d, last mon)",
+						DefaultText: "90 days ago, or where the last sync started",
 					},
 					&cli.StringFlag{
-						Name:     "title",
-						Aliases:  []string{"t"},
-						Usage:    "Title for the booking",
-						Required: true,
-					},
-					&cli.BoolFlag{
-						Name:    "assume-yes",
-						Aliases: []string{"yes", "y"},
-						Usage:   "Assume yes to al prompts and run non-interactively",
+						Name:        "to",
+						Usage:       "Last `DATE` to sync (e.g. YYYY-MM-DD, yesterday)",
+						DefaultText: "today",
 					},
 				},
 				Action: func(c *cli.Context) error {
-					var from, till time.Time
-					onStr := strings.ToLower(c.String("on"))
-					fromTmp := c.Value("from").(*time.Time)
-					tillTmp := c.Value("till").(*time.Time)
-
-					now := time.Now().UTC().Truncate(15 * time.Minute).Truncate(24 * time.Hour)
-					var onDate time.Time
-					switch onStr {
-					case "":
-						fallthrough
-					case "today":
-						onDate = now
-					case "tomorrow":
-						onDate = now.Add(24 * time.Hour)
-					default:
-						onDate, err = time.Parse("2006-01-02", onStr)
+					today := time.Now().UTC().Truncate(24 * time.Hour)
+					from := today.Add(-90 * 24 * time.Hour)
+					till := today.Add(24 * time.Hour)
+					if c.String("from") != "" || c.String("to") != "" {
+						fromStr := c.String("from")
+						if fromStr == "" {
+							fromStr = from.Format("2006-01-02")
+						}
+						toStr := c.String("to")
+						if toStr == "" {
+							toStr = today.Format("2006-01-02")
+						}
+
+						var err error
+						from, till, err = parseReportPeriod(fromStr, toStr)
 						if err != nil {
 							return err
 						}
 					}
-					if fromTmp == nil && tillTmp == nil { // Consider full day
-						from = onDate
-						till = from.Add(24 * time.Hour)
-					} else if fromTmp != nil && tillTmp != nil {
-						from = *fromTmp
-						from = time.Date(onDate.Year(), onDate.Month(), onDate.Day(), from.Hour(), from.Minute(), from.Second(), from.Nanosecond(), time.UTC)
-						till = *tillTmp
-						till = time.Date(onDate.Year(), onDate.Month(), onDate.Day(), till.Hour(), till.Minute(), till.Second(), till.Nanosecond(), time.UTC)
-					} else if fromTmp == nil && tillTmp != nil {
-						return fmt.Errorf("--from is required when --till is provided")
-					} else if fromTmp != nil && tillTmp == nil {
-						from = *fromTmp
-						from = time.Date(onDate.Year(), onDate.Month(), onDate.Day(), from.Hour(), from.Minute(), from.Second(), from.Nanosecond(), time.UTC)
-						till = from.Add(30 * time.Minute)
-					} else {
-						return fmt.Errorf("report the inputs to the developer")
-					}
-
-					// Pull 'till' to the same date in case of full day
-					if !from.Truncate(24 * time.Hour).Equal(till.Truncate(24 * time.Hour)) {
-						till = till.Truncate(24 * time.Hour).Add(-15 * time.Minute)
-					}
-
-					if !from.Before(till) {
-						return fmt.Errorf("--from cannot be ahead of --till")
-					}
-
-					// Booking requires time to be 15min granular
-					if !from.Equal(from.Truncate(15*time.Minute)) || !till.Equal(till.Truncate(15*time.Minute)) {
-						return fmt.Errorf("--from and --till has to be round to 15 minutes for booking")
-					}
-
-					if (c.String("venue") != "") == (len(c.StringSlice("spaces")) > 0) {
-						return fmt.Errorf("either provide venue or spaces")
-					}
-
-					title := c.String("title")
-					title = strings.TrimSpace(title)
-					if title == "" {
-						return fmt.Errorf("--title is required")
-					}
 
-					config, err := loadConfig(configPath)
+					s, err := newClient(credentialOpts, configPath)
 					if err != nil {
-						return skedda.ErrCredsMissing
+						return err
 					}
 
-					s, err := skedda.NewWithCreds(config.Username, config.Password)
+					venues, spaces, err := load(s, noCache, cacheTTL, configPath)
 					if err != nil {
 						return err
 					}
 
-					venues, spaces, err := load(s, noCache, configPath)
+					filteredSpaces, err := selectSpaces(venues, spaces, c.String("venue"), nil)
 					if err != nil {
 						return err
 					}
 
-					var venueID int
-					var filteredSpaces skedda.SpaceList
-					{
-						l := make([]fmt.Stringer, len(spaces))
-						for k, v := range spaces {
-							l[k] = v
-						}
-						matcher := NewMatcher(l)
-						r := matcher.MatchMultiple(c.StringSlice("spaces"))
-
-						list := make(skedda.SpaceList, len(r))
-						for k, v := range r {
-							list[k] = v.(*skedda.Space)
-						}
-
-						for _, space := range list {
-							if venueID == 0 {
-								venueID = space.VenueID
-							}
-
-							if space.VenueID != venueID {
-								return fmt.Errorf("you must choose spaces from a single venue only")
-							}
-						}
-						filteredSpaces = list
+					if err := s.Auth(); err != nil {
+						return err
 					}
 
-					if len(filteredSpaces) == 0 || venueID == 0 {
-						return fmt.Errorf("no spaces found")
-					}
-					venue := venues.FindByID(venueID)
-					if venue == nil {
-						return fmt.Errorf("could not find details about the venue")
+					state, err := loadArchiveState(configPath)
+					if err != nil {
+						return err
 					}
 
-					dateFormat := "Mon 02 Jan"
-					timeFormat := "3:04pm"
-					fmt.Printf("Booking %s on %s, between %s and %s...\n", strings.Join(filteredSpaces.Map(func(i int, s skedda.Space) string {
-						return s.Name
-					}), ", "), onDate.Format(dateFormat), from.Format(timeFormat), till.Format(timeFormat))
-
-					if !c.Bool("assume-yes") {
-						fmt.Print("\nAre you sure? (y/N): ")
-						var answer string
-						fmt.Scanln(&answer)
-
-						if !strings.HasPrefix(strings.ToLower(answer), "y") {
-							return nil
+					for _, domain := range spaceDomains(venues, filteredSpaces) {
+						domainFrom := from
+						if synced, ok := state[domain]; ok && c.String("from") == "" {
+							domainFrom = synced.From
 						}
-					}
-
-					if err := s.Auth(); err != nil {
-						return err
-					}
 
-					spaceIDs := []int{}
-					for _, space := range filteredSpaces {
-						spaceIDs = append(spaceIDs, space.ID)
-					}
-					if err := s.Book(venue.Domain, venue.ID, spaceIDs, title, from, till); err != nil {
-						return err
+						fmt.Printf("Syncing %s...\n", domain)
+						appended, err := syncArchive(s, configPath, domain, domainFrom, till, today)
+						if err != nil {
+							return err
+						}
+						fmt.Printf("  %d new or changed bookings\n", appended)
 					}
 
-					fmt.Println("\nBooked!")
 					return nil
 				},
 			},
 		},
 	}
 
-	if err := app.Run(os.Args); err != nil {
+	err = app.Run(os.Args)
+	backgroundRefresh.Wait()
+
+	if err != nil {
 		fmt.Println("\nError:", err)
 
 		if errors.Is(err, skedda.ErrCredsMissing) {
-			fmt.Printf("\nTry using `%s configure`\n", app.Name)
+			fmt.Printf("\nTry using `%s configure`, or set SKEDDA_USERNAME and SKEDDA_PASSWORD\n", app.Name)
 		} else if errors.Is(err, skedda.ErrAuthFailed) {
 			fmt.Printf("\nTry changing credentials using `%s configure`\n", app.Name)
+		} else if errors.Is(err, skedda.ErrSessionExpired) && usesSession(credentialOpts, configPath) {
+			fmt.Printf("\nThe browser session expired, try using `%s login` again\n", app.Name)
+		} else if errors.Is(err, skedda.ErrSessionExpired) {
+			fmt.Printf("\nSkedda signed out in the meantime, try again or check the credentials using `%s configure`\n", app.Name)
 		}
 		os.Exit(1)
 	}
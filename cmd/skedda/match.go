@@ -2,7 +2,9 @@ package main
 
 import (
 	"fmt"
+	"strings"
 
+	"github.com/alyyousuf7/skedda"
 	"github.com/lithammer/fuzzysearch/fuzzy"
 )
 
@@ -66,3 +68,76 @@ func uniqueStringer(stringerSlice []fmt.Stringer) []fmt.Stringer {
 	}
 	return list
 }
+
+// matchVenue returns the only venue matching the string
+func matchVenue(venues skedda.VenueList, str string) (*skedda.Venue, error) {
+	l := make([]fmt.Stringer, len(venues))
+	for k, v := range venues {
+		l[k] = v
+	}
+	matcher := NewMatcher(l)
+	r := matcher.Match(str)
+
+	list := make(skedda.VenueList, len(r))
+	for k, v := range r {
+		list[k] = v.(*skedda.Venue)
+	}
+
+	if len(list) == 0 {
+		return nil, fmt.Errorf("no venue found")
+	}
+
+	if len(list) > 1 {
+		venueNames := list.Map(func(i int, v skedda.Venue) string {
+			return v.Name
+		})
+
+		return nil, fmt.Errorf("found multiple matching venues, be more specific: %s", strings.Join(venueNames, ", "))
+	}
+
+	return list[0], nil
+}
+
+// matchSpaces returns all spaces matching any of the strings
+func matchSpaces(spaces skedda.SpaceList, strv []string) skedda.SpaceList {
+	l := make([]fmt.Stringer, len(spaces))
+	for k, v := range spaces {
+		l[k] = v
+	}
+	matcher := NewMatcher(l)
+	r := matcher.MatchMultiple(strv)
+
+	list := make(skedda.SpaceList, len(r))
+	for k, v := range r {
+		list[k] = v.(*skedda.Space)
+	}
+
+	return list
+}
+
+// matchVenueSpaces returns the spaces matching the strings along with their
+// venue, making sure they all belong to a single venue
+func matchVenueSpaces(venues skedda.VenueList, spaces skedda.SpaceList, strv []string) (*skedda.Venue, skedda.SpaceList, error) {
+	var venueID int
+	list := matchSpaces(spaces, strv)
+	for _, space := range list {
+		if venueID == 0 {
+			venueID = space.VenueID
+		}
+
+		if space.VenueID != venueID {
+			return nil, nil, fmt.Errorf("you must choose spaces from a single venue only")
+		}
+	}
+
+	if len(list) == 0 || venueID == 0 {
+		return nil, nil, fmt.Errorf("no spaces found")
+	}
+
+	venue := venues.FindByID(venueID)
+	if venue == nil {
+		return nil, nil, fmt.Errorf("could not find details about the venue")
+	}
+
+	return venue, list, nil
+}
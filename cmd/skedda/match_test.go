@@ -0,0 +1,95 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/alyyousuf7/skedda"
+)
+
+var (
+	testVenues = skedda.VenueList{
+		{ID: 1, Name: "London Office"},
+		{ID: 2, Name: "Lisbon Office"},
+	}
+	testSpaces = skedda.SpaceList{
+		{ID: 10, Name: "Thames", VenueID: 1},
+		{ID: 11, Name: "Desk 1", VenueID: 1},
+		{ID: 12, Name: "Desk 2", VenueID: 1},
+		{ID: 20, Name: "Tagus", VenueID: 2},
+	}
+)
+
+func TestMatchVenue(t *testing.T) {
+	testCases := []struct {
+		Input    string
+		Expected int
+		Err      string
+	}{
+		{"london", 1, ""},
+		{"LIS", 2, ""},
+		{"office", 0, "multiple matching venues"},
+		{"paris", 0, "no venue found"},
+	}
+
+	for i, testCase := range testCases {
+		venue, err := matchVenue(testVenues, testCase.Input)
+		if testCase.Err != "" {
+			if err == nil || !strings.Contains(err.Error(), testCase.Err) {
+				t.Errorf("Expected %q but got %v: Test case %d", testCase.Err, err, i)
+			}
+			continue
+		}
+
+		if err != nil || venue.ID != testCase.Expected {
+			t.Errorf("Expected venue %d but got %v, %v: Test case %d", testCase.Expected, venue, err, i)
+		}
+	}
+}
+
+func TestMatchVenueSpaces(t *testing.T) {
+	testCases := []struct {
+		Input    []string
+		Venue    int
+		Expected []int
+		Err      string
+	}{
+		{[]string{"thames"}, 1, []int{10}, ""},
+		{[]string{"desk"}, 1, []int{11, 12}, ""},
+		{[]string{"desk 1", "Desk 1", "thm"}, 1, []int{11, 10}, ""},
+		{[]string{"thames", "tagus"}, 0, nil, "single venue"},
+		{[]string{"nile"}, 0, nil, "no spaces found"},
+	}
+
+	for i, testCase := range testCases {
+		venue, spaces, err := matchVenueSpaces(testVenues, testSpaces, testCase.Input)
+		if testCase.Err != "" {
+			if err == nil || !strings.Contains(err.Error(), testCase.Err) {
+				t.Errorf("Expected %q but got %v: Test case %d", testCase.Err, err, i)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("Expected %v but got %v: Test case %d", testCase.Expected, err, i)
+			continue
+		}
+
+		ids := []int{}
+		for _, space := range spaces {
+			ids = append(ids, space.ID)
+		}
+
+		if venue.ID != testCase.Venue || len(ids) != len(testCase.Expected) {
+			t.Errorf("Expected venue %d with %v but got venue %d with %v: Test case %d", testCase.Venue, testCase.Expected, venue.ID, ids, i)
+			continue
+		}
+
+		for j := range ids {
+			if ids[j] != testCase.Expected[j] {
+				t.Errorf("Expected %v but got %v: Test case %d", testCase.Expected, ids, i)
+				break
+			}
+		}
+	}
+}
@@ -0,0 +1,255 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/alyyousuf7/skedda"
+	"github.com/alyyousuf7/skedda/timeparse"
+	"github.com/urfave/cli/v2"
+)
+
+// multiDayFlags returns the flags booking the same time period on several days
+func multiDayFlags() []cli.Flag {
+	return []cli.Flag{
+		&cli.IntFlag{
+			Name:  "weeks",
+			Usage: "Repeat the days of --on, e.g. mon,wed,fri, for `N` weeks",
+		},
+		&cli.StringFlag{
+			Name:  "dates",
+			Usage: "Book every day of a `RANGE` instead of --on (e.g. 2026-11-02..2026-11-27, today..+2w)",
+		},
+		&cli.BoolFlag{
+			Name:  "weekdays",
+			Usage: "Skip Saturdays and Sundays",
+		},
+		&cli.StringSliceFlag{
+			Name:  "skip",
+			Usage: "`DATES` to skip, on top of the holidays listed in the holidays file of the profile",
+		},
+	}
+}
+
+// isMultiDay tells whether the booking spans several days
+func isMultiDay(c *cli.Context) bool {
+	return c.Int("weeks") > 0 || c.String("dates") != "" || strings.Contains(c.String("on"), ",")
+}
+
+// bookingDays returns the days of a multi-day booking in order, either the
+// days of a range of dates, or the comma separated days of on repeated for a
+// number of weeks
+func bookingDays(on, dates string, weeks int, now time.Time) ([]time.Time, error) {
+	days := []time.Time{}
+	if dates != "" {
+		if on != "" || weeks > 0 {
+			return nil, fmt.Errorf("--dates cannot be used with --on or --weeks")
+		}
+
+		bounds := strings.SplitN(dates, "..", 2)
+		if len(bounds) != 2 {
+			return nil, fmt.Errorf("invalid --dates: %s (e.g. 2026-11-02..2026-11-27)", dates)
+		}
+
+		first, err := timeparse.Date(bounds[0], now)
+		if err != nil {
+			return nil, fmt.Errorf("invalid --dates: %w", err)
+		}
+
+		last, err := timeparse.Date(bounds[1], now)
+		if err != nil {
+			return nil, fmt.Errorf("invalid --dates: %w", err)
+		}
+
+		if last.Before(first) {
+			return nil, fmt.Errorf("--dates ends before it starts")
+		}
+
+		for day := first; !day.After(last); day = day.Add(24 * time.Hour) {
+			days = append(days, day)
+		}
+		return days, nil
+	}
+
+	if weeks <= 0 {
+		weeks = 1
+	}
+
+	seen := map[time.Time]bool{}
+	for _, item := range strings.Split(on, ",") {
+		day, err := timeparse.Date(item, now)
+		if err != nil {
+			return nil, fmt.Errorf("invalid --on: %w", err)
+		}
+
+		for week := 0; week < weeks; week++ {
+			d := day.Add(time.Duration(week) * 7 * 24 * time.Hour)
+			if !seen[d] {
+				seen[d] = true
+				days = append(days, d)
+			}
+		}
+	}
+
+	sort.Slice(days, func(i, j int) bool {
+		return days[i].Before(days[j])
+	})
+	return days, nil
+}
+
+// loadHolidays reads the holidays file of the profile, with a date and an
+// optional name per line. Lines starting with # are comments.
+func loadHolidays(configPath string) (map[time.Time]string, error) {
+	holidays := map[time.Time]string{}
+
+	f, err := os.Open(path.Join(configPath, "holidays"))
+	if os.IsNotExist(err) {
+		return holidays, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+
+		fields := strings.SplitN(text, " ", 2)
+		day, err := time.Parse("2006-01-02", fields[0])
+		if err != nil {
+			return nil, fmt.Errorf("holidays file, line %d: %w", line, err)
+		}
+
+		name := "holiday"
+		if len(fields) == 2 {
+			name = strings.TrimSpace(fields[1])
+		}
+		holidays[day] = name
+	}
+
+	return holidays, scanner.Err()
+}
+
+// DayOutcome is the result of booking one of the days of a multi-day booking
+type DayOutcome struct {
+	Day    time.Time
+	Status string
+	Detail string
+}
+
+const (
+	dayBooked      = "booked"
+	dayUnavailable = "unavailable"
+	daySkipped     = "skipped"
+	dayFailed      = "failed"
+)
+
+// skipReason tells why a day is not booked, if it is not. start is when the
+// booking would start on the day.
+func skipReason(day, start time.Time, weekdaysOnly bool, holidays map[time.Time]string, now time.Time) (string, bool) {
+	if name, ok := holidays[day]; ok {
+		return name, true
+	}
+
+	if weekdaysOnly && (day.Weekday() == time.Saturday || day.Weekday() == time.Sunday) {
+		return "weekend", true
+	}
+
+	if start.Before(now) {
+		return "in the past", true
+	}
+
+	return "", false
+}
+
+// bookDays books the spaces during the same time of the day on each of the
+// days, checking the availability of all of them first. The time of the day
+// is given as offsets from midnight.
+func bookDays(s *skedda.Skedda, venue *skedda.Venue, spaceIDs []int, title string, days []time.Time, opens, closes time.Duration, skip func(time.Time) (string, bool)) ([]DayOutcome, error) {
+	outcomes := []DayOutcome{}
+	toBook := []time.Time{}
+	for _, day := range days {
+		if reason, ok := skip(day); ok {
+			outcomes = append(outcomes, DayOutcome{day, daySkipped, reason})
+			continue
+		}
+		toBook = append(toBook, day)
+	}
+
+	if len(toBook) == 0 {
+		return outcomes, nil
+	}
+
+	// Skedda only lists the bookings of a limited window at once, the days
+	// are fetched in chunks like the archive
+	sort.Slice(toBook, func(i, j int) bool {
+		return toBook[i].Before(toBook[j])
+	})
+
+	bookings := []*skedda.Booking{}
+	for i := 0; i < len(toBook); {
+		last := i
+		for last+1 < len(toBook) && toBook[last+1].Add(closes).Sub(toBook[i].Add(opens)) <= archiveChunk {
+			last++
+		}
+
+		chunk, err := s.Bookings(venue.Domain, toBook[i].Add(opens), toBook[last].Add(closes))
+		if err != nil {
+			return nil, err
+		}
+		bookings = append(bookings, chunk...)
+		i = last + 1
+	}
+
+	for _, day := range toBook {
+		from, till := day.Add(opens), day.Add(closes)
+		if booking := occupiedBy(bookings, spaceIDs, from, till); booking != nil {
+			outcomes = append(outcomes, DayOutcome{day, dayUnavailable, booking.String()})
+			continue
+		}
+
+		if err := s.Book(venue.Domain, venue.ID, spaceIDs, title, from, till); err != nil {
+			outcomes = append(outcomes, DayOutcome{day, dayFailed, err.Error()})
+			continue
+		}
+		outcomes = append(outcomes, DayOutcome{day, dayBooked, ""})
+	}
+
+	sort.SliceStable(outcomes, func(i, j int) bool {
+		return outcomes[i].Day.Before(outcomes[j].Day)
+	})
+	return outcomes, nil
+}
+
+// occupiedBy returns a booking of any of the spaces during a time period
+func occupiedBy(bookings []*skedda.Booking, spaceIDs []int, from, till time.Time) *skedda.Booking {
+	for _, booking := range bookings {
+		for _, id := range booking.SpaceIDs {
+			if containsID(spaceIDs, id) && len(booking.Occurrences(from, till)) > 0 {
+				return booking
+			}
+		}
+	}
+
+	return nil
+}
+
+func writeDayOutcomes(w io.Writer, outcomes []DayOutcome) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	for _, o := range outcomes {
+		fmt.Fprintf(tw, "  %s\t%s\t%s\n", o.Day.Format("Mon 02 Jan"), o.Status, o.Detail)
+	}
+
+	return tw.Flush()
+}
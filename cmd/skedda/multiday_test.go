@@ -0,0 +1,217 @@
+package main
+
+import (
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alyyousuf7/skedda"
+)
+
+func TestBookingDays(t *testing.T) {
+	// Wednesday 4 March 2020
+	now := time.Date(2020, time.March, 4, 10, 20, 0, 0, time.UTC)
+	day := func(d int) string {
+		return time.Date(2020, time.March, d, 0, 0, 0, 0, time.UTC).Format("2006-01-02")
+	}
+
+	testCases := []struct {
+		On       string
+		Dates    string
+		Weeks    int
+		Expected []string
+		Valid    bool
+	}{
+		{"mon,wed,fri", "", 2, []string{day(4), day(6), day(9), day(11), day(13), day(16)}, true},
+		{"fri,2020-03-05", "", 0, []string{day(5), day(6)}, true},
+		{"fri,fri", "", 1, []string{day(6)}, true},
+		{"", "2020-03-05..2020-03-09", 0, []string{day(5), day(6), day(7), day(8), day(9)}, true},
+		{"", "today..+2d", 0, []string{day(4), day(5), day(6)}, true},
+		{"", "2020-03-09..2020-03-05", 0, nil, false},
+		{"", "2020-03-05", 0, nil, false},
+		{"mon", "2020-03-05..2020-03-09", 0, nil, false},
+		{"mon,someday", "", 2, nil, false},
+	}
+
+	for i, testCase := range testCases {
+		days, err := bookingDays(testCase.On, testCase.Dates, testCase.Weeks, now)
+		if (err == nil) != testCase.Valid {
+			t.Errorf("Expected valid: %v but got %v: Test case %d", testCase.Valid, err, i)
+			continue
+		}
+
+		got := []string{}
+		for _, d := range days {
+			got = append(got, d.Format("2006-01-02"))
+		}
+
+		if len(got) != len(testCase.Expected) {
+			t.Errorf("Expected %v but got %v: Test case %d", testCase.Expected, got, i)
+			continue
+		}
+
+		for j := range got {
+			if got[j] != testCase.Expected[j] {
+				t.Errorf("Expected %v but got %v: Test case %d", testCase.Expected, got, i)
+				break
+			}
+		}
+	}
+}
+
+func TestSkipReason(t *testing.T) {
+	dir, err := ioutil.TempDir("", "skedda")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	holidaysFile := "# Public holidays\n2020-03-09 Bank holiday\n\n2020-03-10\n"
+	if err := ioutil.WriteFile(path.Join(dir, "holidays"), []byte(holidaysFile), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	holidays, err := loadHolidays(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Date(2020, time.March, 4, 10, 20, 0, 0, time.UTC)
+	testCases := []struct {
+		Day          int
+		WeekdaysOnly bool
+		Expected     string
+	}{
+		{4, false, "in the past"},
+		{5, false, ""},
+		{7, false, ""},
+		{7, true, "weekend"},
+		{9, false, "Bank holiday"},
+		{10, true, "holiday"},
+	}
+
+	for i, testCase := range testCases {
+		day := time.Date(2020, time.March, testCase.Day, 0, 0, 0, 0, time.UTC)
+		reason, _ := skipReason(day, day.Add(9*time.Hour), testCase.WeekdaysOnly, holidays, now)
+		if reason != testCase.Expected {
+			t.Errorf("Expected %q but got %q: Test case %d", testCase.Expected, reason, i)
+		}
+	}
+}
+
+func TestBookDays(t *testing.T) {
+	day := func(d int) time.Time {
+		return time.Date(2020, time.March, d, 0, 0, 0, 0, time.UTC)
+	}
+
+	// Space 1 is taken on the 10th, and booking it on the 12th fails
+	booked := []string{}
+	s := fakeSkedda(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "POST" && r.URL.Path == "/bookings" {
+			body, _ := ioutil.ReadAll(r.Body)
+			if strings.Contains(string(body), "2020-03-12") {
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+
+			booked = append(booked, string(body))
+			w.Write([]byte("{}"))
+			return
+		}
+
+		w.Write([]byte(`{"bookings":[{"id":1,"title":"Standup","start":"2020-03-10T09:00:00","end":"2020-03-10T09:30:00","recurrenceRule":null,"spaces":[1],"venue":1}]}`))
+	})
+
+	skip := func(d time.Time) (string, bool) {
+		return "Bank holiday", d.Equal(day(9))
+	}
+
+	venue := &skedda.Venue{ID: 1, Name: "London Office", Domain: "acme"}
+	outcomes, err := bookDays(s, venue, []int{1}, "Focus", []time.Time{day(12), day(9), day(10), day(11)}, 9*time.Hour, 10*time.Hour, skip)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []DayOutcome{
+		{day(9), daySkipped, "Bank holiday"},
+		{day(10), dayUnavailable, ""},
+		{day(11), dayBooked, ""},
+		{day(12), dayFailed, ""},
+	}
+
+	if len(outcomes) != len(expected) {
+		t.Fatalf("Expected %v but got %v", expected, outcomes)
+	}
+
+	for i := range expected {
+		if !outcomes[i].Day.Equal(expected[i].Day) || outcomes[i].Status != expected[i].Status {
+			t.Errorf("Expected %v but got %v: Test case %d", expected[i], outcomes[i], i)
+		}
+	}
+
+	if outcomes[0].Detail != "Bank holiday" || !strings.Contains(outcomes[1].Detail, "Standup") {
+		t.Errorf("Expected the reasons to be given but got %q, %q", outcomes[0].Detail, outcomes[1].Detail)
+	}
+
+	if len(booked) != 1 || !strings.Contains(booked[0], `"start":"2020-03-11T09:00:00"`) || !strings.Contains(booked[0], `"end":"2020-03-11T10:00:00"`) {
+		t.Errorf("Expected the 11th to be booked 9-10am but got %v", booked)
+	}
+}
+
+func TestBookDaysInChunks(t *testing.T) {
+	day := func(d int) time.Time {
+		return time.Date(2020, time.March, d, 0, 0, 0, 0, time.UTC)
+	}
+
+	// Like Skedda, only the bookings of the requested window are listed
+	taken := day(30).Add(9 * time.Hour)
+	windows := 0
+	s := fakeSkedda(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "POST" {
+			w.Write([]byte("{}"))
+			return
+		}
+
+		windows++
+		start, _ := time.Parse("2006-01-02T15:04:05", r.URL.Query().Get("start"))
+		end, _ := time.Parse("2006-01-02T15:04:05", r.URL.Query().Get("end"))
+		if end.Sub(start) > archiveChunk {
+			t.Errorf("Expected at most %s of bookings at once but got %s - %s", archiveChunk, start, end)
+		}
+
+		if start.After(taken) || end.Before(taken) {
+			w.Write([]byte(`{"bookings":[]}`))
+			return
+		}
+		w.Write([]byte(`{"bookings":[{"id":1,"title":"Offsite","start":"2020-03-30T09:00:00","end":"2020-03-30T17:00:00","recurrenceRule":null,"spaces":[1],"venue":1}]}`))
+	})
+
+	// Mondays for 5 weeks, and the rest of the first week
+	days := []time.Time{day(2), day(3), day(4), day(5), day(6), day(9), day(16), day(23), day(30)}
+	venue := &skedda.Venue{ID: 1, Name: "London Office", Domain: "acme"}
+	outcomes, err := bookDays(s, venue, []int{1}, "Focus", days, 9*time.Hour, 10*time.Hour, func(time.Time) (string, bool) {
+		return "", false
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if windows < 4 {
+		t.Errorf("Expected the days to be fetched in chunks but got %d requests", windows)
+	}
+
+	for i, outcome := range outcomes {
+		expected := dayBooked
+		if outcome.Day.Equal(day(30)) {
+			expected = dayUnavailable
+		}
+
+		if outcome.Status != expected {
+			t.Errorf("Expected %s but got %v: Test case %d", expected, outcome, i)
+		}
+	}
+}
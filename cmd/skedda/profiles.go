@@ -0,0 +1,138 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/urfave/cli/v2"
+)
+
+// defaultProfile is the profile kept at the root of the config directory, as
+// configured before profiles existed
+const defaultProfile = "default"
+
+var profileNameRegexp = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// profilePath returns the directory holding the config, credentials and cache
+// of a profile
+func profilePath(basePath, name string) string {
+	if name == "" || name == defaultProfile {
+		return basePath
+	}
+
+	return path.Join(basePath, "profiles", name)
+}
+
+func validateProfileName(name string) error {
+	if !profileNameRegexp.MatchString(name) {
+		return fmt.Errorf("invalid profile name %q, use letters, digits, - and _", name)
+	}
+
+	return nil
+}
+
+// profileFlag is the --profile flag, which SKEDDA_PROFILE sets as well
+func profileFlag(profile *string) *cli.StringFlag {
+	return &cli.StringFlag{
+		Name:        "profile",
+		Aliases:     []string{"p"},
+		Usage:       "Profile to use, each with its own credentials and cache (default: set by `skedda profiles use`)",
+		EnvVars:     []string{"SKEDDA_PROFILE"},
+		Destination: profile,
+	}
+}
+
+// resolveProfile returns the profile given by --profile or SKEDDA_PROFILE, or
+// the one selected by `skedda profiles use` otherwise
+func resolveProfile(basePath, name string) (string, error) {
+	if name == "" {
+		name = activeProfile(basePath)
+	}
+
+	if err := validateProfileName(name); err != nil {
+		return "", err
+	}
+
+	return name, nil
+}
+
+// activeProfile returns the profile selected by `skedda profiles use`
+func activeProfile(basePath string) string {
+	raw, err := ioutil.ReadFile(path.Join(basePath, "profile"))
+	if err != nil {
+		return defaultProfile
+	}
+
+	name := strings.TrimSpace(string(raw))
+	if validateProfileName(name) != nil {
+		return defaultProfile
+	}
+
+	return name
+}
+
+// useProfile selects the profile used when none is given
+func useProfile(basePath, name string) error {
+	if err := os.MkdirAll(basePath, 0700); err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(path.Join(basePath, "profile"), []byte(name+"\n"), 0600)
+}
+
+// listProfiles returns the names of the configured profiles
+func listProfiles(basePath string) ([]string, error) {
+	names := []string{defaultProfile}
+
+	infos, err := ioutil.ReadDir(path.Join(basePath, "profiles"))
+	if os.IsNotExist(err) {
+		return names, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	for _, info := range infos {
+		if info.IsDir() && validateProfileName(info.Name()) == nil && info.Name() != defaultProfile {
+			names = append(names, info.Name())
+		}
+	}
+	sort.Strings(names[1:])
+
+	return names, nil
+}
+
+// profileExists tells whether a profile has been configured
+func profileExists(basePath, name string) bool {
+	if name == defaultProfile {
+		return true
+	}
+
+	_, err := os.Stat(profilePath(basePath, name))
+	return err == nil
+}
+
+// deleteProfile removes the config, credentials and cache of a profile
+func deleteProfile(basePath, name string) error {
+	if name == defaultProfile {
+		return fmt.Errorf("the default profile cannot be deleted")
+	}
+
+	if !profileExists(basePath, name) {
+		return fmt.Errorf("no profile named %s", name)
+	}
+
+	if err := os.RemoveAll(profilePath(basePath, name)); err != nil {
+		return err
+	}
+
+	if activeProfile(basePath) == name {
+		return useProfile(basePath, defaultProfile)
+	}
+
+	return nil
+}
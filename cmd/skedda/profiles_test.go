@@ -0,0 +1,104 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/urfave/cli/v2"
+)
+
+func TestProfilePath(t *testing.T) {
+	testCases := []struct {
+		Name     string
+		Expected string
+	}{
+		{"", "/home/jane/.skedda"},
+		{defaultProfile, "/home/jane/.skedda"},
+		{"work", "/home/jane/.skedda/profiles/work"},
+	}
+
+	for i, testCase := range testCases {
+		if actual := profilePath("/home/jane/.skedda", testCase.Name); actual != testCase.Expected {
+			t.Errorf("Expected %s but got %s: Test case %d", testCase.Expected, actual, i)
+		}
+	}
+}
+
+func TestValidateProfileName(t *testing.T) {
+	testCases := []struct {
+		Name     string
+		Expected bool
+	}{
+		{"work", true},
+		{"client_2-eu", true},
+		{"", false},
+		{"..", false},
+		{"../work", false},
+		{"my profile", false},
+		{"work/eu", false},
+	}
+
+	for i, testCase := range testCases {
+		if err := validateProfileName(testCase.Name); (err == nil) != testCase.Expected {
+			t.Errorf("Expected valid: %v but got %v: Test case %d", testCase.Expected, err, i)
+		}
+	}
+}
+
+func TestResolveProfile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "skedda")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	if value, ok := os.LookupEnv("SKEDDA_PROFILE"); ok {
+		defer os.Setenv("SKEDDA_PROFILE", value)
+	}
+	defer os.Unsetenv("SKEDDA_PROFILE")
+
+	testCases := []struct {
+		Args     []string
+		Env      string
+		Active   string
+		Expected string
+		Valid    bool
+	}{
+		{nil, "", "", defaultProfile, true},
+		{nil, "", "home", "home", true},
+		{nil, "work", "home", "work", true},
+		{[]string{"--profile", "client"}, "work", "home", "client", true},
+		{[]string{"-p", "client"}, "", "home", "client", true},
+		{[]string{"--profile", "../work"}, "", "", "", false},
+		{nil, "../work", "home", "", false},
+	}
+
+	for i, testCase := range testCases {
+		os.Remove(path.Join(dir, "profile"))
+		if testCase.Active != "" {
+			if err := useProfile(dir, testCase.Active); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		os.Unsetenv("SKEDDA_PROFILE")
+		if testCase.Env != "" {
+			os.Setenv("SKEDDA_PROFILE", testCase.Env)
+		}
+
+		var profile string
+		flagContext(t, []cli.Flag{profileFlag(&profile)}, testCase.Args...)
+
+		actual, err := resolveProfile(dir, profile)
+		if (err == nil) != testCase.Valid {
+			t.Errorf("Expected valid: %v but got %v: Test case %d", testCase.Valid, err, i)
+			continue
+		}
+
+		if actual != testCase.Expected {
+			t.Errorf("Expected %s but got %s: Test case %d", testCase.Expected, actual, i)
+		}
+	}
+}
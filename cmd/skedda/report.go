@@ -0,0 +1,384 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/alyyousuf7/skedda"
+	"github.com/alyyousuf7/skedda/timeparse"
+	"github.com/urfave/cli/v2"
+)
+
+// reportFlags returns the flags shared by the reports
+func reportFlags(noCacheFlag *cli.BoolFlag) []cli.Flag {
+	return append([]cli.Flag{
+		noCacheFlag,
+		&cli.StringFlag{
+			Name:     "from",
+			Usage:    "First `DATE` of the report (e.g. YYYY-MM-DD, -30d, last mon)",
+			Required: true,
+		},
+		&cli.StringFlag{
+			Name:     "to",
+			Usage:    "Last `DATE` of the report (e.g. YYYY-MM-DD, yesterday)",
+			Required: true,
+		},
+		&cli.StringFlag{
+			Name:    "venue",
+			Aliases: []string{"v"},
+			Usage:   "Venue to report (default: all venues)",
+		},
+		&cli.StringSliceFlag{
+			Name:    "spaces",
+			Aliases: []string{"space", "s"},
+			Usage:   "Spaces to report",
+		},
+		&cli.BoolFlag{
+			Name:  "skip-weekends",
+			Usage: "Leave Saturdays and Sundays out of the report",
+		},
+		&cli.IntFlag{
+			Name:  "concurrency",
+			Usage: "Number of days fetched at once",
+			Value: 4,
+		},
+		archiveFlag(),
+		&cli.StringFlag{
+			Name:    "format",
+			Aliases: []string{"f"},
+			Usage:   "Output `FORMAT` (possible values: table, csv, json)",
+			Value:   "table",
+		},
+	}, openingHoursFlags()...)
+}
+
+// openingHoursFlags returns the --opens and --closes flags
+func openingHoursFlags() []cli.Flag {
+	return []cli.Flag{
+		&cli.StringFlag{
+			Name:  "opens",
+			Usage: "`TIME` the spaces open (e.g. 8am, 8:30am, 08:30)",
+			Value: "8:00am",
+		},
+		&cli.StringFlag{
+			Name:  "closes",
+			Usage: "`TIME` the spaces close (e.g. 6pm, 18:00)",
+			Value: "6:00pm",
+		},
+	}
+}
+
+// openingHours returns --opens and --closes as offsets from midnight
+func openingHours(c *cli.Context) (time.Duration, time.Duration, error) {
+	opens, err := timeparse.Clock(c.String("opens"))
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid --opens: %w", err)
+	}
+
+	closes, err := timeparse.Clock(c.String("closes"))
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid --closes: %w", err)
+	}
+
+	if opens >= closes {
+		return 0, 0, fmt.Errorf("--opens cannot be ahead of --closes")
+	}
+
+	return opens, closes, nil
+}
+
+// fetchOccurrences fetches the bookings of the spaces day by day between from
+// and till using a pool of workers, and returns when each space is occupied
+func fetchOccurrences(s *skedda.Skedda, venues skedda.VenueList, spaces skedda.SpaceList, from, till time.Time, workers int) (map[*skedda.Space][]skedda.Interval, error) {
+	days := []time.Time{}
+	for day := from.Truncate(24 * time.Hour); day.Before(till); day = day.Add(24 * time.Hour) {
+		days = append(days, day)
+	}
+
+	return fetchOccurrencesOn(s, venues, spaces, days, workers)
+}
+
+// fetchOccurrencesOn fetches the bookings of the spaces on the days using a
+// pool of workers, and returns when each space is occupied
+func fetchOccurrencesOn(s *skedda.Skedda, venues skedda.VenueList, spaces skedda.SpaceList, days []time.Time, workers int) (map[*skedda.Space][]skedda.Interval, error) {
+	type Job struct {
+		Venue *skedda.Venue
+		Day   time.Time
+	}
+
+	jobs := []Job{}
+	seenVenues := map[int]bool{}
+	for _, space := range spaces {
+		venue := venues.FindByID(space.VenueID)
+		if venue == nil || seenVenues[venue.ID] {
+			continue
+		}
+		seenVenues[venue.ID] = true
+
+		for _, day := range days {
+			jobs = append(jobs, Job{venue, day})
+		}
+	}
+
+	var mu sync.Mutex
+	var firstErr error
+	occurrences := map[*skedda.Space][]skedda.Interval{}
+	seen := map[*skedda.Space]map[string]bool{}
+	for _, space := range spaces {
+		occurrences[space] = []skedda.Interval{}
+		seen[space] = map[string]bool{}
+	}
+
+	worker := func(jobCh <-chan Job, wg *sync.WaitGroup) {
+		defer wg.Done()
+		for job := range jobCh {
+			dayEnd := job.Day.Add(24 * time.Hour)
+			bookings, err := s.Bookings(job.Venue.Domain, job.Day, dayEnd)
+
+			mu.Lock()
+			if err != nil {
+				if firstErr == nil {
+					firstErr = fmt.Errorf("fetching bookings of %s on %s: %w", job.Venue.Name, job.Day.Format("2006-01-02"), err)
+				}
+				mu.Unlock()
+				continue
+			}
+
+			for _, booking := range bookings {
+				for _, id := range booking.SpaceIDs {
+					space := spaces.FindByID(id)
+					if space == nil {
+						continue
+					}
+
+					for _, occurrence := range booking.Occurrences(job.Day, dayEnd) {
+						// Bookings spanning several days are returned for each day
+						key := fmt.Sprintf("%d@%d", booking.ID, occurrence.Start.Unix())
+						if seen[space][key] {
+							continue
+						}
+						seen[space][key] = true
+						occurrences[space] = append(occurrences[space], occurrence)
+					}
+				}
+			}
+			mu.Unlock()
+		}
+	}
+
+	if workers < 1 {
+		workers = 1
+	}
+
+	jobCh := make(chan Job)
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go worker(jobCh, &wg)
+	}
+	for _, job := range jobs {
+		jobCh <- job
+	}
+	close(jobCh)
+	wg.Wait()
+
+	if firstErr != nil {
+		return nil, firstErr
+	}
+
+	return occurrences, nil
+}
+
+// archiveFlag returns the --archive flag of the commands which can read the
+// bookings from the archive
+func archiveFlag() cli.Flag {
+	return &cli.BoolFlag{
+		Name:    "archive",
+		Aliases: []string{"offline"},
+		Usage:   "Read the bookings from the local archive (see `skedda sync`) instead of Skedda",
+	}
+}
+
+// reportSource returns the client, venues and spaces of a report. Reports
+// read from the archive answer from the cached venues and spaces, without
+// resolving the credentials, and without a client.
+func reportSource(c *cli.Context, configPath string, newClient func() (*skedda.Skedda, error), noCache bool, cacheTTL time.Duration) (*skedda.Skedda, skedda.VenueList, skedda.SpaceList, error) {
+	if c.Bool("archive") {
+		venues, spaces, cachedAt, err := loadOffline(configPath)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+
+		fmt.Fprintf(os.Stderr, "Offline: venues and spaces cached %s\n", dataAge(cachedAt))
+		return nil, venues, spaces, nil
+	}
+
+	s, err := newClient()
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	venues, spaces, err := load(s, noCache, cacheTTL, configPath)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	return s, venues, spaces, nil
+}
+
+// reportOccurrences returns when each space is occupied between from and till,
+// read either from the archive or from Skedda
+func reportOccurrences(c *cli.Context, s *skedda.Skedda, configPath string, venues skedda.VenueList, spaces skedda.SpaceList, from, till time.Time) (map[*skedda.Space][]skedda.Interval, error) {
+	if c.Bool("archive") {
+		return archiveOccurrences(configPath, venues, spaces, from, till)
+	}
+
+	if err := s.Auth(); err != nil {
+		return nil, err
+	}
+
+	return fetchOccurrences(s, venues, spaces, from, till, c.Int("concurrency"))
+}
+
+// parseReportPeriod parses the inclusive dates of a report into a time period
+func parseReportPeriod(fromStr, toStr string) (time.Time, time.Time, error) {
+	now := timeparse.Now()
+	from, err := timeparse.Date(fromStr, now)
+	if err != nil {
+		return time.Time{}, time.Time{}, fmt.Errorf("invalid --from: %w", err)
+	}
+
+	to, err := timeparse.Date(toStr, now)
+	if err != nil {
+		return time.Time{}, time.Time{}, fmt.Errorf("invalid --to: %w", err)
+	}
+
+	till := to.Add(24 * time.Hour)
+	if !from.Before(till) {
+		return time.Time{}, time.Time{}, fmt.Errorf("--from cannot be ahead of --to")
+	}
+
+	return from, till, nil
+}
+
+// Utilization of a space over a report period
+type Utilization struct {
+	Venue       string  `json:"venue"`
+	Space       string  `json:"space"`
+	Bookings    int     `json:"bookings"`
+	BookedHours float64 `json:"bookedHours"`
+	OpenHours   float64 `json:"openHours"`
+	Percentage  float64 `json:"utilization"`
+	PeakHour    string  `json:"peakHour"`
+}
+
+// computeUtilization computes how much each space is booked during the opening
+// hours of each day in the period
+func computeUtilization(venues skedda.VenueList, occurrences map[*skedda.Space][]skedda.Interval, from, till time.Time, opens, closes time.Duration, skipWeekends bool) []Utilization {
+	days := []time.Time{}
+	for day := from; day.Before(till); day = day.Add(24 * time.Hour) {
+		if skipWeekends && (day.Weekday() == time.Saturday || day.Weekday() == time.Sunday) {
+			continue
+		}
+		days = append(days, day)
+	}
+
+	spaces := skedda.SpaceList{}
+	for space := range occurrences {
+		spaces = append(spaces, space)
+	}
+	sort.Sort(spaces)
+
+	result := []Utilization{}
+	for _, space := range spaces {
+		u := Utilization{Space: space.Name}
+		if venue := venues.FindByID(space.VenueID); venue != nil {
+			u.Venue = venue.Name
+		}
+
+		hourly := map[int]time.Duration{}
+		for _, day := range days {
+			openFrom := day.Add(opens)
+			openTill := day.Add(closes)
+			u.OpenHours += openTill.Sub(openFrom).Hours()
+
+			clipped := []skedda.Interval{}
+			for _, occurrence := range occurrences[space] {
+				if interval, ok := occurrence.Clip(openFrom, openTill); ok {
+					clipped = append(clipped, interval)
+					u.Bookings++
+				}
+			}
+
+			for _, interval := range skedda.MergeIntervals(clipped) {
+				u.BookedHours += interval.Duration().Hours()
+				for hour := interval.Start.Truncate(time.Hour); hour.Before(interval.End); hour = hour.Add(time.Hour) {
+					if part, ok := interval.Clip(hour, hour.Add(time.Hour)); ok {
+						hourly[hour.Hour()] += part.Duration()
+					}
+				}
+			}
+		}
+
+		if u.OpenHours > 0 {
+			u.Percentage = u.BookedHours / u.OpenHours * 100
+		}
+
+		peak := -1
+		for hour, d := range hourly {
+			if peak == -1 || d > hourly[peak] || (d == hourly[peak] && hour < peak) {
+				peak = hour
+			}
+		}
+		if peak != -1 {
+			start := time.Date(0, 1, 1, peak, 0, 0, 0, time.UTC)
+			u.PeakHour = fmt.Sprintf("%s-%s", start.Format("3pm"), start.Add(time.Hour).Format("3pm"))
+		}
+
+		result = append(result, u)
+	}
+
+	return result
+}
+
+func writeUtilization(w io.Writer, format string, result []Utilization) error {
+	switch format {
+	case "json":
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(result)
+	case "csv":
+		cw := csv.NewWriter(w)
+		cw.Write([]string{"venue", "space", "bookings", "booked_hours", "open_hours", "utilization", "peak_hour"})
+		for _, u := range result {
+			cw.Write([]string{
+				u.Venue,
+				u.Space,
+				strconv.Itoa(u.Bookings),
+				strconv.FormatFloat(u.BookedHours, 'f', 2, 64),
+				strconv.FormatFloat(u.OpenHours, 'f', 2, 64),
+				strconv.FormatFloat(u.Percentage, 'f', 1, 64),
+				u.PeakHour,
+			})
+		}
+		cw.Flush()
+		return cw.Error()
+	case "table":
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "VENUE\tSPACE\tBOOKINGS\tBOOKED\tUTILIZATION\tPEAK HOUR")
+		for _, u := range result {
+			fmt.Fprintf(tw, "%s\t%s\t%d\t%.1fh\t%.1f%%\t%s\n", u.Venue, u.Space, u.Bookings, u.BookedHours, u.Percentage, u.PeakHour)
+		}
+		return tw.Flush()
+	}
+
+	return fmt.Errorf("unknown format: %s", format)
+}
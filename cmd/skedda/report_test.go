@@ -0,0 +1,172 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/alyyousuf7/skedda"
+	"github.com/urfave/cli/v2"
+)
+
+func TestComputeUtilization(t *testing.T) {
+	at := func(day, hour, minute int) time.Time {
+		return time.Date(2020, time.March, day, hour, minute, 0, 0, time.UTC)
+	}
+	interval := func(day, fromHour, fromMinute, tillHour, tillMinute int) skedda.Interval {
+		return skedda.Interval{Start: at(day, fromHour, fromMinute), End: at(day, tillHour, tillMinute)}
+	}
+
+	venues := skedda.VenueList{{ID: 1, Name: "London"}}
+	thames := &skedda.Space{ID: 10, Name: "Thames", VenueID: 1}
+	desk := &skedda.Space{ID: 11, Name: "Desk 1", VenueID: 1}
+	orphan := &skedda.Space{ID: 30, Name: "Seine", VenueID: 3}
+
+	testCases := []struct {
+		Occurrences  map[*skedda.Space][]skedda.Interval
+		From, Till   time.Time
+		SkipWeekends bool
+		Expected     []Utilization
+	}{
+		// Overlapping bookings count once, and bookings are clipped to the
+		// opening hours
+		{
+			map[*skedda.Space][]skedda.Interval{
+				thames: {interval(4, 9, 0, 11, 0), interval(4, 10, 0, 12, 0), interval(5, 7, 0, 9, 0), interval(5, 17, 0, 19, 0), interval(5, 19, 0, 20, 0)},
+				desk:   {},
+			},
+			at(4, 0, 0), at(6, 0, 0), false,
+			[]Utilization{
+				{"London", "Desk 1", 0, 0, 20, 0, ""},
+				{"London", "Thames", 4, 5, 20, 25, "8am-9am"},
+			},
+		},
+		// Weekends are left out, Friday 6 to Monday 9
+		{
+			map[*skedda.Space][]skedda.Interval{
+				thames: {interval(7, 9, 0, 17, 0), interval(9, 14, 0, 14, 30), interval(9, 15, 0, 16, 0), interval(6, 15, 0, 15, 30)},
+			},
+			at(6, 0, 0), at(10, 0, 0), true,
+			[]Utilization{
+				{"London", "Thames", 3, 2, 20, 10, "3pm-4pm"},
+			},
+		},
+		{
+			map[*skedda.Space][]skedda.Interval{
+				thames: {interval(7, 9, 0, 17, 0)},
+			},
+			at(6, 0, 0), at(10, 0, 0), false,
+			[]Utilization{
+				{"London", "Thames", 1, 8, 40, 20, "9am-10am"},
+			},
+		},
+		// Spaces of unknown venues are reported without a venue
+		{
+			map[*skedda.Space][]skedda.Interval{
+				orphan: {interval(4, 8, 0, 18, 0)},
+			},
+			at(4, 0, 0), at(5, 0, 0), false,
+			[]Utilization{
+				{"", "Seine", 1, 10, 10, 100, "8am-9am"},
+			},
+		},
+	}
+
+	for i, testCase := range testCases {
+		result := computeUtilization(venues, testCase.Occurrences, testCase.From, testCase.Till, 8*time.Hour, 18*time.Hour, testCase.SkipWeekends)
+		if len(result) != len(testCase.Expected) {
+			t.Errorf("Expected %v but got %v: Test case %d", testCase.Expected, result, i)
+			continue
+		}
+
+		for j := range result {
+			if result[j] != testCase.Expected[j] {
+				t.Errorf("Expected %+v but got %+v: Test case %d", testCase.Expected[j], result[j], i)
+			}
+		}
+	}
+}
+
+func TestParseReportPeriod(t *testing.T) {
+	testCases := []struct {
+		From, To     string
+		ExpectedFrom time.Time
+		ExpectedTill time.Time
+		Valid        bool
+	}{
+		{"2020-03-04", "2020-03-04", time.Date(2020, time.March, 4, 0, 0, 0, 0, time.UTC), time.Date(2020, time.March, 5, 0, 0, 0, 0, time.UTC), true},
+		{"2020-03-01", "2020-03-31", time.Date(2020, time.March, 1, 0, 0, 0, 0, time.UTC), time.Date(2020, time.April, 1, 0, 0, 0, 0, time.UTC), true},
+		{"2020-03-05", "2020-03-04", time.Time{}, time.Time{}, false},
+		{"someday", "2020-03-04", time.Time{}, time.Time{}, false},
+		{"2020-03-04", "someday", time.Time{}, time.Time{}, false},
+	}
+
+	for i, testCase := range testCases {
+		from, till, err := parseReportPeriod(testCase.From, testCase.To)
+		if (err == nil) != testCase.Valid {
+			t.Errorf("Expected valid: %v but got %v: Test case %d", testCase.Valid, err, i)
+			continue
+		}
+
+		if !from.Equal(testCase.ExpectedFrom) || !till.Equal(testCase.ExpectedTill) {
+			t.Errorf("Expected %v - %v but got %v - %v: Test case %d", testCase.ExpectedFrom, testCase.ExpectedTill, from, till, i)
+		}
+	}
+}
+
+func TestWriteUtilization(t *testing.T) {
+	result := []Utilization{{"London", "Thames", 4, 5, 20, 25, "8am-9am"}}
+
+	testCases := []struct {
+		Format   string
+		Expected string
+		Valid    bool
+	}{
+		{"csv", "venue,space,bookings,booked_hours,open_hours,utilization,peak_hour\nLondon,Thames,4,5.00,20.00,25.0,8am-9am\n", true},
+		{"table", "VENUE   SPACE   BOOKINGS  BOOKED  UTILIZATION  PEAK HOUR\nLondon  Thames  4         5.0h    25.0%        8am-9am\n", true},
+		{"xml", "", false},
+	}
+
+	for i, testCase := range testCases {
+		buf := &bytes.Buffer{}
+		err := writeUtilization(buf, testCase.Format, result)
+		if (err == nil) != testCase.Valid {
+			t.Errorf("Expected valid: %v but got %v: Test case %d", testCase.Valid, err, i)
+			continue
+		}
+
+		if buf.String() != testCase.Expected {
+			t.Errorf("Expected %q but got %q: Test case %d", testCase.Expected, buf.String(), i)
+		}
+	}
+}
+
+func TestReportSource(t *testing.T) {
+	dir, err := ioutil.TempDir("", "skedda")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// Reports read from the archive never resolve the credentials
+	newClient := func() (*skedda.Skedda, error) {
+		t.Errorf("Expected no client to be created")
+		return nil, skedda.ErrCredsMissing
+	}
+
+	c := flagContext(t, []cli.Flag{archiveFlag()}, "--archive")
+	if _, _, _, err := reportSource(c, dir, newClient, false, 0); err == nil {
+		t.Errorf("Expected an error without a cache")
+	}
+
+	if err := saveToCache(testVenues, testSpaces, "jane@acme.com", dir); err != nil {
+		t.Fatal(err)
+	}
+
+	s, venues, spaces, err := reportSource(c, dir, newClient, false, 0)
+	if err != nil || s != nil || len(venues) != len(testVenues) || len(spaces) != len(testSpaces) {
+		t.Errorf("Expected the cached venues and spaces but got %v, %v, %v, %v", s, venues, spaces, err)
+	}
+}
@@ -0,0 +1,341 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/alyyousuf7/skedda"
+)
+
+// apiServer exposes a Skedda client over a JSON API
+type apiServer struct {
+	skedda *skedda.Skedda
+	venues skedda.VenueList
+	spaces skedda.SpaceList
+
+	// mu keeps the requests to Skedda out of the way while signing in again,
+	// and reauths counts the sign ins so that requests finding the session
+	// expired together sign in again once
+	mu      sync.RWMutex
+	reauths int
+}
+
+// newAPIServer initializes apiServer. Signing in again happens in the middle
+// of a request, where nobody can answer a second factor challenge, so the
+// client fails with ErrMFARequired instead of asking for a code.
+func newAPIServer(s *skedda.Skedda, venues skedda.VenueList, spaces skedda.SpaceList) *apiServer {
+	s.SetMFAHandler(nil)
+	return &apiServer{skedda: s, venues: venues, spaces: spaces}
+}
+
+// do runs a request to Skedda, signing in again once if the session expired
+func (a *apiServer) do(request func() error) error {
+	a.mu.RLock()
+	reauths := a.reauths
+	err := request()
+	a.mu.RUnlock()
+	if !errors.Is(err, skedda.ErrSessionExpired) {
+		return err
+	}
+
+	a.mu.Lock()
+	err = nil
+	if a.reauths == reauths {
+		if err = a.skedda.Reauth(); err == nil {
+			a.reauths++
+		}
+	}
+	a.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	return request()
+}
+
+// Handler returns the routes of the API
+func (a *apiServer) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/venues", a.handleVenues)
+	mux.HandleFunc("/spaces", a.handleSpaces)
+	mux.HandleFunc("/bookings", a.handleBookings)
+	mux.HandleFunc("/bookings/", a.handleBooking)
+	mux.HandleFunc("/availability", a.handleAvailability)
+	return mux
+}
+
+// GET /venues
+func (a *apiServer) handleVenues(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		writeJSONError(w, http.StatusMethodNotAllowed, fmt.Errorf("method not allowed"))
+		return
+	}
+
+	writeJSON(w, http.StatusOK, a.venues)
+}
+
+// GET /spaces?venue=ID
+func (a *apiServer) handleSpaces(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		writeJSONError(w, http.StatusMethodNotAllowed, fmt.Errorf("method not allowed"))
+		return
+	}
+
+	spaces := a.spaces
+	if r.URL.Query().Get("venue") != "" {
+		venue, err := a.venue(r.URL.Query().Get("venue"))
+		if err != nil {
+			writeJSONError(w, http.StatusBadRequest, err)
+			return
+		}
+
+		spaces = a.venueSpaces(venue)
+	}
+
+	writeJSON(w, http.StatusOK, spaces)
+}
+
+// GET /bookings?venue=ID&from=TIME&to=TIME
+// POST /bookings {"venue": ID, "spaces": [ID], "title": "", "from": TIME, "to": TIME}
+func (a *apiServer) handleBookings(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case "GET":
+		venue, err := a.venue(r.URL.Query().Get("venue"))
+		if err != nil {
+			writeJSONError(w, http.StatusBadRequest, err)
+			return
+		}
+
+		from, to, err := parseAPIPeriod(r.URL.Query().Get("from"), r.URL.Query().Get("to"))
+		if err != nil {
+			writeJSONError(w, http.StatusBadRequest, err)
+			return
+		}
+
+		var bookings []*skedda.Booking
+		err = a.do(func() (err error) {
+			bookings, err = a.skedda.Bookings(venue.Domain, from, to)
+			return err
+		})
+		if err != nil {
+			writeJSONError(w, http.StatusBadGateway, err)
+			return
+		}
+
+		writeJSON(w, http.StatusOK, bookings)
+	case "POST":
+		body := struct {
+			Venue  int
+			Spaces []int
+			Title  string
+			From   string
+			To     string
+		}{}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			writeJSONError(w, http.StatusBadRequest, err)
+			return
+		}
+
+		venue, err := a.venue(strconv.Itoa(body.Venue))
+		if err != nil {
+			writeJSONError(w, http.StatusBadRequest, err)
+			return
+		}
+
+		for _, id := range body.Spaces {
+			space := a.spaces.FindByID(id)
+			if space == nil || space.VenueID != venue.ID {
+				writeJSONError(w, http.StatusBadRequest, fmt.Errorf("space %d not found in venue", id))
+				return
+			}
+		}
+
+		if len(body.Spaces) == 0 {
+			writeJSONError(w, http.StatusBadRequest, fmt.Errorf("spaces are required"))
+			return
+		}
+
+		title := strings.TrimSpace(body.Title)
+		if title == "" {
+			writeJSONError(w, http.StatusBadRequest, fmt.Errorf("title is required"))
+			return
+		}
+
+		from, to, err := parseAPIPeriod(body.From, body.To)
+		if err != nil {
+			writeJSONError(w, http.StatusBadRequest, err)
+			return
+		}
+
+		err = a.do(func() error {
+			return a.skedda.Book(venue.Domain, venue.ID, body.Spaces, title, from, to)
+		})
+		if err != nil {
+			writeJSONError(w, http.StatusBadGateway, err)
+			return
+		}
+
+		w.WriteHeader(http.StatusCreated)
+	default:
+		writeJSONError(w, http.StatusMethodNotAllowed, fmt.Errorf("method not allowed"))
+	}
+}
+
+// DELETE /bookings/{id}?venue=ID (experimental, see skedda.Cancel)
+func (a *apiServer) handleBooking(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "DELETE" {
+		writeJSONError(w, http.StatusMethodNotAllowed, fmt.Errorf("method not allowed"))
+		return
+	}
+
+	id, err := strconv.Atoi(strings.TrimPrefix(r.URL.Path, "/bookings/"))
+	if err != nil {
+		writeJSONError(w, http.StatusNotFound, fmt.Errorf("invalid booking id"))
+		return
+	}
+
+	venue, err := a.venue(r.URL.Query().Get("venue"))
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	err = a.do(func() error {
+		return a.skedda.Cancel(venue.Domain, id)
+	})
+	if err != nil {
+		writeJSONError(w, http.StatusBadGateway, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GET /availability?spaces=ID,ID&from=TIME&to=TIME
+func (a *apiServer) handleAvailability(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		writeJSONError(w, http.StatusMethodNotAllowed, fmt.Errorf("method not allowed"))
+		return
+	}
+
+	spaces := skedda.SpaceList{}
+	for _, idStr := range strings.Split(r.URL.Query().Get("spaces"), ",") {
+		id, err := strconv.Atoi(strings.TrimSpace(idStr))
+		if err != nil {
+			writeJSONError(w, http.StatusBadRequest, fmt.Errorf("invalid space id: %q", idStr))
+			return
+		}
+
+		space := a.spaces.FindByID(id)
+		if space == nil {
+			writeJSONError(w, http.StatusBadRequest, fmt.Errorf("space %d not found", id))
+			return
+		}
+		spaces = append(spaces, space)
+	}
+
+	from, to, err := parseAPIPeriod(r.URL.Query().Get("from"), r.URL.Query().Get("to"))
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	var spaceBookings map[*skedda.Space][]*skedda.Booking
+	err = a.do(func() (err error) {
+		spaceBookings, err = fetchSpaceBookings(a.skedda, a.venues, spaces, from, to)
+		return err
+	})
+	if err != nil {
+		writeJSONError(w, http.StatusBadGateway, err)
+		return
+	}
+
+	type Availability struct {
+		Space     *skedda.Space
+		Available bool
+		Bookings  []*skedda.Booking
+	}
+
+	sort.Sort(spaces)
+	result := []Availability{}
+	for _, space := range spaces {
+		bookings := spaceBookings[space]
+		result = append(result, Availability{space, len(bookings) == 0, bookings})
+	}
+
+	writeJSON(w, http.StatusOK, result)
+}
+
+func (a *apiServer) venue(idStr string) (*skedda.Venue, error) {
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid venue id: %q", idStr)
+	}
+
+	venue := a.venues.FindByID(id)
+	if venue == nil {
+		return nil, fmt.Errorf("venue %d not found", id)
+	}
+
+	return venue, nil
+}
+
+func (a *apiServer) venueSpaces(venue *skedda.Venue) skedda.SpaceList {
+	spaces := skedda.SpaceList{}
+	for _, space := range a.spaces {
+		if space.VenueID == venue.ID {
+			spaces = append(spaces, space)
+		}
+	}
+
+	return spaces
+}
+
+// parseAPIPeriod parses a time period given as YYYY-MM-DDTHH:MM[:SS] or
+// YYYY-MM-DD, in the venue's local time
+func parseAPIPeriod(fromStr, toStr string) (time.Time, time.Time, error) {
+	layouts := []string{"2006-01-02T15:04:05", "2006-01-02T15:04", "2006-01-02"}
+	parse := func(name, value string) (time.Time, error) {
+		for _, layout := range layouts {
+			if t, err := time.Parse(layout, value); err == nil {
+				return t, nil
+			}
+		}
+		return time.Time{}, fmt.Errorf("invalid %s: %q", name, value)
+	}
+
+	from, err := parse("from", fromStr)
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+
+	to, err := parse("to", toStr)
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+
+	if !from.Before(to) {
+		return time.Time{}, time.Time{}, fmt.Errorf("from cannot be ahead of to")
+	}
+
+	return from, to, nil
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeJSONError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
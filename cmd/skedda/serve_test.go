@@ -0,0 +1,289 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alyyousuf7/skedda"
+)
+
+func TestAPIServer(t *testing.T) {
+	var requests []string
+	s := fakeSkedda(t, func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.Method+" "+r.URL.Path)
+		switch {
+		case r.Method == "GET" && r.URL.Path == "/bookingslists":
+			w.Write([]byte(`{"bookings":[{"id":1,"title":"Standup","start":"2020-03-04T09:00:00","end":"2020-03-04T09:30:00","recurrenceRule":null,"spaces":[10],"venue":1}]}`))
+		case r.Method == "POST" && r.URL.Path == "/bookings":
+			w.Write([]byte("{}"))
+		case r.Method == "DELETE" && r.URL.Path == "/bookings/5":
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	})
+
+	venues := skedda.VenueList{{ID: 1, Name: "London Office", Domain: "acme"}, {ID: 2, Name: "Lisbon Office", Domain: "acme-lisbon"}}
+	spaces := skedda.SpaceList{{ID: 10, Name: "Thames", VenueID: 1}, {ID: 11, Name: "Desk 1", VenueID: 1}, {ID: 20, Name: "Tagus", VenueID: 2}}
+	api := &apiServer{skedda: s, venues: venues, spaces: spaces}
+
+	testCases := []struct {
+		Method   string
+		URL      string
+		Body     string
+		Status   int
+		Items    int
+		Requests []string
+	}{
+		{"GET", "/venues", "", http.StatusOK, 2, nil},
+		{"POST", "/venues", "", http.StatusMethodNotAllowed, -1, nil},
+		{"GET", "/spaces", "", http.StatusOK, 3, nil},
+		{"GET", "/spaces?venue=1", "", http.StatusOK, 2, nil},
+		{"GET", "/spaces?venue=9", "", http.StatusBadRequest, -1, nil},
+		{"GET", "/bookings?venue=1&from=2020-03-04&to=2020-03-05", "", http.StatusOK, 1, []string{"GET /bookingslists"}},
+		{"GET", "/bookings?venue=1&from=2020-03-05&to=2020-03-04", "", http.StatusBadRequest, -1, nil},
+		{"GET", "/bookings?venue=x&from=2020-03-04&to=2020-03-05", "", http.StatusBadRequest, -1, nil},
+		{"POST", "/bookings", `{"venue":1,"spaces":[10],"title":"Retro","from":"2020-03-04T10:00","to":"2020-03-04T11:00"}`, http.StatusCreated, -1, []string{"POST /bookings"}},
+		{"POST", "/bookings", `{"venue":1,"spaces":[20],"title":"Retro","from":"2020-03-04T10:00","to":"2020-03-04T11:00"}`, http.StatusBadRequest, -1, nil},
+		{"POST", "/bookings", `{"venue":1,"spaces":[],"title":"Retro","from":"2020-03-04T10:00","to":"2020-03-04T11:00"}`, http.StatusBadRequest, -1, nil},
+		{"POST", "/bookings", `{"venue":1,"spaces":[10],"title":" ","from":"2020-03-04T10:00","to":"2020-03-04T11:00"}`, http.StatusBadRequest, -1, nil},
+		{"POST", "/bookings", `{"venue":`, http.StatusBadRequest, -1, nil},
+		{"PUT", "/bookings", "", http.StatusMethodNotAllowed, -1, nil},
+		{"DELETE", "/bookings/5?venue=1", "", http.StatusNoContent, -1, []string{"DELETE /bookings/5"}},
+		{"DELETE", "/bookings/x?venue=1", "", http.StatusNotFound, -1, nil},
+		{"DELETE", "/bookings/6?venue=1", "", http.StatusBadGateway, -1, []string{"DELETE /bookings/6"}},
+		{"GET", "/availability?spaces=10,11&from=2020-03-04T09:00&to=2020-03-04T10:00", "", http.StatusOK, 2, []string{"GET /bookingslists"}},
+		{"GET", "/availability?spaces=10,99&from=2020-03-04T09:00&to=2020-03-04T10:00", "", http.StatusBadRequest, -1, nil},
+	}
+
+	for i, testCase := range testCases {
+		requests = nil
+		req := httptest.NewRequest(testCase.Method, testCase.URL, strings.NewReader(testCase.Body))
+		rec := httptest.NewRecorder()
+		api.Handler().ServeHTTP(rec, req)
+
+		if rec.Code != testCase.Status {
+			t.Errorf("Expected %d but got %d %s: Test case %d", testCase.Status, rec.Code, rec.Body, i)
+			continue
+		}
+
+		if strings.Join(requests, ", ") != strings.Join(testCase.Requests, ", ") {
+			t.Errorf("Expected %v but got %v: Test case %d", testCase.Requests, requests, i)
+		}
+
+		if testCase.Items < 0 {
+			continue
+		}
+
+		var items []interface{}
+		if err := json.NewDecoder(rec.Body).Decode(&items); err != nil || len(items) != testCase.Items {
+			t.Errorf("Expected %d items but got %d (%v): Test case %d", testCase.Items, len(items), err, i)
+		}
+	}
+}
+
+func TestAPIServerAvailability(t *testing.T) {
+	s := fakeSkedda(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"bookings":[{"id":1,"title":"Standup","start":"2020-03-04T09:00:00","end":"2020-03-04T09:30:00","recurrenceRule":null,"spaces":[10],"venue":1}]}`))
+	})
+
+	api := &apiServer{
+		skedda: s,
+		venues: skedda.VenueList{{ID: 1, Name: "London Office", Domain: "acme"}},
+		spaces: skedda.SpaceList{{ID: 10, Name: "Thames", VenueID: 1}, {ID: 11, Name: "Desk 1", VenueID: 1}},
+	}
+
+	req := httptest.NewRequest("GET", "/availability?spaces=10,11&from=2020-03-04T09:00&to=2020-03-04T10:00", nil)
+	rec := httptest.NewRecorder()
+	api.Handler().ServeHTTP(rec, req)
+
+	var result []struct {
+		Space     struct{ ID int }
+		Available bool
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&result); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[int]bool{10: false, 11: true}
+	if len(result) != len(expected) {
+		t.Fatalf("Expected %d spaces but got %d", len(expected), len(result))
+	}
+
+	for _, availability := range result {
+		if availability.Available != expected[availability.Space.ID] {
+			t.Errorf("Expected space %d available: %v but got %v", availability.Space.ID, expected[availability.Space.ID], availability.Available)
+		}
+	}
+}
+
+func TestAPIServerReauth(t *testing.T) {
+	logins := 0
+	s, _ := skedda.NewWithCreds("jane@acme.com", "s3cr3t")
+	s.SetTransport(roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		rec := httptest.NewRecorder()
+		switch req.URL.Path {
+		case "/logins":
+			logins++
+			rec.WriteString("{}")
+		case "/booking":
+			// The session expires until signing in again
+			if logins < 2 {
+				rec.Header().Set("Location", "https://www.skedda.com/account/login?ReturnUrl=%2Fbooking")
+				rec.WriteHeader(http.StatusFound)
+				break
+			}
+			rec.WriteString(`<input name="__RequestVerificationToken" type="hidden" value="token" />`)
+		default:
+			rec.WriteString(`{"bookings":[]}`)
+		}
+
+		res := rec.Result()
+		res.Request = req
+		return res, nil
+	}))
+
+	if err := s.Auth(); err != nil {
+		t.Fatal(err)
+	}
+
+	api := newAPIServer(s, skedda.VenueList{{ID: 1, Name: "London Office", Domain: "acme"}}, nil)
+	req := httptest.NewRequest("GET", "/bookings?venue=1&from=2020-03-04&to=2020-03-05", nil)
+	rec := httptest.NewRecorder()
+	api.Handler().ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK || logins != 2 {
+		t.Errorf("Expected 200 after signing in again but got %d after %d logins: %s", rec.Code, logins, rec.Body)
+	}
+}
+
+func TestAPIServerReauthMFA(t *testing.T) {
+	logins := 0
+	s, _ := skedda.NewWithCreds("jane@acme.com", "s3cr3t")
+	s.SetTransport(roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		rec := httptest.NewRecorder()
+		switch req.URL.Path {
+		case "/logins":
+			logins++
+			if logins > 1 {
+				rec.WriteHeader(http.StatusBadRequest)
+				rec.WriteString(`{"errors":[{"code":"TwoFactorRequired","detail":"Enter the verification code"}]}`)
+				break
+			}
+			rec.WriteString("{}")
+		default:
+			rec.Header().Set("Location", "https://www.skedda.com/account/login?ReturnUrl=%2Fbooking")
+			rec.WriteHeader(http.StatusFound)
+		}
+
+		res := rec.Result()
+		res.Request = req
+		return res, nil
+	}))
+
+	if err := s.Auth(); err != nil {
+		t.Fatal(err)
+	}
+
+	prompted := false
+	s.SetMFAHandler(func(challenge skedda.MFAChallenge) (string, error) {
+		prompted = true
+		return "123456", nil
+	})
+
+	api := newAPIServer(s, skedda.VenueList{{ID: 1, Name: "London Office", Domain: "acme"}}, nil)
+	err := api.do(func() error {
+		_, err := s.Bookings("acme", time.Date(2020, time.March, 4, 0, 0, 0, 0, time.UTC), time.Date(2020, time.March, 5, 0, 0, 0, 0, time.UTC))
+		return err
+	})
+
+	if !errors.Is(err, skedda.ErrMFARequired) || prompted {
+		t.Errorf("Expected %v without prompting but got %v, prompted: %v", skedda.ErrMFARequired, err, prompted)
+	}
+}
+
+func TestAPIServerReauthOnce(t *testing.T) {
+	logins := 0
+	s, _ := skedda.NewWithCreds("jane@acme.com", "s3cr3t")
+	s.SetTransport(roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		if req.URL.Path == "/logins" {
+			logins++
+		}
+
+		rec := httptest.NewRecorder()
+		rec.WriteString("{}")
+		res := rec.Result()
+		res.Request = req
+		return res, nil
+	}))
+
+	api := newAPIServer(s, nil, nil)
+
+	// Both requests find the session expired before either signs in again
+	var started sync.WaitGroup
+	started.Add(2)
+	var done sync.WaitGroup
+	for i := 0; i < 2; i++ {
+		done.Add(1)
+		go func() {
+			defer done.Done()
+
+			attempts := 0
+			err := api.do(func() error {
+				attempts++
+				if attempts == 1 {
+					started.Done()
+					started.Wait()
+					return skedda.ErrSessionExpired
+				}
+				return nil
+			})
+			if err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	done.Wait()
+
+	if logins != 1 {
+		t.Errorf("Expected to sign in again once but got %d logins", logins)
+	}
+}
+
+func TestRequireBearerToken(t *testing.T) {
+	handler := requireBearerToken("s3cr3t", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	testCases := []struct {
+		Authorization string
+		Status        int
+	}{
+		{"", http.StatusUnauthorized},
+		{"s3cr3t", http.StatusUnauthorized},
+		{"Bearer other", http.StatusUnauthorized},
+		{"Basic s3cr3t", http.StatusUnauthorized},
+		{"Bearer s3cr3t", http.StatusOK},
+	}
+
+	for i, testCase := range testCases {
+		req := httptest.NewRequest("GET", "/venues", nil)
+		if testCase.Authorization != "" {
+			req.Header.Set("Authorization", testCase.Authorization)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+
+		if rec.Code != testCase.Status {
+			body, _ := ioutil.ReadAll(rec.Body)
+			t.Errorf("Expected %d but got %d %s: Test case %d", testCase.Status, rec.Code, body, i)
+		}
+	}
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/alyyousuf7/skedda"
+)
+
+// bookingOpensAt returns the moment bookings for onDate open, given that the
+// venue opens bookings leadDays ahead at the time of the day opensAt
+func bookingOpensAt(onDate time.Time, leadDays int, opensAt time.Time, loc *time.Location) time.Time {
+	return time.Date(onDate.Year(), onDate.Month(), onDate.Day()-leadDays, opensAt.Hour(), opensAt.Minute(), 0, 0, loc)
+}
+
+// venueLocation resolves the time zone of a venue, preferring tz when given
+func venueLocation(venue *skedda.Venue, tz string) (*time.Location, error) {
+	if tz != "" {
+		return time.LoadLocation(tz)
+	}
+
+	loc, err := venue.Location()
+	if err != nil {
+		fmt.Printf("Could not determine the time zone of %s, using local time. Use --tz to override.\n", venue.Name)
+		return time.Local, nil
+	}
+
+	return loc, nil
+}
+
+// venueNow returns the current time of the day in a venue, expressed like the
+// times of the bookings
+func venueNow(loc *time.Location) time.Time {
+	now := time.Now().In(loc)
+	return time.Date(now.Year(), now.Month(), now.Day(), now.Hour(), now.Minute(), now.Second(), 0, time.UTC)
+}
+
+// sleepUntil blocks until t or until ctx is done
+func sleepUntil(ctx context.Context, t time.Time) error {
+	d := time.Until(t)
+	if d <= 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// measureClockSkew returns Skedda's clock skew, or zero when it cannot be
+// measured
+func measureClockSkew(s *skedda.Skedda) time.Duration {
+	skew, err := s.ClockSkew()
+	if err != nil {
+		fmt.Println("Failed to measure clock skew, assuming none:", err)
+		return 0
+	}
+
+	return skew
+}
+
+// snipeAt returns when the window opening at opensAt on Skedda's clock opens
+// on the local clock, Skedda's clock being skew ahead of it
+func snipeAt(opensAt time.Time, skew time.Duration) time.Time {
+	return opensAt.Add(-skew)
+}
+
+// retryable tells whether booking again may succeed after err. Bookings off
+// the grid of the venue and failed sign-ins fail the same way every time.
+func retryable(err error) bool {
+	for _, target := range []error{skedda.ErrOffGrid, skedda.ErrBookingTooShort, skedda.ErrBookingTooLong, skedda.ErrSessionExpired, skedda.ErrAuthFailed, skedda.ErrMFARequired} {
+		if errors.Is(err, target) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// retryBook books until it succeeds, fails with an error which is not
+// retryable, or retryFor has passed, waiting interval between the attempts
+func retryBook(ctx context.Context, book func() error, retryFor, interval time.Duration) error {
+	deadline := time.Now().Add(retryFor)
+	for attempt := 1; ; attempt++ {
+		err := book()
+		if err == nil {
+			return nil
+		}
+
+		fmt.Printf("Attempt %d failed: %s\n", attempt, err)
+		if !retryable(err) || time.Now().After(deadline) {
+			return err
+		}
+
+		if err := sleepUntil(ctx, time.Now().Add(interval)); err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/alyyousuf7/skedda"
+)
+
+func TestBookingOpensAt(t *testing.T) {
+	london, err := time.LoadLocation("Europe/London")
+	if err != nil {
+		t.Skip(err)
+	}
+
+	date := func(year int, month time.Month, day int) time.Time {
+		return time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+	}
+	clock := func(hour, minute int) time.Time {
+		return time.Time{}.Add(time.Duration(hour)*time.Hour + time.Duration(minute)*time.Minute)
+	}
+
+	testCases := []struct {
+		OnDate   time.Time
+		Lead     int
+		OpensAt  time.Time
+		Expected time.Time
+	}{
+		// Summer time starts at 1am on Sunday 29 March 2020
+		{date(2020, time.April, 12), 14, clock(0, 0), time.Date(2020, time.March, 29, 0, 0, 0, 0, time.UTC)},
+		{date(2020, time.April, 13), 14, clock(0, 0), time.Date(2020, time.March, 29, 23, 0, 0, 0, time.UTC)},
+		{date(2020, time.April, 12), 14, clock(9, 30), time.Date(2020, time.March, 29, 8, 30, 0, 0, time.UTC)},
+		// and ends at 2am on Sunday 25 October 2020
+		{date(2020, time.November, 8), 14, clock(9, 30), time.Date(2020, time.October, 25, 9, 30, 0, 0, time.UTC)},
+		// Midnight across the end of a month, a leap day and a year
+		{date(2020, time.March, 14), 14, clock(0, 0), time.Date(2020, time.February, 29, 0, 0, 0, 0, time.UTC)},
+		{date(2021, time.January, 10), 14, clock(0, 0), time.Date(2020, time.December, 27, 0, 0, 0, 0, time.UTC)},
+		{date(2020, time.July, 1), 1, clock(0, 0), time.Date(2020, time.June, 29, 23, 0, 0, 0, time.UTC)},
+		{date(2020, time.July, 1), 0, clock(23, 59), time.Date(2020, time.July, 1, 22, 59, 0, 0, time.UTC)},
+	}
+
+	for i, testCase := range testCases {
+		if result := bookingOpensAt(testCase.OnDate, testCase.Lead, testCase.OpensAt, london); !result.Equal(testCase.Expected) {
+			t.Errorf("Expected %v but got %v: Test case %d", testCase.Expected, result.UTC(), i)
+		}
+	}
+}
+
+func TestSnipeAt(t *testing.T) {
+	opensAt := time.Date(2020, time.March, 4, 0, 0, 0, 0, time.UTC)
+
+	testCases := []struct {
+		Skew     time.Duration
+		Expected time.Time
+	}{
+		{0, opensAt},
+		// Skedda's clock is ahead, its midnight comes first on the local clock
+		{2 * time.Second, opensAt.Add(-2 * time.Second)},
+		{-1500 * time.Millisecond, opensAt.Add(1500 * time.Millisecond)},
+	}
+
+	for i, testCase := range testCases {
+		if result := snipeAt(opensAt, testCase.Skew); !result.Equal(testCase.Expected) {
+			t.Errorf("Expected %v but got %v: Test case %d", testCase.Expected, result, i)
+		}
+	}
+}
+
+func TestRetryBook(t *testing.T) {
+	from := time.Date(2020, time.March, 4, 9, 0, 0, 0, time.UTC)
+
+	testCases := []struct {
+		Failures int
+		From     time.Time
+		Attempts int
+		Err      error
+	}{
+		// Booked once the window opens
+		{2, from, 3, nil},
+		// Off the grid, which no retry fixes
+		{0, from.Add(5 * time.Minute), 0, skedda.ErrOffGrid},
+		// Never opens within the retries
+		{1000, from, -1, errors.New("not bookable yet")},
+	}
+
+	for i, testCase := range testCases {
+		attempts := 0
+		s := fakeSkedda(t, func(w http.ResponseWriter, r *http.Request) {
+			attempts++
+			if attempts <= testCase.Failures {
+				w.WriteHeader(http.StatusBadRequest)
+				w.Write([]byte(`{"errors":[{"detail":"not bookable yet"}]}`))
+				return
+			}
+			w.Write([]byte("{}"))
+		})
+		s.SetGrid(1, skedda.Grid{})
+
+		err := retryBook(context.Background(), func() error {
+			return s.BookWithToken("acme", "token", 1, []int{2}, "Standup", testCase.From, testCase.From.Add(30*time.Minute))
+		}, 50*time.Millisecond, time.Millisecond)
+
+		switch {
+		case testCase.Err == nil && err != nil:
+			t.Errorf("Expected no error but got %v: Test case %d", err, i)
+		case testCase.Err != nil && (err == nil || (!errors.Is(err, testCase.Err) && err.Error() != testCase.Err.Error())):
+			t.Errorf("Expected %v but got %v: Test case %d", testCase.Err, err, i)
+		}
+
+		if testCase.Attempts >= 0 && attempts != testCase.Attempts {
+			t.Errorf("Expected %d attempts but got %d: Test case %d", testCase.Attempts, attempts, i)
+		}
+
+		if testCase.Attempts < 0 && (attempts < 2 || attempts >= testCase.Failures) {
+			t.Errorf("Expected to retry until the deadline but got %d attempts: Test case %d", attempts, i)
+		}
+	}
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/alyyousuf7/skedda"
+	"github.com/urfave/cli/v2"
+)
+
+// pollInterval returns --interval, which must be positive not to poll Skedda
+// without pause
+func pollInterval(c *cli.Context) (time.Duration, error) {
+	interval := c.Duration("interval")
+	if interval <= 0 {
+		return 0, fmt.Errorf("--interval must be positive, got %s", interval)
+	}
+
+	return interval, nil
+}
+
+// selectSpaces returns the spaces of the venue or the matching spaces, or all
+// spaces when neither is given
+func selectSpaces(venues skedda.VenueList, spaces skedda.SpaceList, venueStr string, spaceStrs []string) (skedda.SpaceList, error) {
+	var filteredSpaces skedda.SpaceList
+	if venueStr != "" {
+		venue, err := matchVenue(venues, venueStr)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, s := range spaces {
+			if s.VenueID == venue.ID {
+				filteredSpaces = append(filteredSpaces, s)
+			}
+		}
+	} else if len(spaceStrs) > 0 {
+		filteredSpaces = matchSpaces(spaces, spaceStrs)
+	} else {
+		filteredSpaces = spaces
+	}
+
+	if len(filteredSpaces) == 0 {
+		return nil, fmt.Errorf("no spaces found")
+	}
+
+	return filteredSpaces, nil
+}
+
+// spaceDomains returns the domains of the venues the spaces belong to
+func spaceDomains(venues skedda.VenueList, spaces skedda.SpaceList) []string {
+	domains := []string{}
+	seen := map[int]bool{}
+	for _, space := range spaces {
+		venue := venues.FindByID(space.VenueID)
+		if venue == nil || seen[venue.ID] {
+			continue
+		}
+
+		seen[venue.ID] = true
+		domains = append(domains, venue.Domain)
+	}
+
+	return domains
+}
+
+// eventInSpaces tells whether a booking event concerns any of the spaces
+func eventInSpaces(event skedda.BookingEvent, spaces skedda.SpaceList) bool {
+	for _, booking := range []*skedda.Booking{event.Booking, event.Previous} {
+		if booking == nil {
+			continue
+		}
+
+		for _, id := range booking.SpaceIDs {
+			if spaces.FindByID(id) != nil {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// bookingSpaceNames returns the names of the known spaces of a booking
+func bookingSpaceNames(booking *skedda.Booking, spaces skedda.SpaceList) string {
+	names := []string{}
+	for _, id := range booking.SpaceIDs {
+		if space := spaces.FindByID(id); space != nil {
+			names = append(names, space.Name)
+		}
+	}
+
+	return strings.Join(names, ", ")
+}
+
+// printBookingEvent prints a booking event as a single line
+func printBookingEvent(event skedda.BookingEvent, spaces skedda.SpaceList) {
+	eventTime := time.Now().Format("15:04:05")
+	if event.Err != nil {
+		fmt.Printf("[%s] Failed to fetch bookings of %s: %s\n", eventTime, event.Domain, event.Err)
+		return
+	}
+
+	fmt.Printf("[%s] %-7s %s -- %s\n", eventTime, event.Type, bookingSpaceNames(event.Booking, spaces), event.Booking)
+	if event.Previous != nil {
+		fmt.Printf("\t  was: %s\n", event.Previous)
+	}
+}
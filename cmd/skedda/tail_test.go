@@ -0,0 +1,35 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/urfave/cli/v2"
+)
+
+func TestPollInterval(t *testing.T) {
+	testCases := []struct {
+		Args     []string
+		Expected time.Duration
+		Valid    bool
+	}{
+		{nil, time.Minute, true},
+		{[]string{"--interval", "30s"}, 30 * time.Second, true},
+		{[]string{"--interval", "0"}, 0, false},
+		{[]string{"--interval", "-1m"}, 0, false},
+	}
+
+	for i, testCase := range testCases {
+		c := flagContext(t, []cli.Flag{&cli.DurationFlag{Name: "interval", Value: time.Minute}}, testCase.Args...)
+
+		interval, err := pollInterval(c)
+		if (err == nil) != testCase.Valid {
+			t.Errorf("Expected valid: %v but got %v: Test case %d", testCase.Valid, err, i)
+			continue
+		}
+
+		if interval != testCase.Expected {
+			t.Errorf("Expected %v but got %v: Test case %d", testCase.Expected, interval, i)
+		}
+	}
+}
@@ -0,0 +1,210 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/alyyousuf7/skedda"
+	"github.com/alyyousuf7/skedda/timeparse"
+	"github.com/urfave/cli/v2"
+)
+
+// timeRangeFlags returns the --on, --from, --till and --for flags shared by
+// commands working on a time period
+func timeRangeFlags(action string) []cli.Flag {
+	return []cli.Flag{
+		&cli.StringFlag{
+			Name:        "on",
+			Aliases:     []string{"d"},
+			Usage:       fmt.Sprintf("`DATE` to %s (e.g. today, tomorrow, fri, next tue, +3d, YYYY-MM-DD)", action),
+			DefaultText: "today",
+		},
+		&cli.StringFlag{
+			Name:        "from",
+			Aliases:     []string{"a"},
+			Usage:       "`TIME` from (e.g. 3pm, 3:30pm, 15:30, now, +30m, in 2h), or a range (e.g. 2-3:30pm)",
+			DefaultText: "start of the day",
+		},
+		&cli.StringFlag{
+			Name:        "till",
+			Aliases:     []string{"b"},
+			Usage:       "`TIME` till (e.g. 4pm, 16:00, or +1h after --from)",
+			DefaultText: "30 minutes after --from",
+		},
+		&cli.StringFlag{
+			Name:  "for",
+			Usage: "`DURATION` instead of --till (e.g. 45m, 1h30m, 2 hours)",
+		},
+	}
+}
+
+// parseTimeRange resolves --on, --from, --till and --for into a date and a
+// time period. A relative --from, like "now" or "in 2h", sets the date itself,
+// and an offset --till, like "+1h", counts from --from.
+func parseTimeRange(c *cli.Context) (onDate, from, till time.Time, err error) {
+	return parseTimeRangeOn(c, c.String("on"))
+}
+
+// parseTimeRangeOn is parseTimeRange with the date given instead of --on
+func parseTimeRangeOn(c *cli.Context, onStr string) (onDate, from, till time.Time, err error) {
+	now := timeparse.Now()
+	onDate, err = timeparse.Date(onStr, now)
+	if err != nil {
+		return
+	}
+
+	fromStr, tillStr, forStr := c.String("from"), c.String("till"), c.String("for")
+	if tillStr != "" && forStr != "" {
+		err = fmt.Errorf("--till and --for cannot be used together")
+		return
+	}
+
+	if fromStr == "" {
+		if tillStr != "" || forStr != "" {
+			err = fmt.Errorf("--from is required when --till or --for is provided")
+			return
+		}
+
+		// Consider full day
+		from = onDate
+		till = onDate.Add(24 * time.Hour)
+		return
+	}
+
+	if timeparse.IsRange(fromStr) {
+		if tillStr != "" || forStr != "" {
+			err = fmt.Errorf("--from cannot be a range when --till or --for is provided")
+			return
+		}
+
+		from, till, err = timeparse.Range(fromStr, onDate, now)
+		if err != nil {
+			return
+		}
+	} else {
+		from, err = timeparse.Time(fromStr, onDate, now)
+		if err != nil {
+			return
+		}
+
+		switch {
+		case timeparse.IsOffset(tillStr):
+			till, err = timeparse.Time(tillStr, from, from)
+		case tillStr != "":
+			till, err = timeparse.Time(tillStr, from, now)
+		case forStr != "":
+			var d time.Duration
+			d, err = timeparse.Duration(forStr)
+			till = from.Add(d)
+		default:
+			till = from.Add(30 * time.Minute)
+		}
+		if err != nil {
+			return
+		}
+	}
+
+	onDate = from.Truncate(24 * time.Hour)
+	if !from.Before(till) {
+		err = fmt.Errorf("--from cannot be ahead of --till")
+	}
+	return
+}
+
+var snapFlag = cli.StringFlag{
+	Name:  "snap",
+	Usage: "Round --from and --till onto the booking grid of the venue (possible values: nearest, down, up)",
+}
+
+// gridFlags returns the flags overriding the booking grid of the venue, as
+// told by Skedda
+func gridFlags() []cli.Flag {
+	return []cli.Flag{
+		&cli.StringFlag{
+			Name:        "slot",
+			Usage:       "`DURATION` of the booking slots of the venue",
+			DefaultText: "as told by Skedda, or 15m",
+		},
+		&cli.StringFlag{
+			Name:        "min-length",
+			Usage:       "Shortest `DURATION` the venue allows to book",
+			DefaultText: "as told by Skedda",
+		},
+		&cli.StringFlag{
+			Name:        "max-length",
+			Usage:       "Longest `DURATION` the venue allows to book",
+			DefaultText: "as told by Skedda",
+		},
+	}
+}
+
+// venueGrid returns the booking grid of the venue, overridden by --slot,
+// --min-length and --max-length. The slot is DefaultSlot when neither
+// Skedda nor --slot tell it, or when there is no venue.
+func venueGrid(c *cli.Context, venue *skedda.Venue) (skedda.Grid, error) {
+	grid := skedda.Grid{}
+	if venue != nil {
+		grid = venue.Grid()
+	}
+
+	for _, f := range []struct {
+		name string
+		d    *time.Duration
+	}{
+		{"slot", &grid.Slot},
+		{"min-length", &grid.MinLength},
+		{"max-length", &grid.MaxLength},
+	} {
+		if c.String(f.name) == "" {
+			continue
+		}
+
+		d, err := timeparse.Duration(c.String(f.name))
+		if err != nil {
+			return grid, fmt.Errorf("invalid --%s: %w", f.name, err)
+		}
+		*f.d = d
+	}
+
+	if grid.Slot == 0 {
+		grid.Slot = skedda.DefaultSlot
+	}
+
+	if grid.Slot < 0 || (24*time.Hour)%grid.Slot != 0 {
+		return grid, fmt.Errorf("--slot has to divide a day")
+	}
+
+	return grid, nil
+}
+
+// snapToGrid rounds a time period onto the booking grid of the venue as asked
+// by --snap, telling when it does, and validates it against the grid. The
+// grid is set on the client too, for Book to validate against.
+func snapToGrid(c *cli.Context, s *skedda.Skedda, venue *skedda.Venue, from, till time.Time) (time.Time, time.Time, error) {
+	mode, err := skedda.ParseSnapMode(c.String("snap"))
+	if err != nil {
+		return from, till, err
+	}
+
+	grid, err := venueGrid(c, venue)
+	if err != nil {
+		return from, till, err
+	}
+	s.SetGrid(venue.ID, grid)
+
+	snappedFrom, snappedTill := grid.Snap(from, till, mode)
+	if !snappedFrom.Equal(from) || !snappedTill.Equal(till) {
+		timeFormat := "3:04pm"
+		fmt.Printf("Snapped %s - %s to %s - %s, the booking grid of %s\n", from.Format(timeFormat), till.Format(timeFormat), snappedFrom.Format(timeFormat), snappedTill.Format(timeFormat), venue.Name)
+	}
+
+	if err := grid.Validate(snappedFrom, snappedTill); err != nil {
+		if errors.Is(err, skedda.ErrOffGrid) && mode == skedda.SnapNone {
+			return from, till, fmt.Errorf("%w, use --snap to round it", err)
+		}
+		return from, till, err
+	}
+
+	return snappedFrom, snappedTill, nil
+}
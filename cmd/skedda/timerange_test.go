@@ -0,0 +1,119 @@
+package main
+
+import (
+	"flag"
+	"testing"
+	"time"
+
+	"github.com/alyyousuf7/skedda"
+	"github.com/alyyousuf7/skedda/timeparse"
+	"github.com/urfave/cli/v2"
+)
+
+// flagContext parses the arguments with the flags into a context
+func flagContext(t *testing.T, flags []cli.Flag, args ...string) *cli.Context {
+	set := flag.NewFlagSet("test", flag.ContinueOnError)
+	for _, f := range flags {
+		if err := f.Apply(set); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := set.Parse(args); err != nil {
+		t.Fatal(err)
+	}
+
+	return cli.NewContext(cli.NewApp(), set, nil)
+}
+
+func TestParseTimeRange(t *testing.T) {
+	day := time.Date(2020, time.March, 4, 0, 0, 0, 0, time.UTC)
+	at := func(hour, minute int) time.Time {
+		return day.Add(time.Duration(hour)*time.Hour + time.Duration(minute)*time.Minute)
+	}
+
+	testCases := []struct {
+		Args     []string
+		From     time.Time
+		Till     time.Time
+		Expected bool
+	}{
+		{[]string{"--on", "2020-03-04"}, at(0, 0), at(24, 0), true},
+		{[]string{"--on", "2020-03-04", "--from", "3pm"}, at(15, 0), at(15, 30), true},
+		{[]string{"--on", "2020-03-04", "--from", "3pm", "--till", "16:15"}, at(15, 0), at(16, 15), true},
+		{[]string{"--on", "2020-03-04", "--from", "3pm", "--till", "+1h"}, at(15, 0), at(16, 0), true},
+		{[]string{"--on", "2020-03-04", "--from", "3pm", "--till", "in 90m"}, at(15, 0), at(16, 30), true},
+		{[]string{"--on", "2020-03-04", "--from", "3pm", "--for", "45m"}, at(15, 0), at(15, 45), true},
+		{[]string{"--on", "2020-03-04", "--from", "2-3:30pm"}, at(14, 0), at(15, 30), true},
+		{[]string{"--on", "2020-03-04", "--from", "11pm", "--for", "2h"}, at(23, 0), at(25, 0), true},
+		{[]string{"--on", "2020-03-04", "--till", "3pm"}, time.Time{}, time.Time{}, false},
+		{[]string{"--on", "2020-03-04", "--for", "1h"}, time.Time{}, time.Time{}, false},
+		{[]string{"--on", "2020-03-04", "--from", "3pm", "--till", "4pm", "--for", "1h"}, time.Time{}, time.Time{}, false},
+		{[]string{"--on", "2020-03-04", "--from", "2-3pm", "--till", "4pm"}, time.Time{}, time.Time{}, false},
+		{[]string{"--on", "2020-03-04", "--from", "3pm", "--till", "2pm"}, time.Time{}, time.Time{}, false},
+		{[]string{"--on", "someday"}, time.Time{}, time.Time{}, false},
+	}
+
+	for i, testCase := range testCases {
+		c := flagContext(t, timeRangeFlags("book"), testCase.Args...)
+		onDate, from, till, err := parseTimeRange(c)
+		if (err == nil) != testCase.Expected {
+			t.Errorf("Expected valid: %v but got %v: Test case %d", testCase.Expected, err, i)
+			continue
+		}
+
+		if err != nil {
+			continue
+		}
+
+		if !from.Equal(testCase.From) || !till.Equal(testCase.Till) || !onDate.Equal(day) {
+			t.Errorf("Expected %v, %v - %v but got %v, %v - %v: Test case %d", day, testCase.From, testCase.Till, onDate, from, till, i)
+		}
+	}
+
+	// A relative --from sets the date
+	c := flagContext(t, timeRangeFlags("book"), "--on", "2020-03-04", "--from", "in 2h")
+	onDate, from, till, err := parseTimeRange(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if expected := timeparse.Now().Add(2 * time.Hour); from.Sub(expected) > time.Minute || expected.Sub(from) > time.Minute {
+		t.Errorf("Expected about %v but got %v", expected, from)
+	}
+
+	if !onDate.Equal(from.Truncate(24*time.Hour)) || till.Sub(from) != 30*time.Minute {
+		t.Errorf("Expected the date and length of %v but got %v, %v - %v", from, onDate, from, till)
+	}
+}
+
+func TestVenueGrid(t *testing.T) {
+	told := &skedda.Venue{ID: 1, SlotLength: 30, MinBookingLength: 60}
+	untold := &skedda.Venue{ID: 2}
+
+	testCases := []struct {
+		Venue    *skedda.Venue
+		Args     []string
+		Expected skedda.Grid
+		Valid    bool
+	}{
+		{told, nil, skedda.Grid{Slot: 30 * time.Minute, MinLength: time.Hour}, true},
+		{told, []string{"--slot", "1h", "--max-length", "4h"}, skedda.Grid{Slot: time.Hour, MinLength: time.Hour, MaxLength: 4 * time.Hour}, true},
+		{untold, nil, skedda.Grid{Slot: skedda.DefaultSlot}, true},
+		{nil, []string{"--min-length", "30m"}, skedda.Grid{Slot: skedda.DefaultSlot, MinLength: 30 * time.Minute}, true},
+		{untold, []string{"--slot", "7m"}, skedda.Grid{}, false},
+		{untold, []string{"--slot", "soon"}, skedda.Grid{}, false},
+	}
+
+	for i, testCase := range testCases {
+		grid, err := venueGrid(flagContext(t, gridFlags(), testCase.Args...), testCase.Venue)
+		if (err == nil) != testCase.Valid {
+			t.Errorf("Expected valid: %v but got %v: Test case %d", testCase.Valid, err, i)
+			continue
+		}
+
+		if err == nil && grid != testCase.Expected {
+			t.Errorf("Expected %+v but got %+v: Test case %d", testCase.Expected, grid, i)
+		}
+	}
+}
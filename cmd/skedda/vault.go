@@ -0,0 +1,297 @@
+package main
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"syscall"
+	"time"
+
+	"golang.org/x/crypto/scrypt"
+	"golang.org/x/crypto/ssh/terminal"
+)
+
+// errWrongPassphrase is returned when the credentials cannot be decrypted
+var errWrongPassphrase = errors.New("wrong passphrase")
+
+// passphraseEnv is the environment variable providing the passphrase to
+// non-interactive runs
+const passphraseEnv = "SKEDDA_PASSPHRASE"
+
+// vault is the password encrypted with a key derived from a passphrase. The
+// key is derived with scrypt and the password sealed with AES-256-GCM. The
+// TOTP secret of the second factor, if stored, is sealed with the same key.
+type vault struct {
+	Version    int
+	KDF        string
+	N          int
+	R          int
+	P          int
+	Salt       []byte
+	Nonce      []byte
+	Ciphertext []byte
+
+	TOTPNonce      []byte `json:",omitempty"`
+	TOTPCiphertext []byte `json:",omitempty"`
+}
+
+// sealPassword encrypts the password with the passphrase
+func sealPassword(passphrase, password string) (*vault, error) {
+	v := &vault{Version: 1, KDF: "scrypt", N: 1 << 15, R: 8, P: 1, Salt: make([]byte, 16)}
+	if _, err := rand.Read(v.Salt); err != nil {
+		return nil, err
+	}
+
+	key, err := v.key(passphrase)
+	if err != nil {
+		return nil, err
+	}
+
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+
+	v.Nonce = make([]byte, aead.NonceSize())
+	if _, err := rand.Read(v.Nonce); err != nil {
+		return nil, err
+	}
+
+	v.Ciphertext = aead.Seal(nil, v.Nonce, []byte(password), nil)
+	return v, nil
+}
+
+// key derives the key of the vault from the passphrase
+func (v *vault) key(passphrase string) ([]byte, error) {
+	if v.KDF != "scrypt" {
+		return nil, fmt.Errorf("unknown key derivation: %s", v.KDF)
+	}
+
+	return scrypt.Key([]byte(passphrase), v.Salt, v.N, v.R, v.P, 32)
+}
+
+// open decrypts the password with the key of the vault
+func (v *vault) open(key []byte) (string, error) {
+	aead, err := newAEAD(key)
+	if err != nil {
+		return "", err
+	}
+
+	password, err := aead.Open(nil, v.Nonce, v.Ciphertext, nil)
+	if err != nil {
+		return "", errWrongPassphrase
+	}
+
+	return string(password), nil
+}
+
+// sealTOTP encrypts the TOTP secret with the key of the vault
+func (v *vault) sealTOTP(key []byte, secret string) error {
+	aead, err := newAEAD(key)
+	if err != nil {
+		return err
+	}
+
+	v.TOTPNonce = make([]byte, aead.NonceSize())
+	if _, err := rand.Read(v.TOTPNonce); err != nil {
+		return err
+	}
+
+	v.TOTPCiphertext = aead.Seal(nil, v.TOTPNonce, []byte(secret), nil)
+	return nil
+}
+
+// openTOTP decrypts the TOTP secret with the key of the vault
+func (v *vault) openTOTP(key []byte) (string, error) {
+	aead, err := newAEAD(key)
+	if err != nil {
+		return "", err
+	}
+
+	secret, err := aead.Open(nil, v.TOTPNonce, v.TOTPCiphertext, nil)
+	if err != nil {
+		return "", errWrongPassphrase
+	}
+
+	return string(secret), nil
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return cipher.NewGCM(block)
+}
+
+func loadVault(configPath string) (*vault, error) {
+	raw, err := ioutil.ReadFile(path.Join(configPath, "credentials"))
+	if err != nil {
+		return nil, err
+	}
+
+	v := &vault{}
+	if err := json.Unmarshal(raw, v); err != nil {
+		return nil, err
+	}
+
+	return v, nil
+}
+
+func saveVault(configPath string, v *vault) error {
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(configPath, 0700); err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(path.Join(configPath, "credentials"), raw, 0600)
+}
+
+// maxUnlock is the longest the key of the vault is kept unlocked
+const maxUnlock = 7 * 24 * time.Hour
+
+// unlockSession keeps the key of the vault until it expires, so that the
+// passphrase is not asked on every run. Anyone able to read the file can
+// decrypt the password until then, like with the plaintext config before.
+type unlockSession struct {
+	Key     []byte
+	Expires time.Time
+}
+
+// unlock saves the key of the vault for a while. The key is written as it is,
+// only protected by the permissions of the file, and is ignored once expired
+// or if it claims to last longer than maxUnlock.
+func unlock(configPath string, key []byte, d time.Duration) error {
+	if d <= 0 || d > maxUnlock {
+		return fmt.Errorf("the credentials can be unlocked for up to %s", maxUnlock)
+	}
+
+	raw, err := json.Marshal(unlockSession{key, time.Now().Add(d)})
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(path.Join(configPath, "session"), raw, 0600)
+}
+
+// lock forgets the key of the vault
+func lock(configPath string) error {
+	err := os.Remove(path.Join(configPath, "session"))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
+
+func loadSessionKey(configPath string) ([]byte, bool) {
+	raw, err := ioutil.ReadFile(path.Join(configPath, "session"))
+	if err != nil {
+		return nil, false
+	}
+
+	session := unlockSession{}
+	if err := json.Unmarshal(raw, &session); err != nil || time.Now().After(session.Expires) || time.Until(session.Expires) > maxUnlock {
+		lock(configPath)
+		return nil, false
+	}
+
+	return session.Key, true
+}
+
+// readPassphrase returns the passphrase from the environment, or prompts for
+// it when running in a terminal
+func readPassphrase(prompt string) (string, error) {
+	if passphrase := os.Getenv(passphraseEnv); passphrase != "" {
+		return passphrase, nil
+	}
+
+	if !terminal.IsTerminal(int(syscall.Stdin)) {
+		return "", fmt.Errorf("the credentials are encrypted, set %s or use `skedda unlock`", passphraseEnv)
+	}
+
+	fmt.Fprintf(os.Stderr, "%s: ", prompt)
+	b, err := terminal.ReadPassword(int(syscall.Stdin))
+	fmt.Fprintln(os.Stderr)
+	if err != nil {
+		return "", err
+	}
+
+	return string(b), nil
+}
+
+// readNewPassphrase prompts for a new passphrase twice, unless it is provided
+// by the environment
+func readNewPassphrase() (string, error) {
+	if passphrase := os.Getenv(passphraseEnv); passphrase != "" {
+		return passphrase, nil
+	}
+
+	for {
+		passphrase, err := readPassphrase("Passphrase to encrypt the password")
+		if err != nil {
+			return "", err
+		}
+
+		if passphrase == "" {
+			fmt.Fprintln(os.Stderr, "The passphrase cannot be empty")
+			continue
+		}
+
+		confirmation, err := readPassphrase("Repeat the passphrase")
+		if err != nil {
+			return "", err
+		}
+
+		if passphrase == confirmation {
+			return passphrase, nil
+		}
+		fmt.Fprintln(os.Stderr, "The passphrases do not match")
+	}
+}
+
+// unlockVault decrypts the password with the key of the unlocked session, or
+// with the passphrase otherwise. It returns the key as well, to unlock a
+// session with.
+func unlockVault(configPath string, v *vault) (string, []byte, error) {
+	if key, ok := loadSessionKey(configPath); ok {
+		if password, err := v.open(key); err == nil {
+			return password, key, nil
+		}
+		lock(configPath)
+	}
+
+	for attempt := 0; attempt < 3; attempt++ {
+		passphrase, err := readPassphrase("Passphrase")
+		if err != nil {
+			return "", nil, err
+		}
+
+		key, err := v.key(passphrase)
+		if err != nil {
+			return "", nil, err
+		}
+
+		password, err := v.open(key)
+		if err == nil {
+			return password, key, nil
+		}
+
+		if os.Getenv(passphraseEnv) != "" {
+			return "", nil, fmt.Errorf("%w in %s", err, passphraseEnv)
+		}
+		fmt.Fprintln(os.Stderr, "Wrong passphrase, try again")
+	}
+
+	return "", nil, errWrongPassphrase
+}
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/ssh/terminal"
+)
+
+func TestVault(t *testing.T) {
+	v, err := sealPassword("correct horse", "s3cr3t")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := []struct {
+		Passphrase string
+		Password   string
+		Err        error
+	}{
+		{"correct horse", "s3cr3t", nil},
+		{"battery staple", "", errWrongPassphrase},
+	}
+
+	for i, testCase := range testCases {
+		key, err := v.key(testCase.Passphrase)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		password, err := v.open(key)
+		if password != testCase.Password || err != testCase.Err {
+			t.Errorf("Expected %q, %v but got %q, %v: Test case %d", testCase.Password, testCase.Err, password, err, i)
+		}
+	}
+
+	key, _ := v.key("correct horse")
+	if err := v.sealTOTP(key, "JBSWY3DPEHPK3PXP"); err != nil {
+		t.Fatal(err)
+	}
+
+	if secret, err := v.openTOTP(key); secret != "JBSWY3DPEHPK3PXP" || err != nil {
+		t.Errorf("Expected the TOTP secret but got %q, %v", secret, err)
+	}
+}
+
+func TestLoadConfigMigratesPlaintextPassword(t *testing.T) {
+	dir, err := ioutil.TempDir("", "skedda")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	if err := ioutil.WriteFile(path.Join(dir, "config"), []byte(`{"Username":"jane","Password":"s3cr3t"}`), 0777); err != nil {
+		t.Fatal(err)
+	}
+
+	os.Setenv(passphraseEnv, "correct horse")
+	defer os.Unsetenv(passphraseEnv)
+
+	for i := 0; i < 2; i++ {
+		config, err := loadConfig(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if config.Username != "jane" || config.Password != "s3cr3t" {
+			t.Errorf("Expected jane:s3cr3t but got %s:%s: Test case %d", config.Username, config.Password, i)
+		}
+	}
+
+	raw, err := ioutil.ReadFile(path.Join(dir, "config"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if strings.Contains(string(raw), "s3cr3t") {
+		t.Errorf("Expected the password to be removed from the config but got %s", raw)
+	}
+
+	for _, name := range []string{"config", "credentials"} {
+		info, err := os.Stat(path.Join(dir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if info.Mode().Perm() != 0600 {
+			t.Errorf("Expected %s to have mode 0600 but got %o", name, info.Mode().Perm())
+		}
+	}
+}
+
+func TestLoadConfigWithoutTerminal(t *testing.T) {
+	if terminal.IsTerminal(int(syscall.Stdin)) {
+		t.Skip("stdin is a terminal")
+	}
+
+	dir, err := ioutil.TempDir("", "skedda")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	if err := ioutil.WriteFile(path.Join(dir, "config"), []byte(`{"Username":"jane","Password":"s3cr3t"}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	// Without a passphrase to encrypt it with, the password is left as is
+	config, err := loadConfig(dir)
+	if err != nil || config.Password != "s3cr3t" {
+		t.Errorf("Expected s3cr3t but got %q, %v", config.Password, err)
+	}
+
+	if _, err := os.Stat(path.Join(dir, "credentials")); !os.IsNotExist(err) {
+		t.Errorf("Expected no credential store but got %v", err)
+	}
+}
+
+func TestUnlock(t *testing.T) {
+	dir, err := ioutil.TempDir("", "skedda")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	key := []byte("0123456789abcdef0123456789abcdef")
+
+	testCases := []struct {
+		Duration time.Duration
+		Expected bool
+	}{
+		{time.Hour, true},
+		{maxUnlock, true},
+		{maxUnlock + time.Hour, false},
+		{0, false},
+	}
+
+	for i, testCase := range testCases {
+		lock(dir)
+		unlock(dir, key, testCase.Duration)
+
+		if _, ok := loadSessionKey(dir); ok != testCase.Expected {
+			t.Errorf("Expected unlocked: %v but got %v: Test case %d", testCase.Expected, ok, i)
+		}
+	}
+
+	// Keys expired or claiming to last longer than allowed are ignored
+	for i, expires := range []time.Time{time.Now().Add(-time.Minute), time.Now().Add(2 * maxUnlock)} {
+		raw, _ := json.Marshal(unlockSession{key, expires})
+		if err := ioutil.WriteFile(path.Join(dir, "session"), raw, 0600); err != nil {
+			t.Fatal(err)
+		}
+
+		if _, ok := loadSessionKey(dir); ok {
+			t.Errorf("Expected the key to be ignored: Test case %d", i)
+		}
+
+		if _, err := os.Stat(path.Join(dir, "session")); !os.IsNotExist(err) {
+			t.Errorf("Expected the key to be removed but got %v: Test case %d", err, i)
+		}
+	}
+}
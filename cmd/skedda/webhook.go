@@ -0,0 +1,137 @@
+package main
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/alyyousuf7/skedda"
+)
+
+// WebhookPayload is the JSON body posted to the webhook for each event
+type WebhookPayload struct {
+	Event    string          `json:"event"`
+	Domain   string          `json:"domain"`
+	Time     time.Time       `json:"time"`
+	Booking  *skedda.Booking `json:"booking"`
+	Previous *skedda.Booking `json:"previous,omitempty"`
+}
+
+// WebhookNotifier delivers booking events to a webhook
+type WebhookNotifier struct {
+	URL string
+
+	// Secret signs the payloads when set
+	Secret string
+
+	// Retries is the number of extra attempts after a failed delivery
+	Retries int
+
+	// RetryDelay is the wait before the first retry, doubled on each retry
+	RetryDelay time.Duration
+
+	// DeadLetter is the file undeliverable payloads are appended to
+	DeadLetter string
+
+	Client *http.Client
+}
+
+// Notify delivers a booking event, falling back to the dead letter file when
+// every attempt fails
+func (n *WebhookNotifier) Notify(event skedda.BookingEvent) error {
+	payload := WebhookPayload{
+		Event:    event.Type.String(),
+		Domain:   event.Domain,
+		Time:     time.Now(),
+		Booking:  event.Booking,
+		Previous: event.Previous,
+	}
+
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	delay := n.RetryDelay
+	for attempt := 0; ; attempt++ {
+		err = n.deliver(body)
+		if err == nil || attempt >= n.Retries {
+			break
+		}
+
+		time.Sleep(delay)
+		delay *= 2
+	}
+
+	if err != nil && n.DeadLetter != "" {
+		if dlErr := n.deadLetter(body, err); dlErr != nil {
+			return fmt.Errorf("%s (dead letter: %s)", err, dlErr)
+		}
+	}
+
+	return err
+}
+
+func (n *WebhookNotifier) deliver(body []byte) error {
+	req, err := http.NewRequest("POST", n.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if n.Secret != "" {
+		req.Header.Set("X-Skedda-Signature", signPayload(n.Secret, body))
+	}
+
+	c := n.Client
+	if c == nil {
+		c = http.DefaultClient
+	}
+
+	res, err := c.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return fmt.Errorf("unknown status: %d", res.StatusCode)
+	}
+
+	return nil
+}
+
+func (n *WebhookNotifier) deadLetter(body []byte, deliveryErr error) error {
+	entry := struct {
+		FailedAt time.Time       `json:"failedAt"`
+		Error    string          `json:"error"`
+		Payload  json.RawMessage `json:"payload"`
+	}{time.Now(), deliveryErr.Error(), body}
+
+	buf, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(n.DeadLetter, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	_, err = f.Write(append(buf, '\n'))
+	return err
+}
+
+// signPayload returns the HMAC-SHA256 signature of body, as sent in the
+// X-Skedda-Signature header
+func signPayload(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
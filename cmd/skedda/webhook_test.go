@@ -0,0 +1,81 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"strings"
+	"testing"
+
+	"github.com/alyyousuf7/skedda"
+)
+
+func TestWebhookNotifier(t *testing.T) {
+	secret := "s3cr3t"
+	attempts := 0
+	var received WebhookPayload
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		if attempts == 1 {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		body, _ := ioutil.ReadAll(r.Body)
+		if r.Header.Get("X-Skedda-Signature") != signPayload(secret, body) {
+			t.Errorf("Invalid signature: %s", r.Header.Get("X-Skedda-Signature"))
+		}
+
+		if err := json.Unmarshal(body, &received); err != nil {
+			t.Errorf("Invalid payload: %s", err)
+		}
+	}))
+	defer server.Close()
+
+	n := &WebhookNotifier{URL: server.URL, Secret: secret, Retries: 2}
+	event := skedda.BookingEvent{Type: skedda.BookingCreated, Domain: "acme", Booking: &skedda.Booking{ID: 42, Title: "Standup"}}
+	if err := n.Notify(event); err != nil {
+		t.Fatalf("Expected delivery to succeed but got: %s", err)
+	}
+
+	if attempts != 2 {
+		t.Errorf("Expected 2 attempts but got %d", attempts)
+	}
+
+	if received.Event != "created" || received.Domain != "acme" || received.Booking.ID != 42 {
+		t.Errorf("Unexpected payload: %+v", received)
+	}
+}
+
+func TestWebhookNotifierDeadLetter(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer server.Close()
+
+	dir, err := ioutil.TempDir("", "skedda")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	deadLetter := path.Join(dir, "dead-letter.jsonl")
+	n := &WebhookNotifier{URL: server.URL, Retries: 1, DeadLetter: deadLetter}
+	event := skedda.BookingEvent{Type: skedda.BookingDeleted, Domain: "acme", Booking: &skedda.Booking{ID: 42}}
+	if err := n.Notify(event); err == nil {
+		t.Fatalf("Expected delivery to fail")
+	}
+
+	buf, err := ioutil.ReadFile(deadLetter)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(string(buf)), "\n")
+	if len(lines) != 1 || !strings.Contains(lines[0], `"event":"deleted"`) {
+		t.Errorf("Unexpected dead letter: %s", buf)
+	}
+}
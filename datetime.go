@@ -1,6 +1,7 @@
 package skedda
 
 import (
+	"encoding/json"
 	"strings"
 	"time"
 )
@@ -10,6 +11,11 @@ type DateTime struct {
 	time.Time
 }
 
+// MarshalJSON encodes datetime
+func (dt DateTime) MarshalJSON() ([]byte, error) {
+	return json.Marshal(dt.Format("2006-01-02T15:04:05"))
+}
+
 // UnmarshalJSON decodes datetime
 func (dt *DateTime) UnmarshalJSON(input []byte) error {
 	strInput := string(input)
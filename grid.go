@@ -0,0 +1,174 @@
+package skedda
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// DefaultSlot is the booking granularity of venues which do not tell theirs
+const DefaultSlot = 15 * time.Minute
+
+var (
+	// ErrOffGrid is returned when a booking does not start or end on a slot
+	// of the venue
+	ErrOffGrid = errors.New("time is not on the booking grid")
+
+	// ErrBookingTooShort is returned when a booking is shorter than the venue
+	// allows
+	ErrBookingTooShort = errors.New("booking is too short")
+
+	// ErrBookingTooLong is returned when a booking is longer than the venue
+	// allows
+	ErrBookingTooLong = errors.New("booking is too long")
+)
+
+// Grid of the bookings of a venue. Bookings start and end on a multiple of
+// Slot from midnight, and last between MinLength and MaxLength. A zero Slot is
+// DefaultSlot, and a zero MinLength or MaxLength is not enforced.
+type Grid struct {
+	Slot      time.Duration
+	MinLength time.Duration
+	MaxLength time.Duration
+}
+
+// SnapMode tells how times are rounded onto a Grid
+type SnapMode int
+
+const (
+	// SnapNone leaves the times as they are
+	SnapNone SnapMode = iota
+	// SnapNearest rounds the times to the nearest slot
+	SnapNearest
+	// SnapDown rounds the times down to the slot they are in
+	SnapDown
+	// SnapUp rounds the times up to the next slot
+	SnapUp
+)
+
+// ParseSnapMode parses nearest, down, up or none
+func ParseSnapMode(s string) (SnapMode, error) {
+	switch s {
+	case "", "none":
+		return SnapNone, nil
+	case "nearest":
+		return SnapNearest, nil
+	case "down":
+		return SnapDown, nil
+	case "up":
+		return SnapUp, nil
+	}
+
+	return SnapNone, fmt.Errorf("invalid snap mode: %s (possible values: nearest, down, up)", s)
+}
+
+func (m SnapMode) String() string {
+	switch m {
+	case SnapNearest:
+		return "nearest"
+	case SnapDown:
+		return "down"
+	case SnapUp:
+		return "up"
+	}
+
+	return "none"
+}
+
+func (g Grid) slot() time.Duration {
+	if g.Slot <= 0 {
+		return DefaultSlot
+	}
+
+	return g.Slot
+}
+
+// Round rounds t onto the grid
+func (g Grid) Round(t time.Time, mode SnapMode) time.Time {
+	day := t.Truncate(24 * time.Hour)
+	offset := t.Sub(day)
+	slot := g.slot()
+
+	down := offset - offset%slot
+	switch mode {
+	case SnapNearest:
+		if offset-down >= slot/2 {
+			down += slot
+		}
+	case SnapDown:
+	case SnapUp:
+		if down != offset {
+			down += slot
+		}
+	default:
+		return t
+	}
+
+	return day.Add(down)
+}
+
+// Snap rounds a time period onto the grid, keeping it at least a slot long
+func (g Grid) Snap(from, to time.Time, mode SnapMode) (time.Time, time.Time) {
+	if mode == SnapNone {
+		return from, to
+	}
+
+	from, to = g.Round(from, mode), g.Round(to, mode)
+	if !to.After(from) {
+		to = from.Add(g.slot())
+	}
+
+	return from, to
+}
+
+// Validate tells whether a time period can be booked on the grid
+func (g Grid) Validate(from, to time.Time) error {
+	if !g.Round(from, SnapDown).Equal(from) || !g.Round(to, SnapDown).Equal(to) {
+		return fmt.Errorf("%w: %s - %s is not on %s slots", ErrOffGrid, from.Format("3:04pm"), to.Format("3:04pm"), minutes(g.slot()))
+	}
+
+	length := to.Sub(from)
+	if g.MinLength > 0 && length < g.MinLength {
+		return fmt.Errorf("%w: %s is shorter than %s", ErrBookingTooShort, minutes(length), minutes(g.MinLength))
+	}
+
+	if g.MaxLength > 0 && length > g.MaxLength {
+		return fmt.Errorf("%w: %s is longer than %s", ErrBookingTooLong, minutes(length), minutes(g.MaxLength))
+	}
+
+	return nil
+}
+
+// minutes formats a duration as a number of minutes, e.g. "90 minutes"
+func minutes(d time.Duration) string {
+	if d == time.Minute {
+		return "1 minute"
+	}
+
+	return fmt.Sprintf("%d minutes", int(d/time.Minute))
+}
+
+// SetGrid sets the booking grid of a venue, which Book validates the bookings
+// against. The grids of venues fetched with Venue are set already when Skedda
+// tells them. Bookings of venues without a grid are sent to Skedda as they
+// are.
+func (s *Skedda) SetGrid(venueID int, g Grid) {
+	s.gridsMu.Lock()
+	defer s.gridsMu.Unlock()
+
+	s.grids[venueID] = g
+}
+
+func (s *Skedda) grid(venueID int) (Grid, bool) {
+	s.gridsMu.Lock()
+	defer s.gridsMu.Unlock()
+
+	g, ok := s.grids[venueID]
+	return g, ok
+}
+
+// SetSnap makes Book round the bookings onto the grid of their venue instead
+// of rejecting those off the grid
+func (s *Skedda) SetSnap(mode SnapMode) {
+	s.snap = mode
+}
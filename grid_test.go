@@ -0,0 +1,167 @@
+package skedda_test
+
+import (
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alyyousuf7/skedda"
+)
+
+func TestGridSnap(t *testing.T) {
+	at := func(hour, minute int) time.Time {
+		return time.Date(2020, time.March, 4, hour, minute, 0, 0, time.UTC)
+	}
+
+	testCases := []struct {
+		Grid         skedda.Grid
+		Mode         skedda.SnapMode
+		From, Till   time.Time
+		ExpectedFrom time.Time
+		ExpectedTill time.Time
+	}{
+		{skedda.Grid{}, skedda.SnapNone, at(9, 10), at(9, 50), at(9, 10), at(9, 50)},
+		{skedda.Grid{}, skedda.SnapNearest, at(9, 7), at(9, 53), at(9, 0), at(10, 0)},
+		{skedda.Grid{}, skedda.SnapNearest, at(9, 8), at(9, 52), at(9, 15), at(9, 45)},
+		{skedda.Grid{}, skedda.SnapDown, at(9, 10), at(9, 50), at(9, 0), at(9, 45)},
+		{skedda.Grid{}, skedda.SnapUp, at(9, 10), at(9, 50), at(9, 15), at(10, 0)},
+		{skedda.Grid{}, skedda.SnapDown, at(9, 5), at(9, 10), at(9, 0), at(9, 15)},
+		{skedda.Grid{Slot: time.Hour}, skedda.SnapNearest, at(9, 20), at(11, 40), at(9, 0), at(12, 0)},
+		{skedda.Grid{Slot: 30 * time.Minute}, skedda.SnapUp, at(23, 40), at(23, 50), at(0, 0).Add(24 * time.Hour), at(0, 30).Add(24 * time.Hour)},
+	}
+
+	for i, testCase := range testCases {
+		from, till := testCase.Grid.Snap(testCase.From, testCase.Till, testCase.Mode)
+		if !from.Equal(testCase.ExpectedFrom) || !till.Equal(testCase.ExpectedTill) {
+			t.Errorf("Expected %v - %v but got %v - %v: Test case %d", testCase.ExpectedFrom, testCase.ExpectedTill, from, till, i)
+		}
+	}
+}
+
+func TestGridValidate(t *testing.T) {
+	at := func(hour, minute int) time.Time {
+		return time.Date(2020, time.March, 4, hour, minute, 0, 0, time.UTC)
+	}
+
+	grid := skedda.Grid{Slot: 30 * time.Minute, MinLength: time.Hour, MaxLength: 4 * time.Hour}
+	testCases := []struct {
+		From, Till time.Time
+		Expected   error
+	}{
+		{at(9, 0), at(10, 30), nil},
+		{at(9, 15), at(10, 30), skedda.ErrOffGrid},
+		{at(9, 0), at(9, 30), skedda.ErrBookingTooShort},
+		{at(9, 0), at(13, 30), skedda.ErrBookingTooLong},
+		{at(9, 0), at(13, 0), nil},
+	}
+
+	for i, testCase := range testCases {
+		if err := grid.Validate(testCase.From, testCase.Till); !errors.Is(err, testCase.Expected) {
+			t.Errorf("Expected %v but got %v: Test case %d", testCase.Expected, err, i)
+		}
+	}
+}
+
+func TestBookValidatesGrid(t *testing.T) {
+	var booked string
+	s, _ := skedda.NewWithSession("jane@acme.com", skedda.ParseCookieHeader("session=abc", "skedda.com"))
+	s.SetTransport(roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		body, _ := ioutil.ReadAll(req.Body)
+		booked = string(body)
+
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Body:       ioutil.NopCloser(strings.NewReader("{}")),
+			Request:    req,
+		}, nil
+	}))
+
+	from := time.Date(2020, time.March, 4, 9, 10, 0, 0, time.UTC)
+
+	// Bookings of venues without a grid are sent as they are
+	if err := s.BookWithToken("acme", "token", 1, []int{2}, "Standup", from, from.Add(30*time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.Contains(booked, `"start":"2020-03-04T09:10:00"`) {
+		t.Errorf("Expected the booking to be sent as it is but got %s", booked)
+	}
+
+	booked = ""
+	s.SetGrid(1, skedda.Grid{})
+	if err := s.BookWithToken("acme", "token", 1, []int{2}, "Standup", from, from.Add(30*time.Minute)); !errors.Is(err, skedda.ErrOffGrid) {
+		t.Errorf("Expected %v but got %v", skedda.ErrOffGrid, err)
+	}
+
+	if booked != "" {
+		t.Errorf("Expected no booking but got %s", booked)
+	}
+
+	s.SetSnap(skedda.SnapDown)
+	if err := s.BookWithToken("acme", "token", 1, []int{2}, "Standup", from, from.Add(30*time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.Contains(booked, `"start":"2020-03-04T09:00:00"`) || !strings.Contains(booked, `"end":"2020-03-04T09:30:00"`) {
+		t.Errorf("Expected the booking to be snapped but got %s", booked)
+	}
+}
+
+func TestVenueGrid(t *testing.T) {
+	testCases := []struct {
+		Venue    string
+		Expected skedda.Grid
+		Set      bool
+	}{
+		{
+			`{"id":1,"name":"London Office","subdomain":"acme","timeZone":"Europe/London","slotLength":30,"minBookingLength":60,"maxBookingLength":240}`,
+			skedda.Grid{Slot: 30 * time.Minute, MinLength: time.Hour, MaxLength: 4 * time.Hour},
+			true,
+		},
+		{
+			`{"id":1,"name":"London Office","subdomain":"acme","timeZone":"Europe/London"}`,
+			skedda.Grid{},
+			false,
+		},
+	}
+
+	for i, testCase := range testCases {
+		var booked string
+		s, _ := skedda.NewWithSession("jane@acme.com", skedda.ParseCookieHeader("session=abc", "skedda.com"))
+		s.SetTransport(roundTripFunc(func(req *http.Request) (*http.Response, error) {
+			body := `<input name="__RequestVerificationToken" type="hidden" value="token" />`
+			switch req.URL.Path {
+			case "/webs":
+				body = `{"venue":[` + testCase.Venue + `],"spaces":[{"id":2,"name":"Thames","venue":1}]}`
+			case "/bookings":
+				raw, _ := ioutil.ReadAll(req.Body)
+				booked, body = string(raw), "{}"
+			}
+
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Body:       ioutil.NopCloser(strings.NewReader(body)),
+				Request:    req,
+			}, nil
+		}))
+
+		venue, _, err := s.Venue("acme")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if venue.Grid() != testCase.Expected {
+			t.Errorf("Expected %+v but got %+v: Test case %d", testCase.Expected, venue.Grid(), i)
+		}
+
+		// The grid told by Skedda is validated by Book
+		from := time.Date(2020, time.March, 4, 9, 0, 0, 0, time.UTC)
+		err = s.Book("acme", 1, []int{2}, "Standup", from, from.Add(30*time.Minute))
+		if set := errors.Is(err, skedda.ErrBookingTooShort); set != testCase.Set || (!set && booked == "") {
+			t.Errorf("Expected the grid set: %v but got %v, %q: Test case %d", testCase.Set, err, booked, i)
+		}
+	}
+}
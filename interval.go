@@ -0,0 +1,63 @@
+package skedda
+
+import (
+	"sort"
+	"time"
+)
+
+// Interval is a period of time between Start and End
+type Interval struct {
+	Start time.Time
+	End   time.Time
+}
+
+// Duration returns the length of the interval
+func (i Interval) Duration() time.Duration {
+	return i.End.Sub(i.Start)
+}
+
+// Overlaps tells whether the interval shares any time with the period
+func (i Interval) Overlaps(from, to time.Time) bool {
+	return i.Start.Before(to) && i.End.After(from)
+}
+
+// Clip returns the part of the interval within the period, if any
+func (i Interval) Clip(from, to time.Time) (Interval, bool) {
+	if !i.Overlaps(from, to) {
+		return Interval{}, false
+	}
+
+	if i.Start.Before(from) {
+		i.Start = from
+	}
+
+	if i.End.After(to) {
+		i.End = to
+	}
+
+	return i, true
+}
+
+// MergeIntervals returns the union of the intervals as a sorted list of
+// non-overlapping intervals
+func MergeIntervals(intervals []Interval) []Interval {
+	sorted := append([]Interval{}, intervals...)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].Start.Before(sorted[j].Start)
+	})
+
+	merged := []Interval{}
+	for _, interval := range sorted {
+		last := len(merged) - 1
+		if last >= 0 && !interval.Start.After(merged[last].End) {
+			if interval.End.After(merged[last].End) {
+				merged[last].End = interval.End
+			}
+			continue
+		}
+
+		merged = append(merged, interval)
+	}
+
+	return merged
+}
@@ -0,0 +1,63 @@
+package skedda_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/alyyousuf7/skedda"
+)
+
+func TestMergeIntervals(t *testing.T) {
+	at := func(hour, minute int) time.Time {
+		return time.Date(1991, time.March, 7, hour, minute, 0, 0, time.UTC)
+	}
+
+	intervals := []skedda.Interval{
+		{Start: at(13, 0), End: at(14, 0)},
+		{Start: at(9, 0), End: at(10, 0)},
+		{Start: at(9, 30), End: at(11, 0)},
+		{Start: at(11, 0), End: at(11, 30)},
+		{Start: at(9, 45), End: at(10, 15)},
+	}
+
+	expected := []skedda.Interval{
+		{Start: at(9, 0), End: at(11, 30)},
+		{Start: at(13, 0), End: at(14, 0)},
+	}
+
+	result := skedda.MergeIntervals(intervals)
+	if len(result) != len(expected) {
+		t.Fatalf("Expected %d intervals but got %d: %v", len(expected), len(result), result)
+	}
+
+	for i, e := range expected {
+		if !result[i].Start.Equal(e.Start) || !result[i].End.Equal(e.End) {
+			t.Errorf("Expected %v but got %v: Interval %d", e, result[i], i+1)
+		}
+	}
+}
+
+func TestIntervalClip(t *testing.T) {
+	at := func(hour int) time.Time {
+		return time.Date(1991, time.March, 7, hour, 0, 0, 0, time.UTC)
+	}
+
+	cases := []struct {
+		interval skedda.Interval
+		expected skedda.Interval
+		ok       bool
+	}{
+		{skedda.Interval{Start: at(6), End: at(7)}, skedda.Interval{}, false},
+		{skedda.Interval{Start: at(7), End: at(9)}, skedda.Interval{Start: at(8), End: at(9)}, true},
+		{skedda.Interval{Start: at(9), End: at(10)}, skedda.Interval{Start: at(9), End: at(10)}, true},
+		{skedda.Interval{Start: at(11), End: at(13)}, skedda.Interval{Start: at(11), End: at(12)}, true},
+		{skedda.Interval{Start: at(12), End: at(13)}, skedda.Interval{}, false},
+	}
+
+	for i, c := range cases {
+		result, ok := c.interval.Clip(at(8), at(12))
+		if ok != c.ok || !result.Start.Equal(c.expected.Start) || !result.End.Equal(c.expected.End) {
+			t.Errorf("Expected %v (%v) but got %v (%v): Test case %d", c.expected, c.ok, result, ok, i+1)
+		}
+	}
+}
@@ -0,0 +1,113 @@
+package skedda_test
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alyyousuf7/skedda"
+)
+
+func TestTOTP(t *testing.T) {
+	// Test vectors of RFC 6238 for SHA-1, secret "12345678901234567890"
+	secret := "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+	cases := []struct {
+		unix     int64
+		expected string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+		{20000000000, "353130"},
+	}
+
+	for i, c := range cases {
+		code, err := skedda.TOTP(secret, time.Unix(c.unix, 0))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if code != c.expected {
+			t.Errorf("Expected %v but got %v: Test case %d", c.expected, code, i)
+		}
+	}
+
+	if code, _ := skedda.TOTP("gezd gnbv gy3t qojq gezd gnbv gy3t qojq", time.Unix(59, 0)); code != "287082" {
+		t.Errorf("Expected the secret to be normalized but got %v", code)
+	}
+
+	if _, err := skedda.TOTP("not base32!", time.Unix(59, 0)); err == nil {
+		t.Errorf("Expected invalid secret to fail")
+	}
+}
+
+func mfaTransport(t *testing.T, attempts *int) roundTripFunc {
+	return func(req *http.Request) (*http.Response, error) {
+		*attempts++
+
+		body := struct {
+			Login map[string]interface{}
+		}{}
+		if err := json.NewDecoder(req.Body).Decode(&body); err != nil {
+			t.Fatal(err)
+		}
+
+		res := &http.Response{
+			StatusCode: http.StatusOK,
+			Body:       ioutil.NopCloser(strings.NewReader("{}")),
+			Request:    req,
+		}
+		if body.Login["twoFactorCode"] != "123456" {
+			res.StatusCode = http.StatusUnauthorized
+			res.Body = ioutil.NopCloser(strings.NewReader(`{"errors":[{"code":"TwoFactorRequired","detail":"Enter the verification code"}]}`))
+		}
+
+		return res, nil
+	}
+}
+
+func TestAuthMFA(t *testing.T) {
+	attempts := 0
+	s, _ := skedda.NewWithCreds("jane@acme.com", "s3cr3t")
+	s.SetTransport(mfaTransport(t, &attempts))
+
+	err := s.Auth()
+	if !errors.Is(err, skedda.ErrMFARequired) {
+		t.Fatalf("Expected %v but got %v", skedda.ErrMFARequired, err)
+	}
+
+	var mfaErr *skedda.MFARequiredError
+	if !errors.As(err, &mfaErr) || mfaErr.Challenge.Code != "TwoFactorRequired" {
+		t.Errorf("Expected the challenge but got %v", err)
+	}
+
+	cases := []struct {
+		code     string
+		expected error
+	}{
+		{"000000", skedda.ErrAuthFailed},
+		{"123456", nil},
+	}
+
+	for i, c := range cases {
+		attempts = 0
+		code := c.code
+		s.SetMFAHandler(func(challenge skedda.MFAChallenge) (string, error) {
+			return code, nil
+		})
+
+		if err := s.Auth(); !errors.Is(err, c.expected) {
+			t.Errorf("Expected %v but got %v: Test case %d", c.expected, err, i)
+		}
+
+		if attempts != 2 {
+			t.Errorf("Expected 2 attempts but got %d: Test case %d", attempts, i)
+		}
+	}
+}
@@ -1,6 +1,7 @@
 package skedda
 
 import (
+	"encoding/json"
 	"regexp"
 	"strings"
 
@@ -13,6 +14,15 @@ type RuleSet struct {
 	ForceValid bool
 }
 
+// MarshalJSON encodes the iCal Recurrence Rule Set
+func (r RuleSet) MarshalJSON() ([]byte, error) {
+	if len(r.Recurrence()) == 0 {
+		return []byte("null"), nil
+	}
+
+	return json.Marshal(r.String())
+}
+
 // UnmarshalJSON decodes the iCal Recurrence Rule Set
 func (r *RuleSet) UnmarshalJSON(input []byte) error {
 	if string(input) == "null" {
@@ -0,0 +1,149 @@
+package skedda
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/publicsuffix"
+)
+
+// NewWithSession initializes Skedda instance with the cookies of a session
+// signed in elsewhere, e.g. in a browser for accounts signing in through SSO.
+// The username is still needed to find the primary domain.
+func NewWithSession(username string, cookies []*http.Cookie) (*Skedda, error) {
+	c, err := cookiejar.New(&cookiejar.Options{
+		PublicSuffixList: publicsuffix.List,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Skedda{
+		c,
+		nil,
+		username,
+		"",
+		false,
+		nil,
+		map[int]Grid{},
+		sync.Mutex{},
+		SnapNone,
+	}
+	s.SetSession(cookies)
+
+	return s, nil
+}
+
+// SetSession loads the cookies of a session signed in elsewhere into the
+// cookiejar, and considers the instance authenticated. Cookies of domains
+// other than skedda.com are ignored.
+func (s *Skedda) SetSession(cookies []*http.Cookie) {
+	for _, cookie := range cookies {
+		host := strings.TrimPrefix(cookie.Domain, ".")
+		if host != "skedda.com" && !strings.HasSuffix(host, ".skedda.com") {
+			continue
+		}
+
+		// Cookies limited to their host are set without a domain attribute
+		c := *cookie
+		if strings.HasPrefix(cookie.Domain, ".") {
+			if host == "skedda.com" {
+				host = "www.skedda.com"
+			}
+		} else {
+			c.Domain = ""
+		}
+
+		path := c.Path
+		if path == "" {
+			path = "/"
+		}
+
+		s.cookiejar.SetCookies(&url.URL{Scheme: "https", Host: host, Path: path}, []*http.Cookie{&c})
+	}
+
+	s.isAuthenticated = true
+}
+
+// ParseCookiesTxt parses cookies exported in the Netscape cookies.txt format
+func ParseCookiesTxt(r io.Reader) ([]*http.Cookie, error) {
+	cookies := []*http.Cookie{}
+
+	scanner := bufio.NewScanner(r)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+
+		httpOnly := false
+		if strings.HasPrefix(line, "#HttpOnly_") {
+			line = strings.TrimPrefix(line, "#HttpOnly_")
+			httpOnly = true
+		}
+
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		fields := strings.Split(line, "\t")
+		if len(fields) != 7 {
+			return nil, fmt.Errorf("line %d: expected 7 fields but got %d", n, len(fields))
+		}
+
+		cookie := &http.Cookie{
+			Domain:   fields[0],
+			Path:     fields[2],
+			Secure:   strings.EqualFold(fields[3], "TRUE"),
+			HttpOnly: httpOnly,
+			Name:     fields[5],
+			Value:    fields[6],
+		}
+
+		// Cookies limited to the host have no domain attribute
+		if !strings.EqualFold(fields[1], "TRUE") {
+			cookie.Domain = strings.TrimPrefix(cookie.Domain, ".")
+		} else if !strings.HasPrefix(cookie.Domain, ".") {
+			cookie.Domain = "." + cookie.Domain
+		}
+
+		expires, err := strconv.ParseInt(fields[4], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: invalid expiry: %w", n, err)
+		}
+		if expires > 0 {
+			cookie.Expires = time.Unix(expires, 0)
+		}
+
+		cookies = append(cookies, cookie)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return cookies, nil
+}
+
+// ParseCookieHeader parses a raw Cookie header, e.g. copied from the
+// developer tools of a browser, into cookies for the domain and its subdomains
+func ParseCookieHeader(header, domain string) []*http.Cookie {
+	header = strings.TrimSpace(header)
+	if strings.HasPrefix(strings.ToLower(header), "cookie:") {
+		header = header[len("cookie:"):]
+	}
+
+	req := http.Request{Header: http.Header{"Cookie": {header}}}
+	cookies := req.Cookies()
+	for _, cookie := range cookies {
+		cookie.Domain = "." + strings.TrimPrefix(domain, ".")
+		cookie.Path = "/"
+	}
+
+	return cookies
+}
@@ -0,0 +1,246 @@
+package skedda_test
+
+import (
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alyyousuf7/skedda"
+)
+
+func TestParseCookiesTxt(t *testing.T) {
+	txt := `# Netscape HTTP Cookie File
+# This is a generated file! Do not edit.
+
+.skedda.com	TRUE	/	TRUE	1893456000	X-Skedda-RequestVerificationCookie	token
+#HttpOnly_acme.skedda.com	FALSE	/	TRUE	0	.AspNetCore.Cookies	session
+`
+
+	cookies, err := skedda.ParseCookiesTxt(strings.NewReader(txt))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []http.Cookie{
+		{Name: "X-Skedda-RequestVerificationCookie", Value: "token", Domain: ".skedda.com", Path: "/", Secure: true, Expires: time.Unix(1893456000, 0)},
+		{Name: ".AspNetCore.Cookies", Value: "session", Domain: "acme.skedda.com", Path: "/", Secure: true, HttpOnly: true},
+	}
+
+	if len(cookies) != len(expected) {
+		t.Fatalf("Expected %d cookies but got %d", len(expected), len(cookies))
+	}
+
+	for i, cookie := range expected {
+		got := cookies[i]
+		if got.Name != cookie.Name || got.Value != cookie.Value || got.Domain != cookie.Domain || got.Path != cookie.Path ||
+			got.Secure != cookie.Secure || got.HttpOnly != cookie.HttpOnly || !got.Expires.Equal(cookie.Expires) {
+			t.Errorf("Expected %v but got %v: Test case %d", cookie, *got, i)
+		}
+	}
+
+	if _, err := skedda.ParseCookiesTxt(strings.NewReader("skedda.com\tTRUE\t/\n")); err == nil {
+		t.Errorf("Expected malformed line to fail")
+	}
+}
+
+func TestParseCookieHeader(t *testing.T) {
+	cases := []struct {
+		header   string
+		expected []string
+	}{
+		{"a=1; b=2", []string{"a=1", "b=2"}},
+		{"Cookie: a=1;b=2", []string{"a=1", "b=2"}},
+		{"", []string{}},
+	}
+
+	for i, c := range cases {
+		cookies := skedda.ParseCookieHeader(c.header, "skedda.com")
+		got := []string{}
+		for _, cookie := range cookies {
+			if cookie.Domain != ".skedda.com" {
+				t.Errorf("Expected domain .skedda.com but got %s: Test case %d", cookie.Domain, i)
+			}
+			got = append(got, cookie.Name+"="+cookie.Value)
+		}
+
+		if strings.Join(got, ";") != strings.Join(c.expected, ";") {
+			t.Errorf("Expected %v but got %v: Test case %d", c.expected, got, i)
+		}
+	}
+}
+
+type roundTripFunc func(req *http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+func TestSessionPrimaryDomain(t *testing.T) {
+	cookies := skedda.ParseCookieHeader("session=abc", "skedda.com")
+	s, err := skedda.NewWithSession("jane@acme.com", cookies)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s.SetTransport(roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		if req.URL.Path != "/account/login" {
+			t.Errorf("Unexpected request to %s", req.URL)
+		}
+
+		if cookie, err := req.Cookie("session"); err != nil || cookie.Value != "abc" {
+			t.Errorf("Expected the session cookie to be sent")
+		}
+
+		return &http.Response{
+			StatusCode: http.StatusFound,
+			Header:     http.Header{"Location": {"https://acme.skedda.com/booking"}},
+			Body:       ioutil.NopCloser(strings.NewReader("")),
+			Request:    req,
+		}, nil
+	}))
+
+	domain, err := s.PrimaryDomain()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if domain != "acme" {
+		t.Errorf("Expected acme but got %s", domain)
+	}
+}
+
+func TestLogout(t *testing.T) {
+	testCases := []struct {
+		Status int
+		Failed bool
+	}{
+		{http.StatusFound, false},
+		{http.StatusInternalServerError, true},
+	}
+
+	for i, testCase := range testCases {
+		s, _ := skedda.NewWithSession("jane@acme.com", skedda.ParseCookieHeader("session=abc", "skedda.com"))
+
+		loggedOut := false
+		s.SetTransport(roundTripFunc(func(req *http.Request) (*http.Response, error) {
+			if _, err := req.Cookie("session"); err != nil {
+				t.Errorf("Expected the session cookie to be sent: Test case %d", i)
+			}
+
+			res := &http.Response{
+				StatusCode: http.StatusOK,
+				Header:     http.Header{},
+				Body:       ioutil.NopCloser(strings.NewReader("")),
+				Request:    req,
+			}
+
+			switch {
+			case req.Method == "POST" && req.URL.Path == "/account/login":
+				res.StatusCode = http.StatusFound
+				res.Header.Set("Location", "https://acme.skedda.com/booking")
+			case req.Method == "GET" && req.URL.Path == "/booking":
+				res.Body = ioutil.NopCloser(strings.NewReader(`<input name="__RequestVerificationToken" type="hidden" value="token" />`))
+			case req.Method == "POST" && req.URL.Path == "/account/logout":
+				if req.Header.Get("X-Skedda-RequestVerificationToken") != "token" {
+					t.Errorf("Expected the verification token to be sent: Test case %d", i)
+				}
+				loggedOut = true
+				res.StatusCode = testCase.Status
+				res.Header.Set("Location", "https://www.skedda.com/")
+			default:
+				t.Errorf("Unexpected request %s %s: Test case %d", req.Method, req.URL, i)
+			}
+
+			return res, nil
+		}))
+
+		if err := s.Logout(); (err != nil) != testCase.Failed {
+			t.Errorf("Expected failed: %v but got %v: Test case %d", testCase.Failed, err, i)
+		}
+
+		if !loggedOut {
+			t.Errorf("Expected to sign out: Test case %d", i)
+		}
+
+		// The session is gone either way, so signing in again needs the password
+		if _, err := s.PrimaryDomain(); err != skedda.ErrCredsMissing {
+			t.Errorf("Expected %v but got %v: Test case %d", skedda.ErrCredsMissing, err, i)
+		}
+	}
+}
+
+func TestClone(t *testing.T) {
+	s, _ := skedda.NewWithSession("jane@acme.com", skedda.ParseCookieHeader("session=abc", "skedda.com"))
+	s.SetTransport(roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		if cookie, err := req.Cookie("session"); err != nil || cookie.Value != "abc" {
+			t.Errorf("Expected the session cookie to be sent")
+		}
+
+		return &http.Response{
+			StatusCode: http.StatusFound,
+			Header:     http.Header{"Location": {"https://acme.skedda.com/booking"}},
+			Body:       ioutil.NopCloser(strings.NewReader("")),
+			Request:    req,
+		}, nil
+	}))
+
+	clone, err := s.Clone()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if domain, err := clone.PrimaryDomain(); err != nil || domain != "acme" {
+		t.Errorf("Expected acme but got %s, %v", domain, err)
+	}
+
+	// Clones of a password never prompt for a code
+	attempts, prompts := 0, 0
+	s, _ = skedda.NewWithCreds("jane@acme.com", "s3cr3t")
+	s.SetTransport(mfaTransport(t, &attempts))
+	s.SetMFAHandler(func(challenge skedda.MFAChallenge) (string, error) {
+		prompts++
+		return "123456", nil
+	})
+
+	clone, err = s.Clone()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var mfaErr *skedda.MFARequiredError
+	if err := clone.Auth(); !errors.As(err, &mfaErr) {
+		t.Errorf("Expected a second factor to be required but got %v", err)
+	}
+
+	if prompts != 0 {
+		t.Errorf("Expected no prompt but got %d", prompts)
+	}
+}
+
+func TestSessionExpired(t *testing.T) {
+	s, _ := skedda.NewWithSession("jane@acme.com", skedda.ParseCookieHeader("session=abc", "skedda.com"))
+	s.SetTransport(roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		return &http.Response{
+			StatusCode: http.StatusFound,
+			Header:     http.Header{"Location": {"https://www.skedda.com/account/login?ReturnUrl=%2Fbooking"}},
+			Body:       ioutil.NopCloser(strings.NewReader("")),
+			Request:    req,
+		}, nil
+	}))
+
+	if _, err := s.Bookings("acme", time.Now(), time.Now().Add(time.Hour)); !errors.Is(err, skedda.ErrSessionExpired) {
+		t.Errorf("Expected %v but got %v", skedda.ErrSessionExpired, err)
+	}
+
+	if _, err := s.PrimaryDomain(); !errors.Is(err, skedda.ErrSessionExpired) {
+		t.Errorf("Expected %v but got %v", skedda.ErrSessionExpired, err)
+	}
+
+	// Sessions signed in elsewhere cannot sign in again
+	if err := s.Reauth(); err != skedda.ErrSessionExpired {
+		t.Errorf("Expected %v but got %v", skedda.ErrSessionExpired, err)
+	}
+}
@@ -12,6 +12,7 @@ import (
 	"net/url"
 	"regexp"
 	"strings"
+	"sync"
 	"time"
 
 	"golang.org/x/net/publicsuffix"
@@ -20,9 +21,14 @@ import (
 // Skedda struct
 type Skedda struct {
 	cookiejar       *cookiejar.Jar
+	transport       http.RoundTripper
 	username        string
 	password        string
 	isAuthenticated bool
+	mfaHandler      MFAHandler
+	grids           map[int]Grid
+	gridsMu         sync.Mutex
+	snap            SnapMode
 }
 
 var (
@@ -31,6 +37,10 @@ var (
 
 	// ErrCredsMissing is returned when credentials are missing
 	ErrCredsMissing = fmt.Errorf("missing credentials")
+
+	// ErrSessionExpired is returned when Skedda sends the requests of a domain
+	// to its login page, the session having expired or been revoked
+	ErrSessionExpired = fmt.Errorf("session expired")
 )
 
 // New initializes Skedda instance
@@ -44,9 +54,14 @@ func New() (*Skedda, error) {
 
 	return &Skedda{
 		c,
+		nil,
 		"",
 		"",
 		false,
+		nil,
+		map[int]Grid{},
+		sync.Mutex{},
+		SnapNone,
 	}, nil
 }
 
@@ -61,17 +76,72 @@ func NewWithCreds(username, password string) (*Skedda, error) {
 
 	return &Skedda{
 		c,
+		nil,
 		username,
 		password,
 		false,
+		nil,
+		map[int]Grid{},
+		sync.Mutex{},
+		SnapNone,
+	}, nil
+}
+
+// SetTransport sets the transport used for all requests made to Skedda, e.g.
+// to instrument them. A nil transport uses http.DefaultTransport.
+func (s *Skedda) SetTransport(t http.RoundTripper) {
+	s.transport = t
+}
+
+// Clone returns a client signing in with the same credentials, to be used
+// concurrently with s. Clients of a password sign in again with a cookiejar of
+// their own and without the MFA handler, so that they never prompt for a code.
+// Clients of a session share its cookiejar, which is safe for concurrent use.
+func (s *Skedda) Clone() (*Skedda, error) {
+	c := s.cookiejar
+	authenticated := s.isAuthenticated
+	if s.hasCredentials() {
+		jar, err := cookiejar.New(&cookiejar.Options{
+			PublicSuffixList: publicsuffix.List,
+		})
+		if err != nil {
+			return nil, err
+		}
+		c, authenticated = jar, false
+	}
+
+	s.gridsMu.Lock()
+	grids := map[int]Grid{}
+	for venueID, g := range s.grids {
+		grids[venueID] = g
+	}
+	s.gridsMu.Unlock()
+
+	return &Skedda{
+		c,
+		s.transport,
+		s.username,
+		s.password,
+		authenticated,
+		nil,
+		grids,
+		sync.Mutex{},
+		s.snap,
 	}, nil
 }
 
+// Username returns the username the client signs in with
+func (s *Skedda) Username() string {
+	return s.username
+}
+
 func (s *Skedda) hasCredentials() bool {
 	return s.username != "" && s.password != ""
 }
 
-// Auth authenticates into Skedda and stores session into a cookiejar
+// Auth authenticates into Skedda and stores session into a cookiejar. When
+// the account requires a second factor, the code is asked to the MFA handler,
+// or a *MFARequiredError is returned if there is none.
 func (s *Skedda) Auth() error {
 	if s.isAuthenticated {
 		return nil
@@ -81,20 +151,60 @@ func (s *Skedda) Auth() error {
 		return ErrCredsMissing
 	}
 
+	err := s.login("")
+
+	var mfaErr *MFARequiredError
+	if errors.As(err, &mfaErr) && s.mfaHandler != nil {
+		code, herr := s.mfaHandler(mfaErr.Challenge)
+		if herr != nil {
+			return fmt.Errorf("%w: %s", ErrAuthFailed, herr)
+		}
+
+		err = s.login(strings.TrimSpace(code))
+	}
+	if err != nil {
+		return err
+	}
+
+	s.isAuthenticated = true
+	return nil
+}
+
+// Reauth signs in again, e.g. after ErrSessionExpired, dropping the cookies
+// of the previous session. Clients of a session signed in elsewhere cannot
+// sign in again and return ErrSessionExpired.
+func (s *Skedda) Reauth() error {
+	if !s.hasCredentials() {
+		return ErrSessionExpired
+	}
+
+	if err := s.resetSession(); err != nil {
+		return err
+	}
+
+	return s.Auth()
+}
+
+// login posts the credentials, along with the code of the second factor if
+// any
+func (s *Skedda) login(code string) error {
 	c := http.Client{
-		Jar: s.cookiejar,
+		Jar:       s.cookiejar,
+		Transport: s.transport,
 	}
 
-	bodyMap := map[string]map[string]interface{}{
-		"login": {
-			"username":        s.username,
-			"password":        s.password,
-			"rememberMe":      false,
-			"arbitraryerrors": nil,
-		},
+	login := map[string]interface{}{
+		"username":        s.username,
+		"password":        s.password,
+		"rememberMe":      false,
+		"arbitraryerrors": nil,
+	}
+	if code != "" {
+		// Assumed field of the second factor, see mfaChallenge
+		login["twoFactorCode"] = code
 	}
 
-	body, err := json.Marshal(bodyMap)
+	body, err := json.Marshal(map[string]map[string]interface{}{"login": login})
 	if err != nil {
 		return err
 	}
@@ -112,8 +222,20 @@ func (s *Skedda) Auth() error {
 	defer res.Body.Close()
 
 	if res.StatusCode != 200 {
+		resBody, err := ioutil.ReadAll(res.Body)
+		if err != nil {
+			return err
+		}
+
+		if challenge, ok := mfaChallenge(resBody); ok {
+			if code != "" {
+				return fmt.Errorf("%w: invalid code: %s", ErrAuthFailed, challenge.Detail)
+			}
+			return &MFARequiredError{challenge}
+		}
+
 		var e error
-		detail, err := s.errorDetail(res.Body)
+		detail, err := s.errorDetail(bytes.NewReader(resBody))
 		if err != nil {
 			e = fmt.Errorf("unknown status: %d", res.StatusCode)
 		} else {
@@ -123,7 +245,74 @@ func (s *Skedda) Auth() error {
 		return fmt.Errorf("%w: %s", ErrAuthFailed, e)
 	}
 
-	s.isAuthenticated = true
+	return nil
+}
+
+// Logout signs out of Skedda, revoking the session, and forgets the cookies
+// of the session even when signing out fails. Signing out is assumed to need
+// the verification token of the primary domain, as the other requests do.
+func (s *Skedda) Logout() error {
+	if !s.isAuthenticated {
+		return s.resetSession()
+	}
+
+	err := s.logout()
+	if resetErr := s.resetSession(); err == nil {
+		err = resetErr
+	}
+
+	return err
+}
+
+func (s *Skedda) logout() error {
+	primaryDomain, err := s.PrimaryDomain()
+	if err != nil {
+		return fmt.Errorf("fetching primary domain: %w", err)
+	}
+
+	token, err := s.VerificationToken(primaryDomain)
+	if err != nil {
+		return fmt.Errorf("failed to get verification token: %w", err)
+	}
+
+	c := http.Client{
+		Jar:       s.cookiejar,
+		Transport: s.transport,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+
+	req, err := http.NewRequest("POST", "https://www.skedda.com/account/logout", nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Add("X-Skedda-RequestVerificationToken", token)
+
+	res, err := c.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != 200 && res.StatusCode != 302 {
+		return fmt.Errorf("unknown status: %d", res.StatusCode)
+	}
+
+	return nil
+}
+
+// resetSession replaces the cookiejar with an empty one
+func (s *Skedda) resetSession() error {
+	c, err := cookiejar.New(&cookiejar.Options{
+		PublicSuffixList: publicsuffix.List,
+	})
+	if err != nil {
+		return err
+	}
+
+	s.cookiejar = c
+	s.isAuthenticated = false
 	return nil
 }
 
@@ -136,7 +325,8 @@ func (s *Skedda) PrimaryDomain() (string, error) {
 	}
 
 	c := http.Client{
-		Jar: s.cookiejar,
+		Jar:       s.cookiejar,
+		Transport: s.transport,
 		CheckRedirect: func(req *http.Request, via []*http.Request) error {
 			return http.ErrUseLastResponse
 		},
@@ -172,6 +362,12 @@ func (s *Skedda) PrimaryDomain() (string, error) {
 			if err, ok := q["err"]; ok {
 				return "", fmt.Errorf("request failed: %s", err[0])
 			}
+
+			// The cookies of a session signed out in the meantime are sent
+			// back to the login page
+			if strings.Contains(strings.ToLower(redirectURL.Path), "login") {
+				return "", ErrSessionExpired
+			}
 		}
 
 		return "", fmt.Errorf("unknown URL: %s", redirectURL)
@@ -182,13 +378,14 @@ func (s *Skedda) PrimaryDomain() (string, error) {
 
 // Domains gets all the Skedda subdomains against the credentials
 func (s *Skedda) Domains(primaryDomain string) ([]string, error) {
-	token, err := s.verificationToken(primaryDomain)
+	token, err := s.VerificationToken(primaryDomain)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get verification token: %w", err)
 	}
 
 	c := http.Client{
-		Jar: s.cookiejar,
+		Jar:       s.cookiejar,
+		Transport: s.transport,
 	}
 
 	req, err := http.NewRequest("POST", fmt.Sprintf("https://%s.skedda.com/webs", primaryDomain), nil)
@@ -229,15 +426,17 @@ func (s *Skedda) Domains(primaryDomain string) ([]string, error) {
 	return domains, nil
 }
 
-// Venue fetches venue details for a given domain
+// Venue fetches venue details for a given domain. The grid of the venue is
+// set when Skedda tells its slot length, for Book to validate against.
 func (s *Skedda) Venue(domain string) (*Venue, []*Space, error) {
-	token, err := s.verificationToken(domain)
+	token, err := s.VerificationToken(domain)
 	if err != nil {
 		return nil, nil, fmt.Errorf("failed to get verification token: %w", err)
 	}
 
 	c := http.Client{
-		Jar: s.cookiejar,
+		Jar:       s.cookiejar,
+		Transport: s.transport,
 	}
 
 	req, err := http.NewRequest("GET", fmt.Sprintf("https://%s.skedda.com/webs", domain), nil)
@@ -279,6 +478,10 @@ func (s *Skedda) Venue(domain string) (*Venue, []*Space, error) {
 	}
 
 	venue := &bodyMap.Venue[0]
+	if venue.SlotLength > 0 {
+		s.SetGrid(venue.ID, venue.Grid())
+	}
+
 	spaces := []*Space{}
 	for i := range bodyMap.Spaces {
 		spaces = append(spaces, &bodyMap.Spaces[i])
@@ -289,13 +492,14 @@ func (s *Skedda) Venue(domain string) (*Venue, []*Space, error) {
 
 // Bookings fetches all bookings between a domain during a time period
 func (s *Skedda) Bookings(domain string, from, to time.Time) ([]*Booking, error) {
-	token, err := s.verificationToken(domain)
+	token, err := s.VerificationToken(domain)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get verification token: %w", err)
 	}
 
 	c := http.Client{
-		Jar: s.cookiejar,
+		Jar:       s.cookiejar,
+		Transport: s.transport,
 	}
 
 	dateFormat := "2006-01-02T15:04:05"
@@ -335,36 +539,42 @@ func (s *Skedda) Bookings(domain string, from, to time.Time) ([]*Booking, error)
 
 	bookings := []*Booking{}
 	for i, b := range bodyMap.Bookings {
-		add := true
 		// We have to manually filter out the recurring bookings as they are not
 		// filtered by Skedda
-		if len(b.RecurrenceRule.All()) > 0 {
-			t1Start := time.Date(0, 0, 0, from.Hour(), from.Minute(), from.Second(), 0, time.UTC)
-			t1End := time.Date(0, 0, 0, to.Hour(), to.Minute(), to.Second(), 0, time.UTC)
-
-			t2Start := time.Date(0, 0, 0, b.StartTime.Hour(), b.StartTime.Minute(), b.StartTime.Second(), 0, time.UTC)
-			t2End := time.Date(0, 0, 0, b.EndTime.Hour(), b.EndTime.Minute(), b.EndTime.Second(), 0, time.UTC)
-
-			add = TimeOverlaps(t1Start, t1End, t2Start, t2End)
+		if len(b.RecurrenceRule.All()) > 0 && len(b.Occurrences(from, to)) == 0 {
+			continue
 		}
 
-		if add {
-			bookings = append(bookings, &bodyMap.Bookings[i])
-		}
+		bookings = append(bookings, &bodyMap.Bookings[i])
 	}
 
 	return bookings, nil
 }
 
-// Book books a space in a domain
+// Book books a space in a domain. When the grid of the venue is set, the
+// booking has to be on it, unless SetSnap is used to round it.
 func (s *Skedda) Book(domain string, venueID int, spaceIDs []int, title string, from, to time.Time) error {
-	token, err := s.verificationToken(domain)
+	token, err := s.VerificationToken(domain)
 	if err != nil {
 		return fmt.Errorf("failed to get verification token: %w", err)
 	}
 
+	return s.BookWithToken(domain, token, venueID, spaceIDs, title, from, to)
+}
+
+// BookWithToken books a space in a domain using a previously fetched
+// verification token, saving a round trip when timing matters
+func (s *Skedda) BookWithToken(domain, token string, venueID int, spaceIDs []int, title string, from, to time.Time) error {
+	if grid, ok := s.grid(venueID); ok {
+		from, to = grid.Snap(from, to, s.snap)
+		if err := grid.Validate(from, to); err != nil {
+			return err
+		}
+	}
+
 	c := http.Client{
-		Jar: s.cookiejar,
+		Jar:       s.cookiejar,
+		Transport: s.transport,
 	}
 
 	dateFormat := "2006-01-02T15:04:05"
@@ -396,6 +606,7 @@ func (s *Skedda) Book(domain string, venueID int, spaceIDs []int, title string,
 	if err != nil {
 		return err
 	}
+	defer res.Body.Close()
 
 	if res.StatusCode != 200 {
 		detail, err := s.errorDetail(res.Body)
@@ -409,6 +620,75 @@ func (s *Skedda) Book(domain string, venueID int, spaceIDs []int, title string,
 	return nil
 }
 
+// Cancel cancels a booking in a domain.
+//
+// Experimental: the endpoint is assumed to be DELETE /bookings/{id}, as used by
+// the web app of Skedda, and has not been verified against all venues.
+func (s *Skedda) Cancel(domain string, bookingID int) error {
+	token, err := s.VerificationToken(domain)
+	if err != nil {
+		return fmt.Errorf("failed to get verification token: %w", err)
+	}
+
+	c := http.Client{
+		Jar:       s.cookiejar,
+		Transport: s.transport,
+	}
+
+	req, err := http.NewRequest("DELETE", fmt.Sprintf("https://%s.skedda.com/bookings/%d", domain, bookingID), nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Add("X-Skedda-RequestVerificationToken", token)
+
+	res, err := c.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != 200 && res.StatusCode != 204 {
+		detail, err := s.errorDetail(res.Body)
+		if err != nil {
+			return fmt.Errorf("unknown status: %d", res.StatusCode)
+		}
+
+		return errors.New(detail)
+	}
+
+	return nil
+}
+
+// ClockSkew estimates how far Skedda's clock is ahead of the local clock
+func (s *Skedda) ClockSkew() (time.Duration, error) {
+	c := http.Client{
+		Jar:       s.cookiejar,
+		Transport: s.transport,
+	}
+
+	req, err := http.NewRequest("HEAD", "https://www.skedda.com/", nil)
+	if err != nil {
+		return 0, err
+	}
+
+	sent := time.Now()
+	res, err := c.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer res.Body.Close()
+	received := time.Now()
+
+	serverTime, err := http.ParseTime(res.Header.Get("Date"))
+	if err != nil {
+		return 0, fmt.Errorf("invalid server time: %w", err)
+	}
+
+	// Assume the server stamped the response halfway through the round trip
+	localTime := sent.Add(received.Sub(sent) / 2)
+	return serverTime.Sub(localTime), nil
+}
+
 func (s *Skedda) errorDetail(r io.Reader) (string, error) {
 	buf, err := ioutil.ReadAll(r)
 	if err != nil {
@@ -428,9 +708,12 @@ func (s *Skedda) errorDetail(r io.Reader) (string, error) {
 	return detail.(string), nil
 }
 
-func (s *Skedda) verificationToken(domain string) (string, error) {
+// VerificationToken fetches the request verification token of a domain which
+// is required by all the requests made to that domain
+func (s *Skedda) VerificationToken(domain string) (string, error) {
 	c := http.Client{
-		Jar: s.cookiejar,
+		Jar:       s.cookiejar,
+		Transport: s.transport,
 		CheckRedirect: func(req *http.Request, via []*http.Request) error {
 			parts := strings.Split(req.URL.Hostname(), ".")
 			if parts[0] == "www" {
@@ -452,6 +735,12 @@ func (s *Skedda) verificationToken(domain string) (string, error) {
 	defer res.Body.Close()
 
 	if res.StatusCode == 302 {
+		// Skedda sends the signed out requests to its login page, and
+		// those of unknown domains to its home page
+		if strings.Contains(strings.ToLower(res.Header.Get("Location")), "login") {
+			return "", ErrSessionExpired
+		}
+
 		return "", fmt.Errorf("invalid domain")
 	}
 
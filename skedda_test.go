@@ -0,0 +1,114 @@
+package skedda_test
+
+import (
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alyyousuf7/skedda"
+)
+
+// bookingsTransport serves the verification token of the booking page and the
+// bookings list
+func bookingsTransport(t *testing.T, bookings string) roundTripFunc {
+	return func(req *http.Request) (*http.Response, error) {
+		body := ""
+		switch req.URL.Path {
+		case "/booking":
+			body = `<input name="__RequestVerificationToken" type="hidden" value="token" />`
+		case "/bookingslists":
+			body = bookings
+		default:
+			t.Errorf("Unexpected request to %s", req.URL)
+		}
+
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Body:       ioutil.NopCloser(strings.NewReader(body)),
+			Request:    req,
+		}, nil
+	}
+}
+
+func TestBookingsRecurring(t *testing.T) {
+	// Standup every weekday at 9am, Retro every Friday at 4pm and a one-off
+	// Workshop on Tuesday. Skedda filters the one-off bookings itself, but
+	// returns the recurring bookings whatever the period.
+	bookings := `{"bookings":[
+		{"id":1,"title":"Standup","start":"2020-03-02T09:00:00","end":"2020-03-02T09:15:00","recurrenceRule":"DTSTART:20200302T090000Z\nRRULE:FREQ=WEEKLY;BYDAY=MO,TU,WE,TH,FR;COUNT=20","spaces":[1],"venue":1},
+		{"id":2,"title":"Retro","start":"2020-03-06T16:00:00","end":"2020-03-06T17:00:00","recurrenceRule":"DTSTART:20200306T160000Z\nRRULE:FREQ=WEEKLY;COUNT=4","spaces":[1],"venue":1},
+		{"id":3,"title":"Workshop","start":"2020-03-03T13:00:00","end":"2020-03-03T15:00:00","recurrenceRule":null,"spaces":[1],"venue":1}
+	]}`
+
+	day := func(d int) time.Time {
+		return time.Date(2020, time.March, d, 0, 0, 0, 0, time.UTC)
+	}
+
+	testCases := []struct {
+		From     time.Time
+		To       time.Time
+		Expected []int
+	}{
+		// Midnight to midnight
+		{day(4), day(5), []int{1, 3}},
+		{day(6), day(7), []int{1, 2, 3}},
+		{day(7), day(8), []int{3}},
+		{day(2), day(9), []int{1, 2, 3}},
+		// Within a day
+		{day(6).Add(10 * time.Hour), day(6).Add(12 * time.Hour), []int{3}},
+		{day(6).Add(15 * time.Hour), day(6).Add(16*time.Hour + 30*time.Minute), []int{2, 3}},
+		// Across midnight
+		{day(5).Add(20 * time.Hour), day(6).Add(9*time.Hour + 5*time.Minute), []int{1, 3}},
+	}
+
+	for i, testCase := range testCases {
+		s, _ := skedda.NewWithSession("jane@acme.com", skedda.ParseCookieHeader("session=abc", "skedda.com"))
+		s.SetTransport(bookingsTransport(t, bookings))
+
+		result, err := s.Bookings("acme", testCase.From, testCase.To)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		ids := []int{}
+		for _, b := range result {
+			ids = append(ids, b.ID)
+		}
+
+		if len(ids) != len(testCase.Expected) {
+			t.Errorf("Expected %v but got %v: Test case %d", testCase.Expected, ids, i)
+			continue
+		}
+
+		for j := range ids {
+			if ids[j] != testCase.Expected[j] {
+				t.Errorf("Expected %v but got %v: Test case %d", testCase.Expected, ids, i)
+				break
+			}
+		}
+	}
+}
+
+func TestClockSkew(t *testing.T) {
+	testCases := []time.Duration{2 * time.Minute, -90 * time.Second, 0}
+
+	for i, expected := range testCases {
+		s, _ := skedda.New()
+		s.SetTransport(roundTripFunc(func(req *http.Request) (*http.Response, error) {
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Header:     http.Header{"Date": {time.Now().Add(expected).UTC().Format(http.TimeFormat)}},
+				Body:       ioutil.NopCloser(strings.NewReader("")),
+				Request:    req,
+			}, nil
+		}))
+
+		// The Date header only has a precision of a second
+		skew, err := s.ClockSkew()
+		if err != nil || skew <= expected-time.Second || skew > expected+time.Second {
+			t.Errorf("Expected about %v but got %v, %v: Test case %d", expected, skew, err, i)
+		}
+	}
+}
@@ -0,0 +1,300 @@
+// Package timeparse parses the dates, times of the day, durations and time
+// ranges typed by people on the command line, e.g. "next tue", "3:30pm",
+// "15:30", "in 2h", "45m" or "2-3:30pm".
+//
+// Like the rest of skedda, times are naive: the wall clock of the venue is
+// expressed in UTC, and the date of a time is its UTC date.
+package timeparse
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const day = 24 * time.Hour
+
+var (
+	clockRegexp    = regexp.MustCompile(`^(\d{1,2})(?:[:.](\d{2}))?\s*(am|pm|a|p)?$`)
+	relativeRegexp = regexp.MustCompile(`^(?:\+|in\s+)(.+)$`)
+	agoRegexp      = regexp.MustCompile(`^(?:-(.+)|(.+)\s+ago)$`)
+	durationRegexp = regexp.MustCompile(`(\d+(?:\.\d+)?)\s*([a-z]*)`)
+	rangeRegexp    = regexp.MustCompile(`^(.+?)\s*(?:-|–|\bto\b)\s*(.+)$`)
+)
+
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday, "sunday": time.Sunday,
+	"mon": time.Monday, "monday": time.Monday,
+	"tue": time.Tuesday, "tues": time.Tuesday, "tuesday": time.Tuesday,
+	"wed": time.Wednesday, "wednesday": time.Wednesday,
+	"thu": time.Thursday, "thur": time.Thursday, "thurs": time.Thursday, "thursday": time.Thursday,
+	"fri": time.Friday, "friday": time.Friday,
+	"sat": time.Saturday, "saturday": time.Saturday,
+}
+
+var units = map[string]time.Duration{
+	"": time.Minute, "m": time.Minute, "min": time.Minute, "mins": time.Minute, "minute": time.Minute, "minutes": time.Minute,
+	"h": time.Hour, "hr": time.Hour, "hrs": time.Hour, "hour": time.Hour, "hours": time.Hour,
+	"d": day, "day": day, "days": day,
+	"w": 7 * day, "wk": 7 * day, "week": 7 * day, "weeks": 7 * day,
+}
+
+// Now returns the current time of the local wall clock expressed in UTC
+func Now() time.Time {
+	now := time.Now()
+	return time.Date(now.Year(), now.Month(), now.Day(), now.Hour(), now.Minute(), now.Second(), now.Nanosecond(), time.UTC)
+}
+
+func normalize(s string) string {
+	return strings.Join(strings.Fields(strings.ToLower(s)), " ")
+}
+
+// Weekday parses the name of a day of the week, e.g. "tue" or "Tuesday"
+func Weekday(s string) (time.Weekday, error) {
+	if wd, ok := weekdays[normalize(s)]; ok {
+		return wd, nil
+	}
+
+	return 0, fmt.Errorf("invalid weekday: %s", s)
+}
+
+// Date parses a date relative to now and returns its midnight. It accepts
+// today, tomorrow, yesterday, YYYY-MM-DD, a weekday ("fri" is the coming
+// Friday and may be today, "next fri" is never today, "last fri" is the past
+// one) and an offset in days or weeks ("+3d", "in 2 weeks", "-7d",
+// "2 weeks ago"). An empty string is today.
+func Date(s string, now time.Time) (time.Time, error) {
+	s = normalize(s)
+	today := now.Truncate(day)
+
+	switch s {
+	case "", "today":
+		return today, nil
+	case "tomorrow", "tmr":
+		return today.Add(day), nil
+	case "yesterday":
+		return today.Add(-day), nil
+	}
+
+	if t, err := time.Parse("2006-01-02", s); err == nil {
+		return t, nil
+	}
+
+	if strings.HasPrefix(s, "last ") {
+		if wd, ok := weekdays[strings.TrimPrefix(s, "last ")]; ok {
+			days := (int(today.Weekday())-int(wd)+6)%7 + 1
+			return today.Add(-time.Duration(days) * day), nil
+		}
+	}
+
+	name := strings.TrimPrefix(strings.TrimPrefix(s, "this "), "next ")
+	if wd, ok := weekdays[name]; ok {
+		days := (int(wd) - int(today.Weekday()) + 7) % 7
+		if days == 0 && strings.HasPrefix(s, "next ") {
+			days = 7
+		}
+		return today.Add(time.Duration(days) * day), nil
+	}
+
+	sign, offset := time.Duration(1), ""
+	if m := relativeRegexp.FindStringSubmatch(s); m != nil {
+		offset = m[1]
+	} else if m := agoRegexp.FindStringSubmatch(s); m != nil {
+		sign, offset = -1, m[1]+m[2]
+	}
+
+	if offset != "" {
+		d, err := Duration(offset)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("invalid date: %s", s)
+		}
+		if d%day != 0 {
+			return time.Time{}, fmt.Errorf("invalid date: %s is not a number of days", s)
+		}
+		return today.Add(sign * d), nil
+	}
+
+	return time.Time{}, fmt.Errorf("invalid date: %s (e.g. today, tomorrow, fri, next tue, +3d, -7d, YYYY-MM-DD)", s)
+}
+
+// clock is a time of the day as written, with its am/pm suffix if any
+type clock struct {
+	hour     int
+	minute   int
+	meridiem string
+}
+
+func parseClock(s string) (clock, bool) {
+	switch s {
+	case "noon", "midday":
+		return clock{12, 0, ""}, true
+	case "midnight":
+		return clock{0, 0, ""}, true
+	}
+
+	m := clockRegexp.FindStringSubmatch(s)
+	if m == nil {
+		return clock{}, false
+	}
+
+	c := clock{meridiem: m[3]}
+	c.hour, _ = strconv.Atoi(m[1])
+	if m[2] != "" {
+		c.minute, _ = strconv.Atoi(m[2])
+	}
+	if c.meridiem != "" {
+		c.meridiem = c.meridiem[:1]
+	}
+
+	return c, true
+}
+
+// offset returns the clock as an offset from midnight, reading it with the
+// meridiem if the clock has none of its own
+func (c clock) offset(meridiem string) (time.Duration, error) {
+	if c.meridiem != "" {
+		meridiem = c.meridiem
+	}
+
+	hour := c.hour
+	if c.minute > 59 {
+		return 0, fmt.Errorf("invalid minutes: %d", c.minute)
+	}
+
+	if meridiem == "" {
+		if hour > 23 {
+			return 0, fmt.Errorf("invalid hour: %d", hour)
+		}
+	} else {
+		if hour < 1 || hour > 12 {
+			return 0, fmt.Errorf("invalid hour: %d%sm", hour, meridiem)
+		}
+		hour %= 12
+		if meridiem == "p" {
+			hour += 12
+		}
+	}
+
+	return time.Duration(hour)*time.Hour + time.Duration(c.minute)*time.Minute, nil
+}
+
+// Clock parses a time of the day, e.g. "3pm", "3:30pm", "15:30", "15" or
+// "noon", and returns it as an offset from midnight
+func Clock(s string) (time.Duration, error) {
+	c, ok := parseClock(normalize(s))
+	if !ok {
+		return 0, fmt.Errorf("invalid time: %s (e.g. 3pm, 3:30pm, 15:30)", s)
+	}
+
+	return c.offset("")
+}
+
+// Time parses a time on date. Besides a time of the day, it accepts "now" and
+// an offset from now ("+30m", "in 2h"), in which case the date is ignored.
+func Time(s string, date, now time.Time) (time.Time, error) {
+	s = normalize(s)
+	if s == "now" {
+		return now, nil
+	}
+
+	if m := relativeRegexp.FindStringSubmatch(s); m != nil {
+		d, err := Duration(m[1])
+		if err != nil {
+			return time.Time{}, fmt.Errorf("invalid time: %s", s)
+		}
+		return now.Add(d), nil
+	}
+
+	offset, err := Clock(s)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	return date.Truncate(day).Add(offset), nil
+}
+
+// Duration parses a duration, e.g. "45m", "1h30m", "1.5h", "2 hours" or
+// "90", which is a number of minutes
+func Duration(s string) (time.Duration, error) {
+	s = normalize(s)
+	if s == "" {
+		return 0, fmt.Errorf("invalid duration: empty")
+	}
+
+	matches := durationRegexp.FindAllStringSubmatch(s, -1)
+	if len(matches) == 0 || strings.TrimSpace(durationRegexp.ReplaceAllString(s, "")) != "" {
+		return 0, fmt.Errorf("invalid duration: %s (e.g. 45m, 1h30m, 2 hours)", s)
+	}
+
+	var total time.Duration
+	for _, m := range matches {
+		value, err := strconv.ParseFloat(m[1], 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid duration: %s", s)
+		}
+
+		unit, ok := units[m[2]]
+		if !ok {
+			return 0, fmt.Errorf("invalid duration: unknown unit %q in %s", m[2], s)
+		}
+
+		total += time.Duration(value * float64(unit))
+	}
+
+	return total, nil
+}
+
+// IsOffset tells whether s is an offset from now, e.g. "+30m" or "in 2h",
+// rather than a time of the day
+func IsOffset(s string) bool {
+	return relativeRegexp.MatchString(normalize(s))
+}
+
+// IsRange tells whether s is a time range rather than a single time
+func IsRange(s string) bool {
+	return rangeRegexp.MatchString(normalize(s))
+}
+
+// Range parses a time range on date, e.g. "2-3:30pm", "10am to noon" or
+// "now-4pm". A start without am/pm takes the one of the end when that keeps
+// the range in order, so "2-3:30pm" is 2pm till 3:30pm and "11-1pm" is 11am
+// till 1pm.
+func Range(s string, date, now time.Time) (time.Time, time.Time, error) {
+	m := rangeRegexp.FindStringSubmatch(normalize(s))
+	if m == nil {
+		return time.Time{}, time.Time{}, fmt.Errorf("invalid time range: %s (e.g. 2-3:30pm)", s)
+	}
+
+	till, err := Time(m[2], date, now)
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+
+	end, _ := parseClock(m[2])
+	start, ok := parseClock(m[1])
+	if !ok || start.meridiem != "" || end.meridiem == "" {
+		from, err := Time(m[1], date, now)
+		if err != nil {
+			return time.Time{}, time.Time{}, err
+		}
+		if !from.Before(till) {
+			return time.Time{}, time.Time{}, fmt.Errorf("invalid time range: %s ends before it starts", s)
+		}
+		return from, till, nil
+	}
+
+	for _, meridiem := range []string{end.meridiem, "a", "p"} {
+		offset, err := start.offset(meridiem)
+		if err != nil {
+			return time.Time{}, time.Time{}, err
+		}
+
+		if from := date.Truncate(day).Add(offset); from.Before(till) {
+			return from, till, nil
+		}
+	}
+
+	return time.Time{}, time.Time{}, fmt.Errorf("invalid time range: %s ends before it starts", s)
+}
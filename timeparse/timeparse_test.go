@@ -0,0 +1,149 @@
+package timeparse_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/alyyousuf7/skedda/timeparse"
+)
+
+// now is Wednesday 4 March 2020, 10:20am
+var now = time.Date(2020, time.March, 4, 10, 20, 0, 0, time.UTC)
+
+func at(day, hour, minute int) time.Time {
+	return time.Date(2020, time.March, day, hour, minute, 0, 0, time.UTC)
+}
+
+func TestDate(t *testing.T) {
+	testCases := []struct {
+		Input    string
+		Expected time.Time
+		Valid    bool
+	}{
+		{"", at(4, 0, 0), true},
+		{"Today", at(4, 0, 0), true},
+		{"tomorrow", at(5, 0, 0), true},
+		{"yesterday", at(3, 0, 0), true},
+		{"2020-03-20", at(20, 0, 0), true},
+		{"fri", at(6, 0, 0), true},
+		{"wed", at(4, 0, 0), true},
+		{"next wed", at(11, 0, 0), true},
+		{"next tue", at(10, 0, 0), true},
+		{"this Thursday", at(5, 0, 0), true},
+		{"+3d", at(7, 0, 0), true},
+		{"in 2 weeks", at(18, 0, 0), true},
+		{"-3d", at(1, 0, 0), true},
+		{"1 week ago", time.Date(2020, time.February, 26, 0, 0, 0, 0, time.UTC), true},
+		{"last wed", time.Date(2020, time.February, 26, 0, 0, 0, 0, time.UTC), true},
+		{"last mon", at(2, 0, 0), true},
+		{"+3h", time.Time{}, false},
+		{"someday", time.Time{}, false},
+	}
+
+	for i, testCase := range testCases {
+		date, err := timeparse.Date(testCase.Input, now)
+		if (err == nil) != testCase.Valid || !date.Equal(testCase.Expected) {
+			t.Errorf("Expected %v (valid: %v) but got %v, %v: Test case %d", testCase.Expected, testCase.Valid, date, err, i)
+		}
+	}
+}
+
+func TestTime(t *testing.T) {
+	testCases := []struct {
+		Input    string
+		Expected time.Time
+		Valid    bool
+	}{
+		{"3pm", at(5, 15, 0), true},
+		{"3:30PM", at(5, 15, 30), true},
+		{"12am", at(5, 0, 0), true},
+		{"12:15pm", at(5, 12, 15), true},
+		{"15:30", at(5, 15, 30), true},
+		{"9", at(5, 9, 0), true},
+		{"noon", at(5, 12, 0), true},
+		{"now", now, true},
+		{"+30m", at(4, 10, 50), true},
+		{"in 2h", at(4, 12, 20), true},
+		{"in 1 hour 15 minutes", at(4, 11, 35), true},
+		{"13pm", time.Time{}, false},
+		{"24:00", time.Time{}, false},
+		{"3:75pm", time.Time{}, false},
+		{"later", time.Time{}, false},
+	}
+
+	for i, testCase := range testCases {
+		result, err := timeparse.Time(testCase.Input, at(5, 0, 0), now)
+		if (err == nil) != testCase.Valid || !result.Equal(testCase.Expected) {
+			t.Errorf("Expected %v (valid: %v) but got %v, %v: Test case %d", testCase.Expected, testCase.Valid, result, err, i)
+		}
+	}
+}
+
+func TestDuration(t *testing.T) {
+	testCases := []struct {
+		Input    string
+		Expected time.Duration
+		Valid    bool
+	}{
+		{"45m", 45 * time.Minute, true},
+		{"1h30m", 90 * time.Minute, true},
+		{"1.5h", 90 * time.Minute, true},
+		{"2 hours", 2 * time.Hour, true},
+		{"90", 90 * time.Minute, true},
+		{"1 hr 15 mins", 75 * time.Minute, true},
+		{"", 0, false},
+		{"45 parsecs", 0, false},
+		{"an hour", 0, false},
+	}
+
+	for i, testCase := range testCases {
+		d, err := timeparse.Duration(testCase.Input)
+		if (err == nil) != testCase.Valid || d != testCase.Expected {
+			t.Errorf("Expected %v (valid: %v) but got %v, %v: Test case %d", testCase.Expected, testCase.Valid, d, err, i)
+		}
+	}
+}
+
+func TestIsOffset(t *testing.T) {
+	testCases := []struct {
+		Input    string
+		Expected bool
+	}{
+		{"+1h", true},
+		{"in 2h", true},
+		{"now", false},
+		{"4pm", false},
+		{"2-3pm", false},
+	}
+
+	for i, testCase := range testCases {
+		if actual := timeparse.IsOffset(testCase.Input); actual != testCase.Expected {
+			t.Errorf("Expected %v but got %v: Test case %d", testCase.Expected, actual, i)
+		}
+	}
+}
+
+func TestRange(t *testing.T) {
+	testCases := []struct {
+		Input string
+		From  time.Time
+		Till  time.Time
+		Valid bool
+	}{
+		{"2-3:30pm", at(5, 14, 0), at(5, 15, 30), true},
+		{"11-1pm", at(5, 11, 0), at(5, 13, 0), true},
+		{"9am-5pm", at(5, 9, 0), at(5, 17, 0), true},
+		{"14:00 - 15:30", at(5, 14, 0), at(5, 15, 30), true},
+		{"10am to noon", at(5, 10, 0), at(5, 12, 0), true},
+		{"now-4pm", now, at(5, 16, 0), true},
+		{"3pm-", time.Time{}, time.Time{}, false},
+		{"4pm-3pm", time.Time{}, time.Time{}, false},
+	}
+
+	for i, testCase := range testCases {
+		from, till, err := timeparse.Range(testCase.Input, at(5, 0, 0), now)
+		if (err == nil) != testCase.Valid || !from.Equal(testCase.From) || !till.Equal(testCase.Till) {
+			t.Errorf("Expected %v - %v (valid: %v) but got %v - %v, %v: Test case %d", testCase.From, testCase.Till, testCase.Valid, from, till, err, i)
+		}
+	}
+}
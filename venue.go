@@ -1,10 +1,40 @@
 package skedda
 
+import (
+	"fmt"
+	"time"
+)
+
 // Venue in Skedda
 type Venue struct {
-	ID     int
-	Name   string
-	Domain string `json:"subdomain"`
+	ID       int
+	Name     string
+	Domain   string `json:"subdomain"`
+	TimeZone string `json:"timeZone"`
+
+	// Booking grid of the venue in minutes, zero when not configured
+	SlotLength       int `json:"slotLength"`
+	MinBookingLength int `json:"minBookingLength"`
+	MaxBookingLength int `json:"maxBookingLength"`
+}
+
+// Location returns the time zone of the venue
+func (v Venue) Location() (*time.Location, error) {
+	if v.TimeZone == "" {
+		return nil, fmt.Errorf("venue has no time zone")
+	}
+
+	return time.LoadLocation(v.TimeZone)
+}
+
+// Grid returns the booking grid of the venue. Its Slot is zero, i.e.
+// DefaultSlot, when the venue does not tell its slot length.
+func (v Venue) Grid() Grid {
+	return Grid{
+		Slot:      time.Duration(v.SlotLength) * time.Minute,
+		MinLength: time.Duration(v.MinBookingLength) * time.Minute,
+		MaxLength: time.Duration(v.MaxBookingLength) * time.Minute,
+	}
 }
 
 func (v Venue) String() string {
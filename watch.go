@@ -0,0 +1,108 @@
+package skedda
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// maxWatchBackoff caps the polling interval after repeated failures
+const maxWatchBackoff = 10 * time.Minute
+
+// ErrInvalidInterval is returned when a polling interval is not positive,
+// which would poll Skedda without pause
+var ErrInvalidInterval = errors.New("interval must be positive")
+
+// AvailabilityEvent is emitted when the availability of a watched time period
+// changes or polling fails
+type AvailabilityEvent struct {
+	Time      time.Time
+	Available bool
+	Bookings  []*Booking
+	Err       error
+}
+
+// WatchAvailability polls the bookings of spaces in a domain during a time
+// period and emits an event whenever the period becomes available or occupied.
+// The first successful poll is always emitted. Failed polls are emitted as
+// well and back off exponentially. The channel is closed once ctx is done, or
+// after a single ErrInvalidInterval event when interval is not positive.
+func (s *Skedda) WatchAvailability(ctx context.Context, domain string, spaceIDs []int, from, to time.Time, interval time.Duration) <-chan AvailabilityEvent {
+	ch := make(chan AvailabilityEvent)
+
+	go func() {
+		defer close(ch)
+
+		if interval <= 0 {
+			select {
+			case ch <- AvailabilityEvent{Time: time.Now(), Err: ErrInvalidInterval}:
+			case <-ctx.Done():
+			}
+			return
+		}
+
+		wait := interval
+		reported := false
+		wasAvailable := false
+		for {
+			bookings, err := s.Bookings(domain, from, to)
+			event := AvailabilityEvent{Time: time.Now()}
+			if err != nil {
+				event.Err = err
+				wait *= 2
+				if wait > maxWatchBackoff {
+					wait = maxWatchBackoff
+				}
+			} else {
+				event.Bookings = bookingsInSpaces(bookings, spaceIDs)
+				event.Available = len(event.Bookings) == 0
+				wait = interval
+			}
+
+			if event.Err != nil || !reported || event.Available != wasAvailable {
+				select {
+				case ch <- event:
+				case <-ctx.Done():
+					return
+				}
+
+				if event.Err == nil {
+					reported = true
+					wasAvailable = event.Available
+				}
+			}
+
+			select {
+			case <-time.After(wait):
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return ch
+}
+
+// bookingsInSpaces returns the bookings which occupy any of the spaces
+func bookingsInSpaces(bookings []*Booking, spaceIDs []int) []*Booking {
+	result := []*Booking{}
+	for _, booking := range bookings {
+		for _, id := range booking.SpaceIDs {
+			if containsInt(spaceIDs, id) {
+				result = append(result, booking)
+				break
+			}
+		}
+	}
+
+	return result
+}
+
+func containsInt(list []int, v int) bool {
+	for _, item := range list {
+		if item == v {
+			return true
+		}
+	}
+	return false
+}
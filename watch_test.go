@@ -0,0 +1,106 @@
+package skedda_test
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alyyousuf7/skedda"
+)
+
+func TestWatchAvailability(t *testing.T) {
+	occupied := `{"bookings":[{"id":1,"title":"Standup","start":"2020-03-04T09:00:00","end":"2020-03-04T09:30:00","recurrenceRule":null,"spaces":[1],"venue":1}]}`
+	otherSpace := `{"bookings":[{"id":2,"title":"Retro","start":"2020-03-04T09:00:00","end":"2020-03-04T09:30:00","recurrenceRule":null,"spaces":[2],"venue":1}]}`
+
+	// Responses of the successive polls, an empty one failing
+	polls := []string{occupied, occupied, "", otherSpace, otherSpace, occupied}
+
+	poll := 0
+	s, _ := skedda.NewWithSession("jane@acme.com", skedda.ParseCookieHeader("session=abc", "skedda.com"))
+	s.SetTransport(roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		res := &http.Response{
+			StatusCode: http.StatusOK,
+			Body:       ioutil.NopCloser(strings.NewReader(`<input name="__RequestVerificationToken" type="hidden" value="token" />`)),
+			Request:    req,
+		}
+
+		if req.URL.Path == "/bookingslists" {
+			body := polls[len(polls)-1]
+			if poll < len(polls) {
+				body = polls[poll]
+			}
+			poll++
+
+			if body == "" {
+				res.StatusCode = http.StatusInternalServerError
+			}
+			res.Body = ioutil.NopCloser(strings.NewReader(body))
+		}
+
+		return res, nil
+	}))
+
+	expected := []struct {
+		Available bool
+		Failed    bool
+		Bookings  int
+	}{
+		{false, false, 1},
+		{false, true, 0},
+		{true, false, 0},
+		{false, false, 1},
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	from := time.Date(2020, time.March, 4, 9, 0, 0, 0, time.UTC)
+	events := s.WatchAvailability(ctx, "acme", []int{1}, from, from.Add(30*time.Minute), time.Millisecond)
+	for i, e := range expected {
+		event, ok := <-events
+		if !ok {
+			t.Fatalf("Expected %d events but got %d", len(expected), i)
+		}
+
+		if event.Available != e.Available || (event.Err != nil) != e.Failed || len(event.Bookings) != e.Bookings {
+			t.Errorf("Expected %+v but got %+v: Event %d", e, event, i+1)
+		}
+	}
+
+	cancel()
+	for range events {
+	}
+}
+
+func TestWatchAvailabilityInvalidInterval(t *testing.T) {
+	polled := false
+	s, _ := skedda.NewWithSession("jane@acme.com", skedda.ParseCookieHeader("session=abc", "skedda.com"))
+	s.SetTransport(roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		polled = true
+		return &http.Response{StatusCode: http.StatusOK, Body: ioutil.NopCloser(strings.NewReader("{}")), Request: req}, nil
+	}))
+
+	from := time.Date(2020, time.March, 4, 9, 0, 0, 0, time.UTC)
+	testCases := []time.Duration{0, -1 * time.Minute}
+
+	for i, interval := range testCases {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+
+		events := []skedda.AvailabilityEvent{}
+		for event := range s.WatchAvailability(ctx, "acme", []int{1}, from, from.Add(30*time.Minute), interval) {
+			events = append(events, event)
+		}
+		cancel()
+
+		if len(events) != 1 || events[0].Err != skedda.ErrInvalidInterval {
+			t.Errorf("Expected a single %v event but got %v: Test case %d", skedda.ErrInvalidInterval, events, i)
+		}
+	}
+
+	if polled {
+		t.Errorf("Expected no poll with an invalid interval")
+	}
+}